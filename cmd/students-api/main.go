@@ -12,32 +12,31 @@ import (
 	"time"
 
 	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/gc"
+	"github.com/surajNirala/student-api/internal/http/middleware"
 	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/cache"
+	"github.com/surajNirala/student-api/internal/stream"
+	"github.com/surajNirala/student-api/internal/webhook"
 	"github.com/surajNirala/student-api/routes"
 
 	// "gorm.io/driver/mysql"
-	"github.com/surajNirala/student-api/internal/storage/mysql"
-	"github.com/surajNirala/student-api/internal/storage/sqlite"
+	_ "github.com/surajNirala/student-api/internal/storage/memory"
+	_ "github.com/surajNirala/student-api/internal/storage/mysql"
+	_ "github.com/surajNirala/student-api/internal/storage/postgres"
+	_ "github.com/surajNirala/student-api/internal/storage/sqlite"
 )
 
 func main() {
 	// Load Config
 	cfg := config.MustLoad()
-	var storage storage.Storage
-	var err error
-	// Decide storage based on config
-	if cfg.MySQL != nil {
-		storage, err = mysql.MysqlConnect(cfg)
-		if err != nil {
-			log.Fatal("MySQL connection error:", err)
-		}
-	} else if cfg.StoragePath != "" {
-		storage, err = sqlite.New(cfg)
-		if err != nil {
-			log.Fatal("SQLite connection error:", err)
-		}
-	} else {
-		log.Fatal("No valid database configuration found")
+	// Backends register themselves when imported above; storage.driver picks one
+	storage, err := storage.Open(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if cfg.Storage.Cache.Enabled {
+		storage = cache.New(storage, cfg.Storage.Cache)
 	}
 	// Database Setup
 	// storage, err := sqlite.New(cfg)
@@ -52,15 +51,18 @@ func main() {
 	// if err != nil {
 	// 	log.Fatal(err)
 	// }
-	slog.Info("Storage Intilized", slog.String("env", cfg.Env), slog.String("Version", "1.0.0"))
+	slog.Info("Storage Intilized", slog.String("env", cfg.Env), slog.String("driver", cfg.Storage.Driver), slog.String("Version", "1.0.0"))
 
 	// Setup Router
+	collector := gc.New(storage, cfg.GC, cfg.Uploads.Tus)
+	dispatcher := webhook.New(storage, cfg.Webhooks)
+	broker := stream.New(storage, cfg.Events)
 	router := http.NewServeMux()
-	routes.RouteLoad(router, storage)
+	routes.RouteLoad(router, storage, cfg, collector, broker)
 	// Setup Server
 	server := http.Server{
 		Addr:    cfg.HTTPServer.Addr,
-		Handler: router,
+		Handler: middleware.RequestID(middleware.ClientID(middleware.Actor(router))),
 	}
 
 	//Gressfully shut down server
@@ -75,7 +77,15 @@ func main() {
 		}
 	}()
 
+	// Background jobs stop once shutdown begins
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	go collector.Start(jobsCtx)
+	go dispatcher.Start(jobsCtx)
+	// Ending the broker also ends open event streams, so shutdown need not wait for them
+	go broker.Start(jobsCtx)
+
 	<-done
+	stopJobs()
 	slog.Info("Shutting down the server.")
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
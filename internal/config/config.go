@@ -1,23 +1,230 @@
 package config
 
 import (
+	"errors"
 	"flag"
 	"log"
 	"os"
+	"time"
 
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
 type HTTPServer struct {
-	Addr string `yaml:"address" env-required`
+	Addr string `yaml:"address" env-required:"true"`
 }
 
 // env-defult : "production"
 type Config struct {
-	Env         string       `yaml:"env" env:"ENV" env-required:"true"`
-	StoragePath string       `yaml:"storage_path"`
-	MySQL       *MySQLConfig `yaml:"mysql"`
+	Env         string          `yaml:"env" env:"ENV" env-required:"true"`
+	Storage     Storage         `yaml:"storage"`
+	StoragePath string          `yaml:"storage_path"`
+	Memory      bool            `yaml:"memory"`
+	MySQL       *MySQLConfig    `yaml:"mysql"`
+	Postgres    *PostgresConfig `yaml:"postgres"`
 	HTTPServer  `yaml:"http_server"`
+	Uploads     Uploads     `yaml:"uploads"`
+	Signing     Signing     `yaml:"signing"`
+	GC          GC          `yaml:"gc"`
+	Webhooks    Webhooks    `yaml:"webhooks"`
+	Events      Events      `yaml:"events"`
+	Idempotency Idempotency `yaml:"idempotency"`
+	Batch       Batch       `yaml:"batch"`
+	Validation  Validation  `yaml:"validation"`
+}
+
+// Storage selects the backend by the name it registered under, such as
+// "sqlite", "mysql", "postgres" or "memory". Configs written before the
+// key existed leave it empty and get the backend their other settings
+// imply.
+type Storage struct {
+	Driver string `yaml:"driver" env:"STORAGE_DRIVER"`
+	Pool   Pool   `yaml:"pool"`
+	SQLite SQLite `yaml:"sqlite"`
+	Retry  Retry  `yaml:"retry"`
+	Cache  Cache  `yaml:"cache"`
+}
+
+// Cache keeps up to Size student lookups in memory for TTL. Each API
+// instance has its own cache, so with several instances a change made
+// through one shows up on the others only after TTL.
+type Cache struct {
+	Enabled bool          `yaml:"enabled"`
+	Size    int           `yaml:"size" env-default:"1000"`
+	TTL     time.Duration `yaml:"ttl" env-default:"30s"`
+}
+
+// Retry controls how the SQL backends cope with an unavailable database.
+// At startup the first connection is retried for up to MaxWait. Later,
+// operations that are safe to repeat are tried up to Attempts times when
+// they fail with a transient error such as a deadlock or a dropped
+// connection. Delays start at InitialInterval and double up to MaxInterval.
+type Retry struct {
+	InitialInterval time.Duration `yaml:"initial_interval" env-default:"500ms"`
+	MaxInterval     time.Duration `yaml:"max_interval" env-default:"10s"`
+	MaxWait         time.Duration `yaml:"max_wait" env-default:"1m"`
+	Attempts        int           `yaml:"attempts" env-default:"3"`
+}
+
+// Pool sizes the connection pool of the SQL backends and recycles
+// connections before the server or a proxy drops them.
+type Pool struct {
+	MaxOpenConns    int           `yaml:"max_open_conns" env-default:"25"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env-default:"10"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env-default:"30m"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env-default:"5m"`
+}
+
+// SQLite tunes the SQLite backend. WAL lets readers run alongside a
+// writer, and BusyTimeout makes a writer wait for a lock instead of
+// failing with "database is locked".
+type SQLite struct {
+	JournalMode string        `yaml:"journal_mode" env-default:"WAL"`
+	BusyTimeout time.Duration `yaml:"busy_timeout" env-default:"5s"`
+}
+
+// setDefaults infers the driver the way main chose a backend before
+// storage.driver was introduced.
+func (s *Storage) setDefaults(cfg *Config) {
+	if s.Driver != "" {
+		return
+	}
+	switch {
+	case cfg.Memory:
+		s.Driver = "memory"
+	case cfg.MySQL != nil:
+		s.Driver = "mysql"
+	case cfg.Postgres != nil:
+		s.Driver = "postgres"
+	case cfg.StoragePath != "":
+		s.Driver = "sqlite"
+	}
+}
+
+// GC schedules the sweeper that removes files and blobs nothing refers to
+// any more. Files on disk without metadata are only touched once they are
+// older than MinAge, so uploads in flight are left alone. Disabled stops
+// the schedule; sweeps can still be started by hand.
+type GC struct {
+	Interval time.Duration `yaml:"interval" env-default:"1h"`
+	MinAge   time.Duration `yaml:"min_age" env-default:"1h"`
+	DryRun   bool          `yaml:"dry_run"`
+	Disabled bool          `yaml:"disabled"`
+}
+
+// Events tunes the stream of student changes. Every PollInterval new
+// events are read from the outbox and kept in a buffer of BufferSize, from
+// which reconnecting clients resume; older events are read back from the
+// outbox. Idle streams get a comment every Heartbeat so proxies keep
+// them open.
+type Events struct {
+	PollInterval time.Duration `yaml:"poll_interval" env-default:"1s"`
+	BufferSize   int           `yaml:"buffer_size" env-default:"1000"`
+	Heartbeat    time.Duration `yaml:"heartbeat" env-default:"15s"`
+}
+
+// Idempotency lets clients retry POST requests that send an
+// Idempotency-Key header. The first response to a key is kept for TTL and
+// returned again for repeats of the request. A request still unanswered
+// after LockTimeout is taken to have died, freeing its key. Bodies are
+// fingerprinted in memory and so limited to MaxBodySize; file uploads
+// are not covered.
+type Idempotency struct {
+	TTL         time.Duration `yaml:"ttl" env-default:"24h"`
+	LockTimeout time.Duration `yaml:"lock_timeout" env-default:"1m"`
+	MaxBodySize int64         `yaml:"max_body_size" env-default:"1048576"`
+}
+
+// Batch limits POST /api/batch to MaxRequests sub-requests in a body of
+// at most MaxBodySize bytes.
+type Batch struct {
+	MaxRequests int   `yaml:"max_requests" env-default:"20"`
+	MaxBodySize int64 `yaml:"max_body_size" env-default:"1048576"`
+}
+
+// Validation checks requests against the OpenAPI document before they
+// reach the handlers. JSON bodies are read into memory for it, up to
+// MaxBodySize. Responses also checks JSON responses, replacing any that
+// do not match the document with a 500; it buffers every response, so it
+// is meant for tests and development.
+type Validation struct {
+	Responses   bool  `yaml:"responses" env:"VALIDATE_RESPONSES"`
+	MaxBodySize int64 `yaml:"max_body_size" env-default:"1048576"`
+}
+
+// Webhooks schedules delivery of student events to subscribed URLs.
+// Every Interval the dispatcher sends up to BatchSize due deliveries,
+// each within Timeout. A delivery that fails is tried again after a
+// delay growing from InitialInterval to MaxInterval, and is marked dead
+// after MaxAttempts; dead deliveries can be replayed from the admin API.
+// Disabled stops the dispatcher while events keep collecting.
+type Webhooks struct {
+	Interval        time.Duration `yaml:"interval" env-default:"5s"`
+	Timeout         time.Duration `yaml:"timeout" env-default:"10s"`
+	BatchSize       int           `yaml:"batch_size" env-default:"100"`
+	MaxAttempts     int           `yaml:"max_attempts" env-default:"8"`
+	InitialInterval time.Duration `yaml:"initial_interval" env-default:"30s"`
+	MaxInterval     time.Duration `yaml:"max_interval" env-default:"1h"`
+	Disabled        bool          `yaml:"disabled"`
+}
+
+// Signing holds the HMAC keys for signed download links, indexed by key
+// id. New links are signed with ActiveKey; removing a key revokes every
+// link it signed. BaseURL prefixes issued links and defaults to the
+// address of the request that asked for them.
+type Signing struct {
+	ActiveKey  string            `yaml:"active_key"`
+	Keys       map[string]string `yaml:"keys"`
+	BaseURL    string            `yaml:"base_url"`
+	DefaultTTL time.Duration     `yaml:"default_ttl" env-default:"1h"`
+	MaxTTL     time.Duration     `yaml:"max_ttl" env-default:"168h"`
+}
+
+type Uploads struct {
+	File      UploadPolicy `yaml:"file"`
+	LargeFile UploadPolicy `yaml:"large_file"`
+	Photo     Photo        `yaml:"photo"`
+	Tus       Tus          `yaml:"tus"`
+	Quota     Quota        `yaml:"quota"`
+}
+
+// Quota caps the bytes stored per student and in total; zero means no limit.
+type Quota struct {
+	PerStudent int64 `yaml:"per_student"`
+	Total      int64 `yaml:"total"`
+}
+
+// UploadPolicy limits what a single upload endpoint accepts. AllowedTypes
+// holds MIME types such as "application/pdf" or wildcards such as
+// "image/*"; an empty list accepts any type.
+type UploadPolicy struct {
+	MaxSize      int64    `yaml:"max_size"`
+	AllowedTypes []string `yaml:"allowed_types"`
+}
+
+// Photo limits profile photo uploads. Only JPEG and PNG are accepted, and
+// both sides must lie within [MinDimension, MaxDimension] pixels.
+type Photo struct {
+	MaxSize      int64 `yaml:"max_size" env-default:"5242880"`
+	MinDimension int   `yaml:"min_dimension" env-default:"128"`
+	MaxDimension int   `yaml:"max_dimension" env-default:"6000"`
+}
+
+func (u *Uploads) setDefaults() {
+	if u.File.MaxSize == 0 {
+		u.File.MaxSize = 10 * 1024 * 1024
+	}
+	if u.LargeFile.MaxSize == 0 {
+		u.LargeFile.MaxSize = 1024 * 1024 * 1024
+	}
+}
+
+// Tus configures resumable uploads. Partial uploads that receive no data
+// for longer than Expiry are removed by the garbage collector.
+type Tus struct {
+	MaxSize      int64         `yaml:"max_size" env-default:"1073741824"`
+	AllowedTypes []string      `yaml:"allowed_types"`
+	Expiry       time.Duration `yaml:"expiry" env-default:"24h"`
 }
 
 func MustLoad() *Config {
@@ -40,6 +247,10 @@ func MustLoad() *Config {
 	if err != nil {
 		log.Fatalf("Can not read config file %s", err.Error())
 	}
+	cfg.setDefaults()
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("Invalid config: %s", err.Error())
+	}
 	return &cfg
 }
 
@@ -49,6 +260,101 @@ type MySQLConfig struct {
 	User     string `yaml:"user" env-required:"true"`
 	Password string `yaml:"password" env-required:"true"`
 	DBName   string `yaml:"dbname" env-required:"true"`
+	// DialTimeout bounds connecting; ReadTimeout and WriteTimeout bound
+	// each read from and write to an open connection.
+	DialTimeout  time.Duration `yaml:"dial_timeout"`
+	ReadTimeout  time.Duration `yaml:"read_timeout"`
+	WriteTimeout time.Duration `yaml:"write_timeout"`
+	// Replicas are DSNs of read replicas, such as
+	// "reader:secret@tcp(replica1:3306)/student_api". Student reads go to
+	// them, except for a client that changed students within the last
+	// StickyWindow, which reads from the primary to see its own writes. A
+	// replica that cannot be reached is skipped for ReplicaCooldown.
+	Replicas        []string      `yaml:"replicas"`
+	StickyWindow    time.Duration `yaml:"sticky_window"`
+	ReplicaCooldown time.Duration `yaml:"replica_cooldown"`
+}
+
+// setDefaults fills in what env-default cannot, since cleanenv leaves
+// optional sections such as this one alone.
+func (m *MySQLConfig) setDefaults() {
+	if m.DialTimeout == 0 {
+		m.DialTimeout = 5 * time.Second
+	}
+	if m.ReadTimeout == 0 {
+		m.ReadTimeout = 30 * time.Second
+	}
+	if m.WriteTimeout == 0 {
+		m.WriteTimeout = 30 * time.Second
+	}
+	if m.StickyWindow == 0 {
+		m.StickyWindow = 5 * time.Second
+	}
+	if m.ReplicaCooldown == 0 {
+		m.ReplicaCooldown = 30 * time.Second
+	}
+}
+
+// PostgresConfig selects the PostgreSQL backend. SSLMode takes the libpq
+// values ("disable", "require", "verify-full", ...). The driver has no
+// read or write timeouts; ConnectTimeout only bounds connecting and is
+// rounded to whole seconds.
+type PostgresConfig struct {
+	Host           string        `yaml:"host" env-required:"true"`
+	Port           int           `yaml:"port"`
+	User           string        `yaml:"user" env-required:"true"`
+	Password       string        `yaml:"password" env-required:"true"`
+	DBName         string        `yaml:"dbname" env-required:"true"`
+	SSLMode        string        `yaml:"sslmode"`
+	ConnectTimeout time.Duration `yaml:"connect_timeout"`
+}
+
+func (p *PostgresConfig) setDefaults() {
+	if p.Port == 0 {
+		p.Port = 5432
+	}
+	if p.SSLMode == "" {
+		p.SSLMode = "disable"
+	}
+	if p.ConnectTimeout == 0 {
+		p.ConnectTimeout = 5 * time.Second
+	}
+}
+
+func (c *Config) setDefaults() {
+	c.Uploads.setDefaults()
+	c.Storage.setDefaults(c)
+	if c.MySQL != nil {
+		c.MySQL.setDefaults()
+	}
+	if c.Postgres != nil {
+		c.Postgres.setDefaults()
+	}
+}
+
+// validate rejects values env-default cannot catch because they were set
+// explicitly, such as a zero interval, which would otherwise only fail
+// once the server is running.
+func (c *Config) validate() error {
+	if !c.GC.Disabled && c.GC.Interval <= 0 {
+		return errors.New("gc.interval must be positive")
+	}
+	if !c.Webhooks.Disabled && c.Webhooks.Interval <= 0 {
+		return errors.New("webhooks.interval must be positive")
+	}
+	if !c.Webhooks.Disabled && c.Webhooks.BatchSize <= 0 {
+		return errors.New("webhooks.batch_size must be positive")
+	}
+	if c.Events.PollInterval <= 0 {
+		return errors.New("events.poll_interval must be positive")
+	}
+	if c.Events.Heartbeat <= 0 {
+		return errors.New("events.heartbeat must be positive")
+	}
+	if c.Events.BufferSize <= 0 {
+		return errors.New("events.buffer_size must be positive")
+	}
+	return nil
 }
 
 // type MysqlConfig struct {
@@ -79,6 +385,10 @@ func MustLoadMySQL() *Config {
 	if err != nil {
 		log.Fatalf("Cannot read config file: %s", err.Error())
 	}
+	cfg.setDefaults()
+	if err := cfg.validate(); err != nil {
+		log.Fatalf("Invalid config: %s", err.Error())
+	}
 
 	return &cfg
 }
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() Config {
+		return Config{GC: GC{Interval: time.Hour}, Webhooks: Webhooks{Interval: 5 * time.Second, BatchSize: 100},
+			Events: Events{PollInterval: time.Second, BufferSize: 1000, Heartbeat: 15 * time.Second}}
+	}
+	tests := []struct {
+		name   string
+		change func(c *Config)
+		ok     bool
+	}{
+		{"defaults", func(c *Config) {}, true},
+		{"zero gc interval", func(c *Config) { c.GC.Interval = 0 }, false},
+		{"negative gc interval", func(c *Config) { c.GC.Interval = -time.Second }, false},
+		{"zero gc interval while disabled", func(c *Config) { c.GC.Interval, c.GC.Disabled = 0, true }, true},
+		{"zero webhooks interval", func(c *Config) { c.Webhooks.Interval = 0 }, false},
+		{"zero webhooks batch size", func(c *Config) { c.Webhooks.BatchSize = 0 }, false},
+		{"negative webhooks batch size", func(c *Config) { c.Webhooks.BatchSize = -1 }, false},
+		{"zero webhooks settings while disabled", func(c *Config) {
+			c.Webhooks.Interval, c.Webhooks.BatchSize, c.Webhooks.Disabled = 0, 0, true
+		}, true},
+		{"zero events poll interval", func(c *Config) { c.Events.PollInterval = 0 }, false},
+		{"zero events heartbeat", func(c *Config) { c.Events.Heartbeat = 0 }, false},
+		{"zero events buffer size", func(c *Config) { c.Events.BufferSize = 0 }, false},
+		{"negative events buffer size", func(c *Config) { c.Events.BufferSize = -1 }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.change(&cfg)
+			if err := cfg.validate(); (err == nil) != tt.ok {
+				t.Errorf("validate() = %v, want ok %v", err, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+// Package gc reconciles the upload directory with the file metadata,
+// removing files of deleted students, content no file refers to, expired
+// resumable uploads and anything on disk the database does not know about.
+// It also clears out expired idempotency keys.
+package gc
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/filestore"
+)
+
+// Report lists what a sweep deleted, or would have deleted in a dry run.
+type Report struct {
+	StartedAt      time.Time `json:"started_at"`
+	FinishedAt     time.Time `json:"finished_at"`
+	DryRun         bool      `json:"dry_run"`
+	OrphanedFiles  []uint64  `json:"orphaned_files"`
+	Blobs          []string  `json:"blobs"`
+	PartialUploads []string  `json:"partial_uploads"`
+	StrayFiles     []string  `json:"stray_files"`
+	// IdempotencyKeys counts the expired keys removed; dry runs leave
+	// them alone.
+	IdempotencyKeys int64    `json:"idempotency_keys"`
+	Errors          []string `json:"errors,omitempty"`
+}
+
+type Collector struct {
+	storage      storage.Storage
+	cfg          config.GC
+	uploadExpiry time.Duration
+	mu           sync.Mutex
+	last         *Report
+}
+
+func New(storage storage.Storage, cfg config.GC, tus config.Tus) *Collector {
+	return &Collector{storage: storage, cfg: cfg, uploadExpiry: tus.Expiry}
+}
+
+// Start sweeps every cfg.Interval until ctx is done.
+func (c *Collector) Start(ctx context.Context) {
+	if c.cfg.Disabled {
+		return
+	}
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Run(c.cfg.DryRun)
+		}
+	}
+}
+
+// Last returns the report of the most recent sweep, if there was one.
+func (c *Collector) Last() (Report, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.last == nil {
+		return Report{}, false
+	}
+	return *c.last, true
+}
+
+// Run performs one sweep. Sweeps never overlap; a dry run only reports.
+// Content released by deleting orphaned files is removed in the same
+// sweep, but a dry run cannot know about it and lists it on the next one.
+func (c *Collector) Run(dryRun bool) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	report := Report{StartedAt: time.Now(), DryRun: dryRun}
+	fail := func(err error) {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	files, err := c.storage.OrphanedFiles()
+	if err != nil {
+		fail(err)
+	}
+	for _, file := range files {
+		if !dryRun {
+			if _, err := c.storage.DeleteFileByID(int64(file.Id)); err != nil {
+				fail(err)
+				continue
+			}
+		}
+		report.OrphanedFiles = append(report.OrphanedFiles, file.Id)
+	}
+
+	blobs, err := c.storage.UnreferencedBlobs()
+	if err != nil {
+		fail(err)
+	}
+	for _, blob := range blobs {
+		if !dryRun {
+			deleted, err := c.storage.DeleteBlob(blob.SHA256)
+			if err != nil {
+				fail(err)
+				continue
+			}
+			if !deleted {
+				continue
+			}
+		}
+		report.Blobs = append(report.Blobs, blob.SHA256)
+	}
+
+	uploads, err := c.storage.ExpiredUploads(time.Now().Add(-c.uploadExpiry))
+	if err != nil {
+		fail(err)
+	}
+	for _, upload := range uploads {
+		if !dryRun {
+			if err := c.storage.DeleteUpload(upload.Id); err != nil {
+				fail(err)
+				continue
+			}
+		}
+		// A completed upload only leaves its record behind; its bytes belong to a file
+		if !upload.Complete() {
+			report.PartialUploads = append(report.PartialUploads, upload.Id)
+		}
+	}
+
+	if !dryRun {
+		report.IdempotencyKeys, err = c.storage.DeleteExpiredIdempotencyKeys(time.Now())
+		if err != nil {
+			fail(err)
+		}
+	}
+
+	c.sweepDisk(&report, fail)
+
+	report.FinishedAt = time.Now()
+	c.last = &report
+	slog.Info("Garbage collection finished",
+		slog.Bool("dry_run", dryRun),
+		slog.Int("orphaned_files", len(report.OrphanedFiles)),
+		slog.Int("blobs", len(report.Blobs)),
+		slog.Int("partial_uploads", len(report.PartialUploads)),
+		slog.Int("stray_files", len(report.StrayFiles)),
+		slog.Int64("idempotency_keys", report.IdempotencyKeys),
+		slog.Int("errors", len(report.Errors)),
+	)
+	return report
+}
+
+// sweepDisk removes files the database has no record of. Only files older
+// than cfg.MinAge are considered, since an upload in progress writes its
+// file before recording it.
+func (c *Collector) sweepDisk(report *Report, fail func(error)) {
+	cutoff := time.Now().Add(-c.cfg.MinAge)
+	remove := func(entry filestore.Entry) {
+		if !report.DryRun {
+			if err := filestore.Remove(entry.Path); err != nil {
+				fail(err)
+				return
+			}
+		}
+		report.StrayFiles = append(report.StrayFiles, entry.Path)
+	}
+
+	blobs, err := filestore.Blobs()
+	if err != nil {
+		fail(err)
+	}
+	for _, entry := range blobs {
+		if entry.ModTime.After(cutoff) {
+			continue
+		}
+		known, err := c.storage.HasBlob(entry.Name)
+		if err != nil {
+			fail(err)
+			continue
+		}
+		if !known {
+			remove(entry)
+		}
+	}
+
+	temps, err := filestore.Temps()
+	if err != nil {
+		fail(err)
+	}
+	for _, entry := range temps {
+		if entry.ModTime.Before(cutoff) {
+			remove(entry)
+		}
+	}
+
+	partials, err := filestore.Partials()
+	if err != nil {
+		fail(err)
+	}
+	for _, entry := range partials {
+		if entry.ModTime.After(cutoff) {
+			continue
+		}
+		_, err := c.storage.GetUpload(entry.Name)
+		if errors.Is(err, sql.ErrNoRows) {
+			remove(entry)
+		} else if err != nil {
+			fail(err)
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package gc
+
+import (
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage/filestore"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+)
+
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func TestRun(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := memory.New()
+	asha, err := store.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ravi, err := store.CreateStudent("Ravi", "ravi@example.com", 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orphan, err := store.StudentFileUpload10MB(asha, "asha.txt", "text/plain", []byte("asha's"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := store.StudentFileUpload10MB(ravi, "ravi.txt", "text/plain", []byte("ravi's"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DeleteStudentByID(asha); err != nil {
+		t.Fatal(err)
+	}
+
+	// Leftovers on disk that no record refers to
+	stray, err := filestore.Save(strings.NewReader("stray"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	strayPath, err := filestore.Commit(stray)
+	if err != nil {
+		t.Fatal(err)
+	}
+	temp, err := filestore.Save(strings.NewReader("interrupted"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, partialPath, err := filestore.CreatePartial()
+	if err != nil {
+		t.Fatal(err)
+	}
+	strays := []string{strayPath, temp.Path, partialPath}
+	slices.Sort(strays)
+
+	collector := New(store, config.GC{}, config.Tus{Expiry: time.Hour})
+	if _, ok := collector.Last(); ok {
+		t.Error("Last before any sweep reported one")
+	}
+	check := func(report Report, dryRun bool) {
+		t.Helper()
+		got := slices.Clone(report.StrayFiles)
+		slices.Sort(got)
+		if report.DryRun != dryRun || !slices.Equal(report.OrphanedFiles, []uint64{orphan.Id}) || !slices.Equal(got, strays) || len(report.Errors) != 0 {
+			t.Errorf("report = %+v", report)
+		}
+		if last, ok := collector.Last(); !ok || !last.StartedAt.Equal(report.StartedAt) {
+			t.Errorf("Last = %+v, %v", last, ok)
+		}
+	}
+
+	check(collector.Run(true), true)
+	if _, err := store.GetFileByID(int64(orphan.Id)); err != nil {
+		t.Errorf("dry run deleted the orphaned file: %v", err)
+	}
+	for _, path := range append(strays, orphan.Path) {
+		if !exists(path) {
+			t.Errorf("dry run removed %s", path)
+		}
+	}
+
+	check(collector.Run(false), false)
+	if _, err := store.GetFileByID(int64(orphan.Id)); err == nil {
+		t.Error("orphaned file was kept")
+	}
+	for _, path := range append(strays, orphan.Path) {
+		if exists(path) {
+			t.Errorf("%s was kept", path)
+		}
+	}
+	_, rc, err := store.OpenFile(int64(kept.Id))
+	if err != nil {
+		t.Fatalf("file of an existing student was removed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "ravi's" {
+		t.Errorf("kept file holds %q", data)
+	}
+
+	if report := collector.Run(false); len(report.OrphanedFiles)+len(report.Blobs)+len(report.StrayFiles)+len(report.PartialUploads) != 0 {
+		t.Errorf("second sweep = %+v, want nothing left", report)
+	}
+}
+
+func TestRunSkipsRecentFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	temp, err := filestore.Save(strings.NewReader("in flight"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report := New(memory.New(), config.GC{MinAge: time.Hour}, config.Tus{Expiry: time.Hour}).Run(false)
+	if len(report.StrayFiles) != 0 || !exists(temp.Path) {
+		t.Errorf("an upload in progress was swept: %+v", report)
+	}
+}
+
+func TestRunUploads(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := memory.New()
+	partial, err := store.CreateUpload(0, "partial.txt", "text/plain", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	completed, err := store.CreateUpload(0, "done.txt", "text/plain", 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed, err = store.WriteUploadChunk(completed.Id, 0, strings.NewReader("hello")); err != nil || !completed.Complete() {
+		t.Fatalf("WriteUploadChunk = %+v, %v", completed, err)
+	}
+
+	// A negative expiry makes every upload expired
+	collector := New(store, config.GC{}, config.Tus{Expiry: -time.Hour})
+	report := collector.Run(false)
+	if !slices.Equal(report.PartialUploads, []string{partial.Id}) {
+		t.Errorf("PartialUploads = %v, want only %s", report.PartialUploads, partial.Id)
+	}
+	if len(report.Errors) != 0 {
+		t.Errorf("Errors = %v", report.Errors)
+	}
+	if _, err := store.GetUpload(completed.Id); err == nil {
+		t.Error("expired record of a completed upload was kept")
+	}
+	if _, rc, err := store.OpenFile(int64(completed.FileId)); err != nil {
+		t.Errorf("file of a completed upload was removed: %v", err)
+	} else {
+		rc.Close()
+	}
+}
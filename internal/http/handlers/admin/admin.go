@@ -0,0 +1,105 @@
+package admin
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/gc"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/cache"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+// GCReport returns the report of the last garbage collection sweep.
+func GCReport(collector *gc.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report, ok := collector.Last()
+		if !ok {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no garbage collection has run yet")))
+			return
+		}
+		response.WriteJson(w, http.StatusOK, report)
+	}
+}
+
+// RunGC sweeps immediately; pass ?dry_run=true to only see what would go.
+func RunGC(collector *gc.Collector) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dryRun := false
+		if value := r.URL.Query().Get("dry_run"); value != "" {
+			var err error
+			dryRun, err = strconv.ParseBool(value)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid dry_run")))
+				return
+			}
+		}
+		response.WriteJson(w, http.StatusOK, collector.Run(dryRun))
+	}
+}
+
+// pingTimeout keeps a hung database from hanging the probe with it.
+const pingTimeout = 2 * time.Second
+
+type poolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+// Database reports whether the storage backend answers and, for SQL
+// backends, the state of the connection pool. It responds 503 when the
+// database cannot be reached, so it can serve as a health probe.
+func Database(store storage.Storage, driver string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		data := make(map[string]any)
+		data["driver"] = driver
+		data["status"] = "up"
+		status := http.StatusOK
+		if pinger, ok := storage.As[storage.Pinger](store); ok {
+			ctx, cancel := context.WithTimeout(r.Context(), pingTimeout)
+			defer cancel()
+			if err := pinger.Ping(ctx); err != nil {
+				data["status"] = "down"
+				data["error"] = err.Error()
+				status = http.StatusServiceUnavailable
+			}
+		}
+		if statser, ok := storage.As[storage.PoolStatser](store); ok {
+			stats := statser.PoolStats()
+			data["pool"] = poolStats{
+				MaxOpenConnections: stats.MaxOpenConnections,
+				OpenConnections:    stats.OpenConnections,
+				InUse:              stats.InUse,
+				Idle:               stats.Idle,
+				WaitCount:          stats.WaitCount,
+				WaitDuration:       stats.WaitDuration.String(),
+				MaxIdleClosed:      stats.MaxIdleClosed,
+				MaxIdleTimeClosed:  stats.MaxIdleTimeClosed,
+				MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+			}
+		}
+		response.WriteJson(w, status, data)
+	}
+}
+
+// CacheStats reports the hit and miss counters of the student cache.
+func CacheStats(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		c, ok := storage.As[*cache.Cache](store)
+		if !ok {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("the student cache is not enabled")))
+			return
+		}
+		response.WriteJson(w, http.StatusOK, c.Stats())
+	}
+}
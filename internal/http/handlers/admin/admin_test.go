@@ -0,0 +1,60 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+	"github.com/surajNirala/student-api/internal/storage/sqlite"
+)
+
+type databaseStatus struct {
+	Driver string     `json:"driver"`
+	Status string     `json:"status"`
+	Error  string     `json:"error"`
+	Pool   *poolStats `json:"pool"`
+}
+
+func getDatabase(t *testing.T, store storage.Storage, driver string) (int, databaseStatus) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	Database(store, driver)(w, httptest.NewRequest(http.MethodGet, "/api/admin/database", nil))
+	var status databaseStatus
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+	return w.Code, status
+}
+
+func TestDatabase(t *testing.T) {
+	code, status := getDatabase(t, memory.New(), "memory")
+	if code != http.StatusOK || status.Driver != "memory" || status.Status != "up" || status.Pool != nil {
+		t.Errorf("memory: %d %+v", code, status)
+	}
+
+	cfg := &config.Config{
+		StoragePath: filepath.Join(t.TempDir(), "test.db"),
+		Storage:     config.Storage{Pool: config.Pool{MaxOpenConns: 4}},
+	}
+	db, err := sqlite.New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The pool is found through the retrying decorator
+	store := storage.WithRetry(db, config.Retry{Attempts: 3})
+	code, status = getDatabase(t, store, "sqlite")
+	if code != http.StatusOK || status.Status != "up" || status.Pool == nil || status.Pool.MaxOpenConnections != 4 {
+		t.Errorf("sqlite: %d %+v", code, status)
+	}
+
+	db.Db.Close()
+	code, status = getDatabase(t, store, "sqlite")
+	if code != http.StatusServiceUnavailable || status.Status != "down" || status.Error == "" {
+		t.Errorf("closed sqlite: %d %+v", code, status)
+	}
+}
@@ -0,0 +1,170 @@
+package admin
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+// SubscriptionRequest is the body of CreateSubscription.
+type SubscriptionRequest struct {
+	URL string `json:"url"`
+	// Events limits the subscription to these event types; empty means all.
+	Events []string `json:"events"`
+	// Secret signs deliveries; one is generated when it is left out.
+	Secret string `json:"secret"`
+}
+
+// Subscriptions lists the webhook subscriptions, without their secrets.
+func Subscriptions(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		list, err := store.Subscriptions()
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		if list == nil {
+			list = []models.Subscription{}
+		}
+		response.WriteJson(w, http.StatusOK, list)
+	}
+}
+
+// CreateSubscription registers a webhook. The secret is only ever shown
+// in this response.
+func CreateSubscription(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		var req SubscriptionRequest
+		err := json.NewDecoder(r.Body).Decode(&req)
+		if errors.Is(err, io.EOF) {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("empty body")))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		target, err := url.Parse(req.URL)
+		if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("url must be an absolute http or https URL")))
+			return
+		}
+		for _, event := range req.Events {
+			if !slices.Contains(storage.EventTypes, event) {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("unknown event type %q", event)))
+				return
+			}
+		}
+		if req.Secret == "" {
+			b := make([]byte, 32)
+			rand.Read(b)
+			req.Secret = hex.EncodeToString(b)
+		}
+		sub, err := store.CreateSubscription(req.URL, req.Secret, req.Events)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+
+		data := make(map[string]any)
+		data["Success"] = "OK"
+		data["Code"] = 201
+		data["subscription"] = sub
+		data["secret"] = sub.Secret
+		response.WriteJson(w, http.StatusCreated, data)
+	}
+}
+
+func DeleteSubscription(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		err = store.DeleteSubscription(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no subscription found with id %d", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// Deliveries lists the most recent webhook deliveries; ?status=dead shows
+// the ones that gave up, and ?limit= caps the list at up to 1000.
+func Deliveries(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		status := r.URL.Query().Get("status")
+		if status != "" && status != models.DeliveryPending && status != models.DeliveryDelivered && status != models.DeliveryDead {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid status")))
+			return
+		}
+		limit := 100
+		if value := r.URL.Query().Get("limit"); value != "" {
+			var err error
+			limit, err = strconv.Atoi(value)
+			if err != nil || limit < 1 || limit > 1000 {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid limit")))
+				return
+			}
+		}
+		list, err := store.Deliveries(status, limit)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		if list == nil {
+			list = []models.Delivery{}
+		}
+		response.WriteJson(w, http.StatusOK, list)
+	}
+}
+
+// ReplayDelivery sends a delivery again on the next dispatcher run,
+// whatever became of it before.
+func ReplayDelivery(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		err = store.ReplayDelivery(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no delivery found with id %d", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+
+		data := make(map[string]any)
+		data["Success"] = "OK"
+		data["Code"] = 202
+		data["message"] = fmt.Sprintf("delivery %d will be sent again", id)
+		response.WriteJson(w, http.StatusAccepted, data)
+	}
+}
@@ -0,0 +1,198 @@
+// Package batch runs several API requests sent in one round trip.
+package batch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+const BasePath = "/api/batch"
+
+// unbatchable lists paths a batch cannot contain: itself, and the event
+// stream, whose response never ends.
+var unbatchable = []string{BasePath, "/api/students/events"}
+
+// Request is one sub-request. Body, any JSON value, is sent as the body
+// of the sub-request.
+type Request struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// Response is the outcome of one sub-request. Body holds JSON responses
+// as they are and anything else as a string.
+type Response struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
+
+// errAborted stops an atomic batch at the first sub-request that fails.
+var errAborted = errors.New("batch aborted")
+
+// Handle runs an array of sub-requests through handler one after another
+// and responds with their responses in the same order. With ?atomic=true
+// all their storage changes share one transaction: the batch stops at the
+// first sub-request answered with a 4xx or 5xx status, responds with that
+// status and the responses so far, and none of the changes are kept.
+// Backends without transactions refuse atomic batches with 501.
+func Handle(handler http.Handler, store storage.Storage, cfg config.Batch) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic := false
+		if value := r.URL.Query().Get("atomic"); value != "" {
+			var err error
+			atomic, err = strconv.ParseBool(value)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid atomic")))
+				return
+			}
+		}
+		var requests []Request
+		err := json.NewDecoder(http.MaxBytesReader(w, r.Body, cfg.MaxBodySize)).Decode(&requests)
+		if errors.Is(err, io.EOF) {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("empty body")))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		if len(requests) == 0 || len(requests) > cfg.MaxRequests {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("a batch holds between 1 and %d requests", cfg.MaxRequests)))
+			return
+		}
+		for i, req := range requests {
+			if err := check(req); err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("request %d: %w", i, err)))
+				return
+			}
+		}
+
+		if !atomic {
+			responses := make([]Response, 0, len(requests))
+			for _, req := range requests {
+				responses = append(responses, dispatch(r.Context(), handler, r, req))
+			}
+			response.WriteJson(w, http.StatusOK, responses)
+			return
+		}
+
+		transactor, ok := storage.As[storage.Transactor](store)
+		if !ok {
+			response.WriteJson(w, http.StatusNotImplemented, response.GenerateError(fmt.Errorf("atomic batches are not supported by this storage backend")))
+			return
+		}
+		var responses []Response
+		err = transactor.InTx(r.Context(), func(ctx context.Context) error {
+			for _, req := range requests {
+				res := dispatch(ctx, handler, r, req)
+				responses = append(responses, res)
+				if res.Status >= http.StatusBadRequest {
+					return errAborted
+				}
+			}
+			return nil
+		})
+		if errors.Is(err, errAborted) {
+			response.WriteJson(w, responses[len(responses)-1].Status, responses)
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		response.WriteJson(w, http.StatusOK, responses)
+	}
+}
+
+func check(req Request) error {
+	if req.Method == "" {
+		return fmt.Errorf("method is required")
+	}
+	if !strings.HasPrefix(req.Path, "/") {
+		return fmt.Errorf("path must start with /")
+	}
+	path, _, _ := strings.Cut(req.Path, "?")
+	if slices.Contains(unbatchable, path) {
+		return fmt.Errorf("%s cannot be batched", path)
+	}
+	return nil
+}
+
+// dispatch runs req through handler on behalf of parent, the batch request.
+func dispatch(ctx context.Context, handler http.Handler, parent *http.Request, req Request) Response {
+	var body io.Reader = http.NoBody
+	if len(req.Body) > 0 && string(req.Body) != "null" {
+		body = bytes.NewReader(req.Body)
+	}
+	sub, err := http.NewRequestWithContext(ctx, strings.ToUpper(req.Method), req.Path, body)
+	if err != nil {
+		data, _ := json.Marshal(response.GenerateError(err))
+		return Response{Status: http.StatusBadRequest, Body: data}
+	}
+	for name, value := range req.Headers {
+		sub.Header.Set(name, value)
+	}
+	if body != http.NoBody && sub.Header.Get("Content-Type") == "" {
+		sub.Header.Set("Content-Type", "application/json")
+	}
+	sub.Host = parent.Host
+	sub.RemoteAddr = parent.RemoteAddr
+	sub.TLS = parent.TLS
+
+	rec := &recorder{header: make(http.Header)}
+	handler.ServeHTTP(rec, sub)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	res := Response{Status: rec.status, Headers: make(map[string]string, len(rec.header))}
+	for name, values := range rec.header {
+		res.Headers[name] = strings.Join(values, ", ")
+	}
+	if rec.body.Len() > 0 {
+		if strings.Contains(rec.header.Get("Content-Type"), "json") && json.Valid(rec.body.Bytes()) {
+			res.Body = bytes.TrimSpace(rec.body.Bytes())
+		} else {
+			res.Body, _ = json.Marshal(rec.body.String())
+		}
+	}
+	return res
+}
+
+// recorder keeps a sub-response in memory.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header {
+	return rec.header
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec.body.Write(b)
+}
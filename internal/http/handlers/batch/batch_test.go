@@ -0,0 +1,188 @@
+package batch
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/http/handlers/file"
+	"github.com/surajNirala/student-api/internal/http/handlers/student"
+	"github.com/surajNirala/student-api/internal/http/handlers/tus"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+	"github.com/surajNirala/student-api/internal/storage/sqlite"
+)
+
+var testConfig = config.Batch{MaxRequests: 5, MaxBodySize: 1 << 20}
+
+func newRouter(store storage.Storage) *http.ServeMux {
+	router := http.NewServeMux()
+	router.HandleFunc("POST "+BasePath, Handle(router, store, testConfig))
+	router.HandleFunc("GET /api/students", student.List(store))
+	router.HandleFunc("POST /api/students", student.Create(store))
+	router.HandleFunc("GET /api/students/{id}", student.GetByID(store))
+	router.HandleFunc("GET /api/files/{id}", file.Download(store))
+	router.HandleFunc("DELETE /api/files/{id}", file.Delete(store))
+	router.HandleFunc("DELETE "+tus.BasePath+"/{id}", tus.Delete(store))
+	router.HandleFunc("GET /text", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("hello " + r.URL.Query().Get("name")))
+	})
+	return router
+}
+
+func send(t *testing.T, router http.Handler, query string, body string) (int, []Response) {
+	t.Helper()
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, BasePath+query, strings.NewReader(body)))
+	var responses []Response
+	if w.Code == http.StatusOK || strings.HasPrefix(w.Body.String(), "[") {
+		if err := json.Unmarshal(w.Body.Bytes(), &responses); err != nil {
+			t.Fatalf("decoding %q: %v", w.Body, err)
+		}
+	}
+	return w.Code, responses
+}
+
+func TestBatch(t *testing.T) {
+	router := newRouter(memory.New())
+	status, responses := send(t, router, "", `[
+		{"method": "POST", "path": "/api/students", "body": {"name": "Asha", "email": "asha@example.com", "age": 20}},
+		{"method": "GET", "path": "/api/students/1"},
+		{"method": "GET", "path": "/api/students/x"},
+		{"method": "GET", "path": "/text?name=batch"}
+	]`)
+	if status != http.StatusOK || len(responses) != 4 {
+		t.Fatalf("batch = %d with %d responses", status, len(responses))
+	}
+	wantStatus := []int{http.StatusCreated, http.StatusOK, http.StatusBadRequest, http.StatusOK}
+	for i, res := range responses {
+		if res.Status != wantStatus[i] {
+			t.Errorf("response %d: status %d, want %d", i, res.Status, wantStatus[i])
+		}
+	}
+	var got struct{ Name string }
+	if err := json.Unmarshal(responses[1].Body, &got); err != nil || got.Name != "Asha" {
+		t.Errorf("response 1 body %s", responses[1].Body)
+	}
+	if string(responses[3].Body) != `"hello batch"` {
+		t.Errorf("text body = %s", responses[3].Body)
+	}
+}
+
+func TestBatchRefused(t *testing.T) {
+	router := newRouter(memory.New())
+	for _, body := range []string{
+		``,
+		`[]`,
+		`[{"method": "GET", "path": "/api/batch"}]`,
+		`[{"method": "GET", "path": "/api/students/events"}]`,
+		`[{"method": "GET", "path": "api/students"}]`,
+		`[{"path": "/api/students"}]`,
+		`[{"method": "GET", "path": "/text"}, {"method": "GET", "path": "/text"}, {"method": "GET", "path": "/text"},
+		  {"method": "GET", "path": "/text"}, {"method": "GET", "path": "/text"}, {"method": "GET", "path": "/text"}]`,
+	} {
+		if status, _ := send(t, router, "", body); status != http.StatusBadRequest {
+			t.Errorf("batch %s: status %d, want 400", body, status)
+		}
+	}
+	if status, _ := send(t, router, "?atomic=true", `[{"method": "GET", "path": "/text"}]`); status != http.StatusNotImplemented {
+		t.Errorf("atomic batch on memory storage: status %d, want 501", status)
+	}
+}
+
+func TestAtomicBatch(t *testing.T) {
+	s, err := sqlite.New(&config.Config{StoragePath: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Db.Close() })
+	router := newRouter(s)
+
+	status, responses := send(t, router, "?atomic=true", `[
+		{"method": "POST", "path": "/api/students", "body": {"name": "Asha", "email": "asha@example.com", "age": 20}},
+		{"method": "POST", "path": "/api/students", "body": {"name": "Ravi"}},
+		{"method": "GET", "path": "/text"}
+	]`)
+	if status != http.StatusBadRequest || len(responses) != 2 {
+		t.Fatalf("failing atomic batch = %d with %d responses, want 400 with 2", status, len(responses))
+	}
+	if list, err := s.StudentList(); err != nil || len(list) != 0 {
+		t.Errorf("students after a failed atomic batch: %+v, %v", list, err)
+	}
+
+	status, responses = send(t, router, "?atomic=true", `[
+		{"method": "POST", "path": "/api/students", "body": {"name": "Asha", "email": "asha@example.com", "age": 20}},
+		{"method": "GET", "path": "/api/students"}
+	]`)
+	if status != http.StatusOK || len(responses) != 2 {
+		t.Fatalf("atomic batch = %d with %d responses", status, len(responses))
+	}
+	// The list read inside the transaction sees the student created in it
+	var list []struct{ Name string }
+	if err := json.Unmarshal(responses[1].Body, &list); err != nil || len(list) != 1 {
+		t.Errorf("list inside the batch = %s", responses[1].Body)
+	}
+	if list, err := s.StudentList(); err != nil || len(list) != 1 {
+		t.Errorf("students after an atomic batch: %+v, %v", list, err)
+	}
+}
+
+func TestAtomicBatchKeepsDeletedFiles(t *testing.T) {
+	t.Chdir(t.TempDir())
+	s, err := sqlite.New(&config.Config{StoragePath: "test.db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Db.Close() })
+	router := newRouter(s)
+
+	stored, err := s.StudentLargeFileUpload(0, "notes.txt", "text/plain", strings.NewReader("notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := s.CreateUpload(0, "big.bin", "application/octet-stream", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload, err = s.WriteUploadChunk(upload.Id, 0, strings.NewReader("01234")); err != nil {
+		t.Fatal(err)
+	}
+	deletes := fmt.Sprintf(`
+		{"method": "DELETE", "path": "/api/files/%d"},
+		{"method": "DELETE", "path": "%s/%s", "headers": {"Tus-Resumable": "%s"}}`, stored.Id, tus.BasePath, upload.Id, tus.Version)
+
+	status, responses := send(t, router, "?atomic=true", `[`+deletes+`,
+		{"method": "POST", "path": "/api/students", "body": {"name": "Ravi"}}
+	]`)
+	if status != http.StatusBadRequest || len(responses) != 3 || responses[0].Status != http.StatusOK || responses[1].Status != http.StatusNoContent {
+		t.Fatalf("failing atomic batch = %d with %+v", status, responses)
+	}
+	// The rollback restored the records, and their bytes are still there
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/files/%d", stored.Id), nil))
+	if w.Code != http.StatusOK || w.Body.String() != "notes" {
+		t.Errorf("download after the rollback: status %d, body %q", w.Code, w.Body)
+	}
+	if _, err := s.GetUpload(upload.Id); err != nil {
+		t.Errorf("upload after the rollback: %v", err)
+	}
+	if _, err := os.Stat(upload.Path); err != nil {
+		t.Errorf("partial upload after the rollback: %v", err)
+	}
+
+	status, responses = send(t, router, "?atomic=true", `[`+deletes+`]`)
+	if status != http.StatusOK || len(responses) != 2 {
+		t.Fatalf("atomic batch = %d with %+v", status, responses)
+	}
+	for _, path := range []string{stored.Path, upload.Path} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s after the commit: %v", path, err)
+		}
+	}
+}
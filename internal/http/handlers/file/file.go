@@ -0,0 +1,210 @@
+package file
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/digest"
+	"github.com/surajNirala/student-api/internal/utils/response"
+	"github.com/surajNirala/student-api/internal/utils/signedurl"
+)
+
+func Download(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		download(storage, w, r, id)
+	}
+}
+
+// LinkRequest is the optional body of CreateLink. ExpiresIn is a Go
+// duration such as "15m"; the configured default applies when it is empty.
+type LinkRequest struct {
+	ExpiresIn string `json:"expires_in"`
+}
+
+// CreateLink issues a signed URL that downloads the file without any
+// credentials until it expires.
+func CreateLink(storage storage.Storage, signer *signedurl.Signer, cfg config.Signing) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		var req LinkRequest
+		err = json.NewDecoder(r.Body).Decode(&req)
+		if err != nil && !errors.Is(err, io.EOF) {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		ttl := cfg.DefaultTTL
+		if req.ExpiresIn != "" {
+			ttl, err = time.ParseDuration(req.ExpiresIn)
+			if err != nil || ttl <= 0 {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid expires_in")))
+				return
+			}
+		}
+		if ttl > cfg.MaxTTL {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("expires_in may not exceed %s", cfg.MaxTTL)))
+			return
+		}
+
+		file, err := storage.GetFileByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no file found with id %d", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		if status, err := checkOwner(storage, file); err != nil {
+			response.WriteJson(w, status, response.GenerateError(err))
+			return
+		}
+		expires := time.Now().Add(ttl)
+		query, err := signer.Sign(id, expires)
+		if errors.Is(err, signedurl.ErrNotConfigured) {
+			response.WriteJson(w, http.StatusNotImplemented, response.GenerateError(err))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+
+		baseURL := cfg.BaseURL
+		if baseURL == "" {
+			scheme := "http"
+			if r.TLS != nil {
+				scheme = "https"
+			}
+			baseURL = scheme + "://" + r.Host
+		}
+		data := make(map[string]any)
+		data["Success"] = "OK"
+		data["Code"] = 201
+		data["url"] = fmt.Sprintf("%s/api/files/signed/%d?%s", strings.TrimSuffix(baseURL, "/"), id, query.Encode())
+		data["expires_at"] = expires.UTC().Truncate(time.Second)
+		response.WriteJson(w, http.StatusCreated, data)
+	}
+}
+
+// SignedDownload serves a file to anyone holding a valid signed link.
+func SignedDownload(storage storage.Storage, signer *signedurl.Signer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		if err := signer.Verify(id, r.URL.Query(), time.Now()); err != nil {
+			response.WriteJson(w, http.StatusForbidden, response.GenerateError(err))
+			return
+		}
+		download(storage, w, r, id)
+	}
+}
+
+func download(storage storage.Storage, w http.ResponseWriter, r *http.Request, id int64) {
+	file, content, err := storage.OpenFile(id)
+	if errors.Is(err, sql.ErrNoRows) || errors.Is(err, fs.ErrNotExist) {
+		response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no file found with id %d", id)))
+		return
+	}
+	if err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+		return
+	}
+	defer content.Close()
+
+	if status, err := checkOwner(storage, file); err != nil {
+		response.WriteJson(w, status, response.GenerateError(err))
+		return
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.Name}))
+	Serve(w, r, file, content)
+}
+
+func Delete(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		file, err := storage.GetFileByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no file found with id %d", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		if status, err := checkOwner(storage, file); err != nil {
+			response.WriteJson(w, status, response.GenerateError(err))
+			return
+		}
+		message, err := storage.DeleteFileByID(id)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		response.WriteJson(w, http.StatusOK, message)
+	}
+}
+
+// checkOwner makes a student's files reachable only while the student is.
+func checkOwner(storage storage.Storage, file models.File) (int, error) {
+	if file.StudentId == 0 {
+		return http.StatusOK, nil
+	}
+	_, err := storage.GetStudentByID(file.StudentId)
+	if errors.Is(err, sql.ErrNoRows) {
+		return http.StatusNotFound, fmt.Errorf("no file found with id %d", file.Id)
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// Serve streams content with validators set so http.ServeContent can
+// answer Range, If-Range, If-None-Match and If-Modified-Since requests.
+func Serve(w http.ResponseWriter, r *http.Request, file models.File, content io.ReadSeeker) {
+	if file.ContentType != "" {
+		w.Header().Set("Content-Type", file.ContentType)
+	}
+	w.Header().Set("ETag", `"`+file.SHA256+`"`)
+	if sum, err := hex.DecodeString(file.SHA256); err == nil {
+		w.Header().Set("Repr-Digest", digest.Header(sum))
+	}
+	http.ServeContent(w, r, file.Name, file.UpdatedAt, content)
+}
@@ -0,0 +1,199 @@
+package file
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+	"github.com/surajNirala/student-api/internal/utils/signedurl"
+)
+
+const content = "0123456789abcdef"
+
+func newStore(t *testing.T) (*memory.Memory, models.File) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	store := memory.New()
+	file, err := store.StudentLargeFileUpload(0, "digits.txt", "text/plain", strings.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, file
+}
+
+func get(handler http.HandlerFunc, id uint64, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, "/api/files/"+strconv.FormatUint(id, 10), nil)
+	r.SetPathValue("id", strconv.FormatUint(id, 10))
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestDownload(t *testing.T) {
+	store, file := newStore(t)
+	handler := Download(store)
+	etag := `"` + file.SHA256 + `"`
+
+	tests := []struct {
+		name   string
+		header http.Header
+		status int
+		body   string
+	}{
+		{"whole file", nil, http.StatusOK, content},
+		{"range", http.Header{"Range": {"bytes=2-5"}}, http.StatusPartialContent, "2345"},
+		{"suffix range", http.Header{"Range": {"bytes=-3"}}, http.StatusPartialContent, "def"},
+		{"unsatisfiable range", http.Header{"Range": {"bytes=100-"}}, http.StatusRequestedRangeNotSatisfiable, ""},
+		{"if-none-match", http.Header{"If-None-Match": {etag}}, http.StatusNotModified, ""},
+		{"if-none-match changed", http.Header{"If-None-Match": {`"other"`}}, http.StatusOK, content},
+		{"if-range current", http.Header{"Range": {"bytes=0-1"}, "If-Range": {etag}}, http.StatusPartialContent, "01"},
+		{"if-range stale", http.Header{"Range": {"bytes=0-1"}, "If-Range": {`"other"`}}, http.StatusOK, content},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := get(handler, file.Id, tt.header)
+			if w.Code != tt.status {
+				t.Fatalf("status %d, want %d", w.Code, tt.status)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body %q, want %q", w.Body, tt.body)
+			}
+			if tt.status == http.StatusOK || tt.status == http.StatusPartialContent {
+				if w.Header().Get("ETag") != etag || w.Header().Get("Accept-Ranges") != "bytes" {
+					t.Errorf("headers %v", w.Header())
+				}
+			}
+		})
+	}
+
+	if w := get(handler, file.Id, nil); !strings.Contains(w.Header().Get("Content-Disposition"), `filename=digits.txt`) {
+		t.Errorf("Content-Disposition = %q", w.Header().Get("Content-Disposition"))
+	}
+	if w := get(handler, 999, nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing file: status %d, want 404", w.Code)
+	}
+}
+
+func TestDownloadOfDeletedStudent(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := memory.New()
+	studentID, err := store.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := store.StudentLargeFileUpload(studentID, "notes.txt", "text/plain", strings.NewReader("notes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := get(Download(store), file.Id, nil); w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	if _, err := store.DeleteStudentByID(studentID); err != nil {
+		t.Fatal(err)
+	}
+	if w := get(Download(store), file.Id, nil); w.Code != http.StatusNotFound {
+		t.Errorf("file of a deleted student: status %d, want 404", w.Code)
+	}
+}
+
+var testSigning = config.Signing{
+	ActiveKey:  "k1",
+	Keys:       map[string]string{"k1": "secret"},
+	DefaultTTL: time.Hour,
+	MaxTTL:     24 * time.Hour,
+}
+
+func createLink(handler http.HandlerFunc, id uint64, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "http://api.example.com/api/files/"+strconv.FormatUint(id, 10)+"/links", strings.NewReader(body))
+	r.SetPathValue("id", strconv.FormatUint(id, 10))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func getSigned(handler http.HandlerFunc, link string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, link, nil)
+	r.SetPathValue("id", strings.TrimPrefix(r.URL.Path, "/api/files/signed/"))
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestSignedLinks(t *testing.T) {
+	store, file := newStore(t)
+	signer := signedurl.New(testSigning)
+
+	w := createLink(CreateLink(store, signer, testSigning), file.Id, `{"expires_in":"15m"}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("CreateLink: status %d: %s", w.Code, w.Body)
+	}
+	var link struct {
+		URL       string    `json:"url"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &link); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(link.URL, "http://api.example.com/api/files/signed/") {
+		t.Errorf("url = %q", link.URL)
+	}
+	if until := time.Until(link.ExpiresAt); until <= 14*time.Minute || until > 15*time.Minute {
+		t.Errorf("expires_at = %v, want in 15 minutes", link.ExpiresAt)
+	}
+
+	download := SignedDownload(store, signer)
+	if w := getSigned(download, link.URL); w.Code != http.StatusOK || w.Body.String() != content {
+		t.Errorf("signed download: status %d, body %q", w.Code, w.Body)
+	}
+	u, _ := url.Parse(link.URL)
+	query := u.Query()
+	query.Set("expires", strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10))
+	u.RawQuery = query.Encode()
+	if w := getSigned(download, u.String()); w.Code != http.StatusForbidden {
+		t.Errorf("extended link: status %d, want 403", w.Code)
+	}
+	if w := getSigned(download, "/api/files/signed/"+strconv.FormatUint(file.Id, 10)); w.Code != http.StatusForbidden {
+		t.Errorf("unsigned link: status %d, want 403", w.Code)
+	}
+	// Rotating the key away revokes the link
+	rotated := testSigning
+	rotated.ActiveKey, rotated.Keys = "k2", map[string]string{"k2": "other secret"}
+	if w := getSigned(SignedDownload(store, signedurl.New(rotated)), link.URL); w.Code != http.StatusForbidden {
+		t.Errorf("revoked link: status %d, want 403", w.Code)
+	}
+}
+
+func TestCreateLinkRefused(t *testing.T) {
+	store, file := newStore(t)
+	handler := CreateLink(store, signedurl.New(testSigning), testSigning)
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		id      uint64
+		body    string
+		status  int
+	}{
+		{"missing file", handler, 999, "", http.StatusNotFound},
+		{"bad duration", handler, file.Id, `{"expires_in":"soon"}`, http.StatusBadRequest},
+		{"negative duration", handler, file.Id, `{"expires_in":"-1m"}`, http.StatusBadRequest},
+		{"over max", handler, file.Id, `{"expires_in":"25h"}`, http.StatusBadRequest},
+		{"not configured", CreateLink(store, signedurl.New(config.Signing{}), testSigning), file.Id, "", http.StatusNotImplemented},
+	}
+	for _, tt := range tests {
+		if w := createLink(tt.handler, tt.id, tt.body); w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+	}
+}
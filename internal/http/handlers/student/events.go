@@ -0,0 +1,130 @@
+package student
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/stream"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+// catchUpPage is how many events are read at a time when a client resumes
+// from further back than the broker keeps.
+const catchUpPage = 100
+
+// reconnectDelay is the retry time sent to clients, in milliseconds.
+const reconnectDelay = 3000
+
+// Events streams student changes as Server-Sent Events. A client resumes
+// after the id in its Last-Event-ID header, or in ?last_event_id= on the
+// first connection; without either it gets only new events.
+func Events(store storage.Storage, broker *stream.Broker, cfg config.Events) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		value := r.Header.Get("Last-Event-ID")
+		if value == "" {
+			value = r.URL.Query().Get("last_event_id")
+		}
+		var after uint64
+		var err error
+		if value != "" {
+			after, err = strconv.ParseUint(value, 10, 64)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid Last-Event-ID")))
+				return
+			}
+		} else {
+			after, err = broker.Last()
+			if err != nil {
+				response.WriteJson(w, http.StatusServiceUnavailable, response.GenerateError(err))
+				return
+			}
+		}
+
+		rc := http.NewResponseController(w)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		// Keep nginx from buffering the stream
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay)
+
+		var sub *stream.Subscription
+		var backlog []models.Event
+		for sub == nil {
+			var ok bool
+			sub, backlog, ok, err = broker.Subscribe(after)
+			if err != nil || ok {
+				break
+			}
+			var events []models.Event
+			events, err = store.EventsAfter(after, catchUpPage)
+			if err != nil {
+				slog.Error("Reading events failed", slog.String("error", err.Error()))
+				return
+			}
+			if len(events) == 0 {
+				// Storage, such as a lagging replica, has nothing newer; ending the
+				// stream would only bring the client back to the same id
+				sub, backlog, err = broker.Follow(after)
+				break
+			}
+			if writeEvents(w, events) != nil {
+				return
+			}
+			after = events[len(events)-1].Id
+		}
+		if err != nil {
+			if !errors.Is(err, stream.ErrClosed) {
+				slog.Error("Subscribing to events failed", slog.String("error", err.Error()))
+			}
+			return
+		}
+		defer sub.Close()
+		if writeEvents(w, backlog) != nil || rc.Flush() != nil {
+			return
+		}
+
+		heartbeat := time.NewTicker(cfg.Heartbeat)
+		defer heartbeat.Stop()
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event, open := <-sub.C:
+				if !open {
+					return
+				}
+				err = writeEvents(w, []models.Event{event})
+			case <-heartbeat.C:
+				_, err = fmt.Fprint(w, ": heartbeat\n\n")
+			}
+			if err == nil {
+				err = rc.Flush()
+			}
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvents(w http.ResponseWriter, events []models.Event) error {
+	for _, event := range events {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Id, event.Type, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
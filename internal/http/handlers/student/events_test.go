@@ -0,0 +1,79 @@
+package student
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+	"github.com/surajNirala/student-api/internal/stream"
+)
+
+// lagging reads no events, like a replica that has not caught up.
+type lagging struct {
+	storage.Storage
+}
+
+func (lagging) EventsAfter(id uint64, limit int) ([]models.Event, error) {
+	return nil, nil
+}
+
+func TestEventsFollowAfterEmptyCatchUp(t *testing.T) {
+	store := memory.New()
+	for range 3 {
+		if _, err := store.CreateStudent("Asha", "asha@example.com", 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := config.Events{PollInterval: 10 * time.Millisecond, BufferSize: 10, Heartbeat: time.Hour}
+	// The broker starts at event 3 with nothing buffered
+	broker := stream.New(store, cfg)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go broker.Start(ctx)
+	server := httptest.NewServer(Events(lagging{store}, broker, cfg))
+	defer server.Close()
+
+	r, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Last-Event-ID", "1")
+	resp, err := http.DefaultClient.Do(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	lines := make(chan string, 16)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+		close(lines)
+	}()
+	if _, err := store.CreateStudent("Ravi", "ravi@example.com", 21); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case line, open := <-lines:
+			if !open {
+				t.Fatal("stream ended instead of following new events")
+			}
+			if line == "id: 4" {
+				return
+			}
+		case <-deadline:
+			t.Fatal("event 4 was not streamed")
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package student
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"io/fs"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/http/handlers/file"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/response"
+	"github.com/surajNirala/student-api/internal/utils/thumbnail"
+)
+
+// Edge lengths of the square thumbnails generated for every photo.
+const (
+	mediumSize = 512
+	thumbSize  = 128
+)
+
+func UploadPhoto(storage storage.Storage, cfg config.Photo, quotaCfg config.Quota) http.HandlerFunc {
+	policy := config.UploadPolicy{MaxSize: cfg.MaxSize, AllowedTypes: []string{"image/jpeg", "image/png"}}
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		_, err = storage.GetStudentByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		upload, status, err := receiveUpload(w, r, policy)
+		if err != nil {
+			response.WriteJson(w, status, response.GenerateError(err))
+			return
+		}
+		defer upload.file.Close()
+
+		// Check the dimensions from the header before decoding the whole image
+		imgCfg, _, err := image.DecodeConfig(upload.file)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid image: %w", err)))
+			return
+		}
+		if imgCfg.Width < cfg.MinDimension || imgCfg.Height < cfg.MinDimension || imgCfg.Width > cfg.MaxDimension || imgCfg.Height > cfg.MaxDimension {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("photo must be between %d and %d pixels on each side", cfg.MinDimension, cfg.MaxDimension)))
+			return
+		}
+		if _, err := upload.file.Seek(0, io.SeekStart); err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		img, format, err := image.Decode(upload.file)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid image: %w", err)))
+			return
+		}
+		if _, err := upload.file.Seek(0, io.SeekStart); err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+
+		// The renditions are stored too, so their space is reserved before the original's
+		medium, err := newRendition(upload.header.Filename, img, format, mediumSize)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		thumb, err := newRendition(upload.header.Filename, img, format, thumbSize)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		reader, status, err := uploadReader(storage, quotaCfg, id, upload, int64(len(medium.data)+len(thumb.data)))
+		if err != nil {
+			response.WriteJson(w, status, response.GenerateError(err))
+			return
+		}
+		original, err := storage.StudentLargeFileUpload(id, upload.header.Filename, upload.contentType, reader)
+		if err != nil {
+			response.WriteJson(w, uploadErrorStatus(err), response.GenerateError(err))
+			return
+		}
+		photo := models.Photo{StudentId: id, OriginalFileId: original.Id}
+		mediumFile, err := storage.StudentFileUpload10MB(id, medium.name, medium.contentType, medium.data)
+		if err == nil {
+			photo.MediumFileId = mediumFile.Id
+			var thumbFile models.File
+			thumbFile, err = storage.StudentFileUpload10MB(id, thumb.name, thumb.contentType, thumb.data)
+			photo.ThumbFileId = thumbFile.Id
+		}
+		if err == nil {
+			err = storage.SetStudentPhoto(id, photo)
+		}
+		if err != nil {
+			for _, fileID := range []uint64{photo.OriginalFileId, photo.MediumFileId, photo.ThumbFileId} {
+				if fileID != 0 {
+					storage.DeleteFileByID(int64(fileID))
+				}
+			}
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+
+		data := make(map[string]any)
+		data["Success"] = "OK"
+		data["Code"] = 200
+		data["photo_url"] = models.StudentPhotoURL(uint64(id))
+		response.WriteJson(w, http.StatusOK, data)
+	}
+}
+
+func GetPhoto(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		_, err = storage.GetStudentByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		photo, err := storage.GetStudentPhoto(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("student with id %d has no photo", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+
+		var fileID uint64
+		switch r.URL.Query().Get("size") {
+		case "", "original":
+			fileID = photo.OriginalFileId
+		case "medium":
+			fileID = photo.MediumFileId
+		case "thumb":
+			fileID = photo.ThumbFileId
+		default:
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("size must be one of thumb, medium or original")))
+			return
+		}
+		f, content, err := storage.OpenFile(int64(fileID))
+		if errors.Is(err, sql.ErrNoRows) || errors.Is(err, fs.ErrNotExist) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("photo of student with id %d not found", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		defer content.Close()
+		w.Header().Set("Content-Disposition", "inline")
+		file.Serve(w, r, f, content)
+	}
+}
+
+// rendition is a resized copy of a photo, encoded and ready to store.
+type rendition struct {
+	name        string
+	contentType string
+	data        []byte
+}
+
+// newRendition encodes a size×size version of img in its original format.
+func newRendition(fileName string, img image.Image, format string, size int) (rendition, error) {
+	thumb := thumbnail.Square(img, size)
+	var buf bytes.Buffer
+	contentType := "image/jpeg"
+	var err error
+	if format == "png" {
+		contentType = "image/png"
+		err = png.Encode(&buf, thumb)
+	} else {
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 85})
+	}
+	if err != nil {
+		return rendition{}, err
+	}
+	name := fmt.Sprintf("%s_%d%s", fileName[:len(fileName)-len(filepath.Ext(fileName))], size, filepath.Ext(fileName))
+	return rendition{name: name, contentType: contentType, data: buf.Bytes()}, nil
+}
@@ -0,0 +1,185 @@
+package student
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+)
+
+var testPhoto = config.Photo{MaxSize: 5 << 20, MinDimension: 128, MaxDimension: 6000}
+
+// noisyPNG returns an image that compresses badly, so its renditions take
+// up real space.
+func noisyPNG(t *testing.T) []byte {
+	t.Helper()
+	rng := rand.New(rand.NewSource(1))
+	img := image.NewRGBA(image.Rect(0, 0, 600, 600))
+	for y := range 600 {
+		for x := range 600 {
+			img.Set(x, y, color.RGBA{uint8(rng.Intn(256)), uint8(rng.Intn(256)), uint8(rng.Intn(256)), 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func putPhoto(t *testing.T, handler http.HandlerFunc, data []byte) int {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	part, err := form.CreateFormFile("file", "photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	form.Close()
+	r := httptest.NewRequest(http.MethodPut, "/api/students/1/photo", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	r.SetPathValue("id", "1")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w.Code
+}
+
+func TestUploadPhotoQuota(t *testing.T) {
+	t.Chdir(t.TempDir())
+	photo := noisyPNG(t)
+
+	for _, tt := range []struct {
+		name  string
+		quota config.Quota
+		want  int
+	}{
+		{"no quota", config.Quota{}, http.StatusOK},
+		// The original fits, but not with its renditions
+		{"per student", config.Quota{PerStudent: int64(len(photo)) + 1}, http.StatusRequestEntityTooLarge},
+		{"total", config.Quota{Total: int64(len(photo)) + 1}, http.StatusRequestEntityTooLarge},
+		{"room for all", config.Quota{PerStudent: 3 * int64(len(photo))}, http.StatusOK},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.New()
+			if _, err := store.CreateStudent("Asha", "asha@example.com", 20); err != nil {
+				t.Fatal(err)
+			}
+			if status := putPhoto(t, UploadPhoto(store, testPhoto, tt.quota), photo); status != tt.want {
+				t.Fatalf("status %d, want %d", status, tt.want)
+			}
+			usage, err := store.StudentFileUsage(1)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.want != http.StatusOK && usage.Files != 0 {
+				t.Errorf("refused photo left %d files behind", usage.Files)
+			}
+			if limit := tt.quota.PerStudent; limit > 0 && usage.Bytes > limit {
+				t.Errorf("photo uses %d bytes, over the quota of %d", usage.Bytes, limit)
+			}
+		})
+	}
+}
+
+func TestPhoto(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := memory.New()
+	if _, err := store.CreateStudent("Asha", "asha@example.com", 20); err != nil {
+		t.Fatal(err)
+	}
+	upload := UploadPhoto(store, testPhoto, config.Quota{})
+	get := func(size string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/api/students/1/photo?size="+size, nil)
+		r.SetPathValue("id", "1")
+		w := httptest.NewRecorder()
+		GetPhoto(store)(w, r)
+		return w
+	}
+
+	if w := get(""); w.Code != http.StatusNotFound {
+		t.Errorf("GET before upload: status %d, want 404", w.Code)
+	}
+	for _, tt := range []struct {
+		name string
+		data []byte
+	}{
+		{"too small", encodePNG(t, 100, 600)},
+		{"too large", encodePNG(t, 600, 6001)},
+		{"not an image", []byte("%PDF-1.7\n")},
+	} {
+		if status := putPhoto(t, upload, tt.data); status == http.StatusOK {
+			t.Errorf("%s: status %d", tt.name, status)
+		}
+	}
+
+	if status := putPhoto(t, upload, encodePNG(t, 800, 600)); status != http.StatusOK {
+		t.Fatalf("upload: status %d", status)
+	}
+	student, err := store.GetStudentByID(1)
+	if err != nil || student.PhotoURL == "" {
+		t.Errorf("student = %+v, %v; want a photo_url", student, err)
+	}
+	for _, tt := range []struct {
+		size          string
+		width, height int
+	}{
+		{"", 800, 600},
+		{"original", 800, 600},
+		{"medium", mediumSize, mediumSize},
+		{"thumb", thumbSize, thumbSize},
+	} {
+		w := get(tt.size)
+		if w.Code != http.StatusOK || w.Header().Get("Content-Type") != "image/png" {
+			t.Errorf("size %q: status %d, Content-Type %q", tt.size, w.Code, w.Header().Get("Content-Type"))
+			continue
+		}
+		cfg, err := png.DecodeConfig(w.Body)
+		if err != nil || cfg.Width != tt.width || cfg.Height != tt.height {
+			t.Errorf("size %q: %dx%d, %v; want %dx%d", tt.size, cfg.Width, cfg.Height, err, tt.width, tt.height)
+		}
+	}
+	if w := get("huge"); w.Code != http.StatusBadRequest {
+		t.Errorf("unknown size: status %d, want 400", w.Code)
+	}
+
+	// Files removed behind the photo's back, such as by garbage collection
+	photo, err := store.GetStudentPhoto(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := store.GetFileByID(int64(photo.ThumbFileId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(thumb.Path); err != nil {
+		t.Fatal(err)
+	}
+	if w := get("thumb"); w.Code != http.StatusNotFound {
+		t.Errorf("thumb missing on disk: status %d, want 404", w.Code)
+	}
+	if _, err := store.DeleteFileByID(int64(photo.MediumFileId)); err != nil {
+		t.Fatal(err)
+	}
+	if w := get("medium"); w.Code != http.StatusNotFound {
+		t.Errorf("medium file deleted: status %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,103 @@
+package student
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+// getAsOf answers GET /api/students/{id}?as_of=... with the student as it
+// was at that time. A date without a time means the end of that day, UTC.
+func getAsOf(w http.ResponseWriter, r *http.Request, storage storage.Storage, id int64) {
+	at, err := parseAsOf(r.URL.Query().Get("as_of"))
+	if err != nil {
+		response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+		return
+	}
+	student, err := storage.StudentAsOf(id, at)
+	if errors.Is(err, sql.ErrNoRows) {
+		response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d as of %s", id, at.Format(time.RFC3339))))
+		return
+	}
+	if err != nil {
+		response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+		return
+	}
+	response.WriteJson(w, http.StatusOK, student)
+}
+
+func parseAsOf(value string) (time.Time, error) {
+	if at, err := time.Parse(time.RFC3339, value); err == nil {
+		return at, nil
+	}
+	if day, err := time.Parse(time.DateOnly, value); err == nil {
+		return day.Add(24*time.Hour - time.Nanosecond), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid as_of %q, want an RFC 3339 time or a date", value)
+}
+
+func Revisions(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		revisions, err := storage.StudentRevisions(id)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		if len(revisions) == 0 {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", id)))
+			return
+		}
+		response.WriteJson(w, http.StatusOK, revisions)
+	}
+}
+
+// Revert restores the values of an earlier revision. The revision it
+// creates is returned; nothing in between is removed.
+func Revert(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		id, err := strconv.ParseInt(r.PathValue("id"), 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		rev, err := strconv.Atoi(r.PathValue("rev"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		revision, err := store.RevertStudent(id, rev)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no revision %d found for student with id %d", rev, id)))
+			return
+		}
+		if errors.Is(err, storage.ErrDeletedRevision) {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+
+		data := make(map[string]any)
+		data["Success"] = "OK"
+		data["Code"] = 200
+		data["id"] = id
+		data["revision"] = revision
+		response.WriteJson(w, http.StatusOK, data)
+	}
+}
@@ -1,26 +1,35 @@
 package student
 
 import (
+	"database/sql"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 
+	"github.com/gabriel-vasile/mimetype"
 	"github.com/go-playground/validator/v10"
+	"github.com/surajNirala/student-api/internal/config"
 	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/quota"
 	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/digest"
+	"github.com/surajNirala/student-api/internal/utils/filetype"
 	"github.com/surajNirala/student-api/internal/utils/response"
 )
 
 func List(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
 		list, err := storage.StudentList()
 		// fmt.Println("list", list)
 		// slog.Info("err : ", err.Error())
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
 		}
 		response.WriteJson(w, http.StatusOK, list)
 	}
@@ -28,6 +37,7 @@ func List(storage storage.Storage) http.HandlerFunc {
 
 func Create(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
 		var student models.Student
 		err := json.NewDecoder(r.Body).Decode(&student)
 		if errors.Is(err, io.EOF) {
@@ -47,7 +57,8 @@ func Create(storage storage.Storage) http.HandlerFunc {
 		}
 		lastID, err := storage.CreateStudent(student.Name, student.Email, student.Age)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
 		}
 
 		data := make(map[string]any)
@@ -60,15 +71,24 @@ func Create(storage storage.Storage) http.HandlerFunc {
 
 func GetByID(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, err)
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		if r.URL.Query().Has("as_of") {
+			getAsOf(w, r, storage, id)
 			return
 		}
 		student, err := storage.GetStudentByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", id)))
+			return
+		}
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
 			return
 		}
 		response.WriteJson(w, http.StatusOK, student)
@@ -77,11 +97,12 @@ func GetByID(storage storage.Storage) http.HandlerFunc {
 
 func UpdateByID(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
 		var studentupdate models.Student
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, err)
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
 			return
 		}
 		err = json.NewDecoder(r.Body).Decode(&studentupdate)
@@ -100,14 +121,14 @@ func UpdateByID(storage storage.Storage) http.HandlerFunc {
 			response.WriteJson(w, http.StatusBadRequest, response.ValidationError(validatorErr))
 			return
 		}
-		_, err = storage.GetStudentByID(id)
-		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+		message, err := storage.UpdateStudentByID(studentupdate.Name, studentupdate.Email, studentupdate.Age, id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", id)))
 			return
 		}
-		message, err := storage.UpdateStudentByID(studentupdate.Name, studentupdate.Email, studentupdate.Age, id)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
 		}
 
 		data := make(map[string]any)
@@ -121,71 +142,274 @@ func UpdateByID(storage storage.Storage) http.HandlerFunc {
 
 func DeleteByID(storage storage.Storage) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
 		idStr := r.PathValue("id")
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, err)
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
 			return
 		}
 		student, err := storage.DeleteStudentByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", id)))
+			return
+		}
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
 			return
 		}
 		response.WriteJson(w, http.StatusOK, student)
 	}
 }
 
-func FileUpload10MB(storage storage.Storage) http.HandlerFunc {
+func FileUpload10MB(storage storage.Storage, policy config.UploadPolicy, quotaCfg config.Quota) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		file, header, err := r.FormFile("file")
+		storage := storage.WithContext(r.Context())
+		upload, status, err := receiveUpload(w, r, policy)
 		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			response.WriteJson(w, status, response.GenerateError(err))
 			return
 		}
-		defer file.Close()
+		defer upload.file.Close()
 
-		if header.Size > 10*1024*1024 { // 10 MB limit
-			fmt.Println("Bad Request: File size exceeds limit", header.Size)
-			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("file size exceeds limit")))
+		studentID, status, err := fileOwner(storage, r)
+		if err != nil {
+			response.WriteJson(w, status, response.GenerateError(err))
 			return
 		}
-		fileBytes, err := io.ReadAll(file)
+		reader, status, err := uploadReader(storage, quotaCfg, studentID, upload, 0)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			response.WriteJson(w, status, response.GenerateError(err))
 			return
 		}
-		result, err := storage.StudentFileUpload10MB(header.Filename, fileBytes)
+		fileBytes, err := io.ReadAll(reader)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			response.WriteJson(w, uploadErrorStatus(err), response.GenerateError(err))
+			return
+		}
+		result, err := storage.StudentFileUpload10MB(studentID, upload.header.Filename, upload.contentType, fileBytes)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
 			return
 		}
 
 		data := make(map[string]any)
-		data["Success"] = result
+		data["Success"] = fmt.Sprintf("File %s uploaded successfully", result.Name)
 		data["Code"] = 200
+		data["id"] = result.Id
+		data["sha256"] = result.SHA256
 		response.WriteJson(w, http.StatusOK, data)
 	}
 }
 
-func LargeFileUpload(storage storage.Storage) http.HandlerFunc {
+func LargeFileUpload(storage storage.Storage, policy config.UploadPolicy, quotaCfg config.Quota) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		file, header, err := r.FormFile("file")
+		storage := storage.WithContext(r.Context())
+		upload, status, err := receiveUpload(w, r, policy)
 		if err != nil {
-			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			response.WriteJson(w, status, response.GenerateError(err))
 			return
 		}
-		defer file.Close()
+		defer upload.file.Close()
 
-		result, err := storage.StudentLargeFileUpload(header.Filename, file)
+		studentID, status, err := fileOwner(storage, r)
+		if err != nil {
+			response.WriteJson(w, status, response.GenerateError(err))
+			return
+		}
+		reader, status, err := uploadReader(storage, quotaCfg, studentID, upload, 0)
+		if err != nil {
+			response.WriteJson(w, status, response.GenerateError(err))
+			return
+		}
+		result, err := storage.StudentLargeFileUpload(studentID, upload.header.Filename, upload.contentType, reader)
 		if err != nil {
-			response.WriteJson(w, http.StatusInternalServerError, err)
+			response.WriteJson(w, uploadErrorStatus(err), response.GenerateError(err))
 			return
 		}
 
 		data := make(map[string]any)
-		data["Success"] = result
+		data["Success"] = fmt.Sprintf("Large File %s uploaded successfully", result.Name)
 		data["Code"] = 200
+		data["id"] = result.Id
+		data["sha256"] = result.SHA256
 		response.WriteJson(w, http.StatusOK, data)
 	}
 }
+
+// multipartOverhead leaves room for boundaries and other form fields when
+// capping the request body at the policy's file size.
+const multipartOverhead = 1024 * 1024
+
+type receivedFile struct {
+	file        multipart.File
+	header      *multipart.FileHeader
+	contentType string
+	// digest is the SHA-256 the client announced in the part's
+	// Content-Digest or Digest header, if any.
+	digest []byte
+}
+
+// receiveUpload reads the "file" form field and checks it against policy.
+// Oversized and disallowed files are rejected before any of it is stored.
+func receiveUpload(w http.ResponseWriter, r *http.Request, policy config.UploadPolicy) (receivedFile, int, error) {
+	// Abort oversized bodies early instead of spooling them to disk first
+	r.Body = http.MaxBytesReader(w, r.Body, policy.MaxSize+multipartOverhead)
+	file, header, err := r.FormFile("file")
+	var maxBytesErr *http.MaxBytesError
+	if errors.As(err, &maxBytesErr) {
+		return receivedFile{}, http.StatusRequestEntityTooLarge, fmt.Errorf("file size exceeds limit of %d bytes", policy.MaxSize)
+	}
+	if err != nil {
+		return receivedFile{}, http.StatusBadRequest, err
+	}
+	if header.Size > policy.MaxSize {
+		file.Close()
+		return receivedFile{}, http.StatusRequestEntityTooLarge, fmt.Errorf("file size exceeds limit of %d bytes", policy.MaxSize)
+	}
+	want, err := digest.FromHeader(http.Header(header.Header))
+	if err != nil {
+		file.Close()
+		return receivedFile{}, http.StatusBadRequest, err
+	}
+
+	mime, err := mimetype.DetectReader(file)
+	if err == nil {
+		_, err = file.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		file.Close()
+		return receivedFile{}, http.StatusInternalServerError, err
+	}
+	if !filetype.Allowed(mime, policy.AllowedTypes) {
+		file.Close()
+		return receivedFile{}, http.StatusUnsupportedMediaType, fmt.Errorf("file type %s is not allowed", mime.String())
+	}
+	return receivedFile{file: file, header: header, contentType: mime.String(), digest: want}, http.StatusOK, nil
+}
+
+// uploadReader checks an upload against the owner's remaining quota and
+// returns the stream to store: verified against the client's digest and
+// cut off as soon as the quota runs out. reserved bytes, which will be
+// stored along with the upload, count against the quota as well.
+func uploadReader(storage storage.Storage, quotaCfg config.Quota, studentID int64, upload receivedFile, reserved int64) (io.Reader, int, error) {
+	remaining, err := quota.Remaining(storage, quotaCfg, studentID)
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if remaining != quota.Unlimited {
+		remaining = max(remaining-reserved, 0)
+	}
+	if upload.header.Size > remaining {
+		return nil, http.StatusRequestEntityTooLarge, quota.ErrExceeded
+	}
+	return quota.Limit(digest.Verify(upload.file, upload.digest), remaining), http.StatusOK, nil
+}
+
+// uploadErrorStatus maps an error from storing an upload to a status code.
+func uploadErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, digest.ErrMismatch):
+		return http.StatusBadRequest
+	case errors.Is(err, quota.ErrExceeded):
+		return http.StatusRequestEntityTooLarge
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+func FileUsage(storage storage.Storage, quotaCfg config.Quota) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		_, err = storage.GetStudentByID(id)
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", id)))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		usage, err := storage.StudentFileUsage(id)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		remaining, err := quota.Remaining(storage, quotaCfg, id)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+
+		data := make(map[string]any)
+		data["student_id"] = id
+		data["files"] = usage.Files
+		data["used_bytes"] = usage.Bytes
+		// A quota of 0 means unlimited, in which case nothing is remaining to report
+		data["quota_bytes"] = quotaCfg.PerStudent
+		if remaining != quota.Unlimited {
+			data["remaining_bytes"] = remaining
+		}
+		response.WriteJson(w, http.StatusOK, data)
+	}
+}
+
+// fileOwner reads the optional student_id form field of an upload. A file
+// attached to a student is only downloadable while that student exists.
+func fileOwner(storage storage.Storage, r *http.Request) (int64, int, error) {
+	idStr := r.FormValue("student_id")
+	if idStr == "" {
+		return 0, http.StatusOK, nil
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, http.StatusBadRequest, fmt.Errorf("invalid student_id")
+	}
+	_, err = storage.GetStudentByID(id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, http.StatusNotFound, fmt.Errorf("no student found with id %d", id)
+	}
+	if err != nil {
+		return 0, http.StatusInternalServerError, err
+	}
+	return id, http.StatusOK, nil
+}
+
+// History lists every recorded change of a student, oldest first. It is
+// still available after the student is deleted.
+func History(storage storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		storage := storage.WithContext(r.Context())
+		idStr := r.PathValue("id")
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		history, err := storage.StudentHistory(id)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		// Students created before the audit log existed have no entries yet
+		if len(history) == 0 {
+			_, err = storage.GetStudentByID(id)
+			if errors.Is(err, sql.ErrNoRows) {
+				response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", id)))
+				return
+			}
+			if err != nil {
+				response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+				return
+			}
+			history = []models.AuditEntry{}
+		}
+		response.WriteJson(w, http.StatusOK, history)
+	}
+}
@@ -0,0 +1,125 @@
+package student
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+	"github.com/surajNirala/student-api/internal/utils/digest"
+)
+
+// postFile sends content as the "file" field of a multipart upload, with
+// any extra part headers given as name/value pairs.
+func postFile(t *testing.T, handler http.HandlerFunc, name string, content []byte, partHeader ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename=%q`, name))
+	header.Set("Content-Type", "application/octet-stream")
+	for i := 0; i+1 < len(partHeader); i += 2 {
+		header.Set(partHeader[i], partHeader[i+1])
+	}
+	part, err := form.CreatePart(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(content)
+	form.Close()
+	r := httptest.NewRequest(http.MethodPost, "/api/students/files", &body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestUploadPolicy(t *testing.T) {
+	t.Chdir(t.TempDir())
+	pdf := []byte("%PDF-1.7\n%%EOF\n")
+	policy := config.UploadPolicy{MaxSize: 64, AllowedTypes: []string{"application/pdf", "image/*"}}
+
+	tests := []struct {
+		name    string
+		file    string
+		content []byte
+		status  int
+	}{
+		{"allowed", "cv.pdf", pdf, http.StatusOK},
+		// The extension is not trusted, only the content
+		{"disguised", "cv.pdf", []byte("#!/bin/sh\necho hi\n"), http.StatusUnsupportedMediaType},
+		{"too large", "cv.pdf", append(pdf, make([]byte, 64)...), http.StatusRequestEntityTooLarge},
+	}
+	handlers := map[string]func(*memory.Memory) http.HandlerFunc{
+		"FileUpload10MB": func(m *memory.Memory) http.HandlerFunc {
+			return FileUpload10MB(m, policy, config.Quota{})
+		},
+		"LargeFileUpload": func(m *memory.Memory) http.HandlerFunc {
+			return LargeFileUpload(m, policy, config.Quota{})
+		},
+	}
+	for handlerName, newHandler := range handlers {
+		for _, tt := range tests {
+			t.Run(handlerName+"/"+tt.name, func(t *testing.T) {
+				store := memory.New()
+				w := postFile(t, newHandler(store), tt.file, tt.content)
+				if w.Code != tt.status {
+					t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+				}
+				usage, err := store.TotalFileUsage()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if want := tt.status == http.StatusOK; (usage.Files == 1) != want {
+					t.Errorf("%d files stored after status %d", usage.Files, w.Code)
+				}
+			})
+		}
+	}
+}
+
+func TestUploadDigest(t *testing.T) {
+	t.Chdir(t.TempDir())
+	content := []byte("certificate of completion")
+	sum := sha256.Sum256(content)
+	other := sha256.Sum256([]byte("something else"))
+	policy := config.UploadPolicy{MaxSize: 1 << 20}
+
+	tests := []struct {
+		name   string
+		header []string
+		status int
+	}{
+		{"no digest", nil, http.StatusOK},
+		{"content-digest", []string{"Content-Digest", digest.Header(sum[:])}, http.StatusOK},
+		{"mismatch", []string{"Content-Digest", digest.Header(other[:])}, http.StatusBadRequest},
+		{"malformed", []string{"Digest", "SHA-256=!!"}, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := memory.New()
+			for _, handler := range []http.HandlerFunc{FileUpload10MB(store, policy, config.Quota{}), LargeFileUpload(store, policy, config.Quota{})} {
+				if w := postFile(t, handler, "cert.txt", content, tt.header...); w.Code != tt.status {
+					t.Fatalf("status %d, want %d: %s", w.Code, tt.status, w.Body)
+				}
+			}
+			usage, err := store.TotalFileUsage()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.status != http.StatusOK && usage.Files != 0 {
+				t.Errorf("rejected uploads left %d files behind", usage.Files)
+			}
+			// Both uploads of the same content share one blob
+			if tt.status == http.StatusOK && (usage.Files != 2 || usage.Bytes != int64(len(content))) {
+				t.Errorf("usage = %+v", usage)
+			}
+		})
+	}
+}
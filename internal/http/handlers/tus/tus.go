@@ -0,0 +1,298 @@
+// Package tus implements the core, creation, expiration and termination
+// parts of the tus 1.0 resumable upload protocol (https://tus.io/protocols/resumable-upload).
+package tus
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/quota"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/digest"
+	"github.com/surajNirala/student-api/internal/utils/filetype"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+const (
+	Version    = "1.0.0"
+	Extensions = "creation,expiration,termination"
+	BasePath   = "/api/files/tus"
+)
+
+func Options(cfg config.Tus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Tus-Resumable", Version)
+		w.Header().Set("Tus-Version", Version)
+		w.Header().Set("Tus-Extension", Extensions)
+		w.Header().Set("Tus-Max-Size", strconv.FormatInt(cfg.MaxSize, 10))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func Create(store storage.Storage, cfg config.Tus, quotaCfg config.Quota) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		if !checkVersion(w, r) {
+			return
+		}
+		length, err := strconv.ParseInt(r.Header.Get("Upload-Length"), 10, 64)
+		if err != nil || length < 0 {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid Upload-Length")))
+			return
+		}
+		// An empty upload would be complete before any PATCH could create its file
+		if length == 0 {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("Upload-Length must be positive")))
+			return
+		}
+		if length > cfg.MaxSize {
+			response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GenerateError(fmt.Errorf("upload exceeds maximum size of %d bytes", cfg.MaxSize)))
+			return
+		}
+		metadata, err := parseMetadata(r.Header.Get("Upload-Metadata"))
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		var studentID int64
+		if idStr := metadata["student_id"]; idStr != "" {
+			studentID, err = strconv.ParseInt(idStr, 10, 64)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid student_id")))
+				return
+			}
+			_, err = store.GetStudentByID(studentID)
+			if errors.Is(err, sql.ErrNoRows) {
+				response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("no student found with id %d", studentID)))
+				return
+			}
+			if err != nil {
+				response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+				return
+			}
+		}
+		remaining, err := quota.Remaining(store, quotaCfg, studentID)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		if length > remaining {
+			response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GenerateError(quota.ErrExceeded))
+			return
+		}
+		fileName := metadata["filename"]
+		if fileName == "" {
+			fileName = "upload"
+		}
+
+		upload, err := store.CreateUpload(studentID, fileName, metadata["filetype"], length)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		setUploadHeaders(w, upload, cfg)
+		w.Header().Set("Location", BasePath+"/"+upload.Id)
+		w.WriteHeader(http.StatusCreated)
+	}
+}
+
+func Head(store storage.Storage, cfg config.Tus) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		if !checkVersion(w, r) {
+			return
+		}
+		upload, err := store.GetUpload(r.PathValue("id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		setUploadHeaders(w, upload, cfg)
+		w.Header().Set("Upload-Length", strconv.FormatInt(upload.Length, 10))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func Patch(store storage.Storage, cfg config.Tus, quotaCfg config.Quota) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		if !checkVersion(w, r) {
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/offset+octet-stream" {
+			response.WriteJson(w, http.StatusUnsupportedMediaType, response.GenerateError(fmt.Errorf("content type must be application/offset+octet-stream")))
+			return
+		}
+		offset, err := strconv.ParseInt(r.Header.Get("Upload-Offset"), 10, 64)
+		if err != nil || offset < 0 {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("invalid Upload-Offset")))
+			return
+		}
+		current, err := store.GetUpload(r.PathValue("id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("upload not found")))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		remaining, err := quota.Remaining(store, quotaCfg, current.StudentId)
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		want, err := digest.FromHeader(r.Header)
+		if err != nil {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		body := io.Reader(r.Body)
+		if offset == 0 && current.Offset == 0 && len(cfg.AllowedTypes) > 0 {
+			mime, sniffed, err := filetype.Detect(r.Body)
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+				return
+			}
+			if !filetype.Allowed(mime, cfg.AllowedTypes) {
+				// Terminate the upload so no part of a rejected file is kept
+				store.DeleteUpload(current.Id)
+				response.WriteJson(w, http.StatusUnsupportedMediaType, response.GenerateError(fmt.Errorf("file type %s is not allowed", mime.String())))
+				return
+			}
+			body = sniffed
+		}
+		upload, err := store.WriteUploadChunk(current.Id, offset, quota.Limit(digest.Verify(body, want), remaining))
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("upload not found")))
+			return
+		}
+		if errors.Is(err, storage.ErrOffsetMismatch) {
+			w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+			response.WriteJson(w, http.StatusConflict, response.GenerateError(err))
+			return
+		}
+		if errors.Is(err, digest.ErrMismatch) {
+			response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+			return
+		}
+		if errors.Is(err, quota.ErrExceeded) {
+			// The upload can never complete, so drop what it has received so far
+			store.DeleteUpload(current.Id)
+			response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GenerateError(err))
+			return
+		}
+		if errors.Is(err, storage.ErrChunkTooLarge) {
+			response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GenerateError(err))
+			return
+		}
+		if err != nil {
+			// The bytes that did arrive are kept; the client resumes from the offset reported by HEAD
+			slog.Error("Upload chunk interrupted", slog.String("id", upload.Id), slog.String("error", err.Error()))
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		if upload.Complete() && len(cfg.AllowedTypes) > 0 {
+			// The type is sniffed again from the stored file, as the first chunk alone may not decide it
+			mime, err := detectFile(store, int64(upload.FileId))
+			if err != nil {
+				response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+				return
+			}
+			if !filetype.Allowed(mime, cfg.AllowedTypes) {
+				store.DeleteUpload(upload.Id)
+				store.DeleteFileByID(int64(upload.FileId))
+				response.WriteJson(w, http.StatusUnsupportedMediaType, response.GenerateError(fmt.Errorf("file type %s is not allowed", mime.String())))
+				return
+			}
+		}
+		setUploadHeaders(w, upload, cfg)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func detectFile(store storage.Storage, id int64) (*mimetype.MIME, error) {
+	_, rc, err := store.OpenFile(id)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	mime, _, err := filetype.Detect(rc)
+	return mime, err
+}
+
+func Delete(store storage.Storage) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		store := store.WithContext(r.Context())
+		if !checkVersion(w, r) {
+			return
+		}
+		err := store.DeleteUpload(r.PathValue("id"))
+		if errors.Is(err, sql.ErrNoRows) {
+			response.WriteJson(w, http.StatusNotFound, response.GenerateError(fmt.Errorf("upload not found")))
+			return
+		}
+		if err != nil {
+			response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+			return
+		}
+		w.Header().Set("Tus-Resumable", Version)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func checkVersion(w http.ResponseWriter, r *http.Request) bool {
+	if r.Header.Get("Tus-Resumable") != Version {
+		w.Header().Set("Tus-Version", Version)
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return false
+	}
+	return true
+}
+
+func setUploadHeaders(w http.ResponseWriter, upload models.Upload, cfg config.Tus) {
+	w.Header().Set("Tus-Resumable", Version)
+	w.Header().Set("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	if upload.Complete() {
+		w.Header().Set("File-Location", fmt.Sprintf("/api/files/%d", upload.FileId))
+		return
+	}
+	expires := upload.UpdatedAt.Add(cfg.Expiry)
+	w.Header().Set("Upload-Expires", expires.UTC().Format(http.TimeFormat))
+}
+
+// parseMetadata decodes the Upload-Metadata header, a comma separated list
+// of keys each followed by an optional base64 encoded value.
+func parseMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if header == "" {
+		return metadata, nil
+	}
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, fmt.Errorf("invalid Upload-Metadata")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Upload-Metadata value for %s", key)
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -0,0 +1,286 @@
+package tus
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+)
+
+var testConfig = config.Tus{MaxSize: 1 << 20, Expiry: time.Hour}
+
+func newRouter(t *testing.T, store storage.Storage, cfg config.Tus, quotaCfg config.Quota) *http.ServeMux {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	router := http.NewServeMux()
+	router.HandleFunc("OPTIONS "+BasePath, Options(cfg))
+	router.HandleFunc("POST "+BasePath, Create(store, cfg, quotaCfg))
+	router.HandleFunc("HEAD "+BasePath+"/{id}", Head(store, cfg))
+	router.HandleFunc("PATCH "+BasePath+"/{id}", Patch(store, cfg, quotaCfg))
+	router.HandleFunc("DELETE "+BasePath+"/{id}", Delete(store))
+	return router
+}
+
+// send makes a tus request; header holds name/value pairs.
+func send(router http.Handler, method string, path string, body string, header ...string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, path, strings.NewReader(body))
+	r.Header.Set("Tus-Resumable", Version)
+	for i := 0; i+1 < len(header); i += 2 {
+		r.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, r)
+	return w
+}
+
+func create(t *testing.T, router http.Handler, length int, metadata string) string {
+	t.Helper()
+	w := send(router, http.MethodPost, BasePath, "", "Upload-Length", strconv.Itoa(length), "Upload-Metadata", metadata)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create: status %d: %s", w.Code, w.Body)
+	}
+	location := w.Header().Get("Location")
+	if !strings.HasPrefix(location, BasePath+"/") {
+		t.Fatalf("Location = %q", location)
+	}
+	return location
+}
+
+func patch(router http.Handler, location string, offset int, chunk string) *httptest.ResponseRecorder {
+	return send(router, http.MethodPatch, location, chunk,
+		"Content-Type", "application/offset+octet-stream", "Upload-Offset", strconv.Itoa(offset))
+}
+
+func meta(pairs ...string) string {
+	var fields []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		fields = append(fields, pairs[i]+" "+base64.StdEncoding.EncodeToString([]byte(pairs[i+1])))
+	}
+	return strings.Join(fields, ",")
+}
+
+func TestUpload(t *testing.T) {
+	store := memory.New()
+	router := newRouter(t, store, testConfig, config.Quota{})
+
+	w := send(router, http.MethodOptions, BasePath, "")
+	if w.Code != http.StatusNoContent || w.Header().Get("Tus-Version") != Version || w.Header().Get("Tus-Max-Size") != "1048576" {
+		t.Errorf("OPTIONS = %d %v", w.Code, w.Header())
+	}
+
+	content := "hello, resumable world"
+	location := create(t, router, len(content), meta("filename", "hello.txt", "filetype", "text/plain"))
+	w = send(router, http.MethodHead, location, "")
+	if w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "0" || w.Header().Get("Upload-Length") != strconv.Itoa(len(content)) {
+		t.Errorf("HEAD = %d %v", w.Code, w.Header())
+	}
+	if w.Header().Get("Upload-Expires") == "" || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("HEAD headers %v", w.Header())
+	}
+
+	if w = patch(router, location, 0, content[:5]); w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != "5" {
+		t.Fatalf("first PATCH = %d, offset %s", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	if w = patch(router, location, 0, content); w.Code != http.StatusConflict || w.Header().Get("Upload-Offset") != "5" {
+		t.Errorf("PATCH at a stale offset = %d, offset %s", w.Code, w.Header().Get("Upload-Offset"))
+	}
+	if w = patch(router, location, 5, content[5:]+"!"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("PATCH past the length = %d", w.Code)
+	}
+	w = patch(router, location, 5, content[5:])
+	if w.Code != http.StatusNoContent || w.Header().Get("Upload-Offset") != strconv.Itoa(len(content)) {
+		t.Fatalf("last PATCH = %d %v", w.Code, w.Header())
+	}
+	fileLocation := w.Header().Get("File-Location")
+	id, err := strconv.ParseInt(strings.TrimPrefix(fileLocation, "/api/files/"), 10, 64)
+	if err != nil {
+		t.Fatalf("File-Location = %q", fileLocation)
+	}
+	file, rc, err := store.OpenFile(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != content || file.Name != "hello.txt" {
+		t.Errorf("stored file %+v holds %q", file, data)
+	}
+
+	if w = send(router, http.MethodDelete, location, ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE = %d", w.Code)
+	}
+	if w = send(router, http.MethodHead, location, ""); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD after DELETE = %d", w.Code)
+	}
+	if w = patch(router, location, 0, "x"); w.Code != http.StatusNotFound {
+		t.Errorf("PATCH after DELETE = %d", w.Code)
+	}
+}
+
+func TestRefused(t *testing.T) {
+	store := memory.New()
+	router := newRouter(t, store, testConfig, config.Quota{})
+	location := create(t, router, 10, "")
+
+	tests := []struct {
+		name   string
+		w      *httptest.ResponseRecorder
+		status int
+	}{
+		{"no version", func() *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodHead, location, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, r)
+			return w
+		}(), http.StatusPreconditionFailed},
+		{"no length", send(router, http.MethodPost, BasePath, ""), http.StatusBadRequest},
+		{"negative length", send(router, http.MethodPost, BasePath, "", "Upload-Length", "-1"), http.StatusBadRequest},
+		{"empty upload", send(router, http.MethodPost, BasePath, "", "Upload-Length", "0"), http.StatusBadRequest},
+		{"too large", send(router, http.MethodPost, BasePath, "", "Upload-Length", "1048577"), http.StatusRequestEntityTooLarge},
+		{"bad metadata", send(router, http.MethodPost, BasePath, "", "Upload-Length", "1", "Upload-Metadata", "filename !!"), http.StatusBadRequest},
+		{"unknown student", send(router, http.MethodPost, BasePath, "", "Upload-Length", "1", "Upload-Metadata", meta("student_id", "404")), http.StatusNotFound},
+		{"wrong content type", send(router, http.MethodPatch, location, "x", "Upload-Offset", "0"), http.StatusUnsupportedMediaType},
+		{"bad offset", send(router, http.MethodPatch, location, "x", "Content-Type", "application/offset+octet-stream", "Upload-Offset", "-1"), http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if tt.w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, tt.w.Code, tt.status)
+		}
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	tests := []struct {
+		header string
+		want   map[string]string
+		ok     bool
+	}{
+		{"", map[string]string{}, true},
+		{meta("filename", "a.txt"), map[string]string{"filename": "a.txt"}, true},
+		{meta("filename", "a.txt", "student_id", "7") + ",is_confidential", map[string]string{"filename": "a.txt", "student_id": "7", "is_confidential": ""}, true},
+		{" filename " + base64.StdEncoding.EncodeToString([]byte("a")), map[string]string{"filename": "a"}, true},
+		{"filename not-base64!", nil, false},
+		{",", nil, false},
+	}
+	for _, tt := range tests {
+		got, err := parseMetadata(tt.header)
+		if (err == nil) != tt.ok || (tt.ok && !reflect.DeepEqual(got, tt.want)) {
+			t.Errorf("parseMetadata(%q) = %v, %v", tt.header, got, err)
+		}
+	}
+}
+
+func TestAllowedTypes(t *testing.T) {
+	cfg := testConfig
+	cfg.AllowedTypes = []string{"application/pdf"}
+	router := newRouter(t, memory.New(), cfg, config.Quota{})
+
+	script := "#!/bin/sh\necho hi\n"
+	location := create(t, router, len(script), meta("filename", "cv.pdf"))
+	if w := patch(router, location, 0, script); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("PATCH of a disallowed type = %d", w.Code)
+	}
+	if w := send(router, http.MethodHead, location, ""); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD of a rejected upload = %d, want 404", w.Code)
+	}
+
+	pdf := "%PDF-1.7\n%%EOF\n"
+	location = create(t, router, len(pdf), meta("filename", "cv.pdf"))
+	if w := patch(router, location, 0, pdf[:9]); w.Code != http.StatusNoContent {
+		t.Fatalf("first PATCH of a pdf = %d", w.Code)
+	}
+	// Only the start of the file is sniffed
+	if w := patch(router, location, 9, pdf[9:]); w.Code != http.StatusNoContent || w.Header().Get("File-Location") == "" {
+		t.Errorf("last PATCH of a pdf = %d %v", w.Code, w.Header())
+	}
+}
+
+func TestAllowedTypesOfCompleteUpload(t *testing.T) {
+	cfg := testConfig
+	cfg.AllowedTypes = []string{"text/plain"}
+	store := memory.New()
+	router := newRouter(t, store, cfg, config.Quota{})
+
+	// "PK" alone sniffs as text; only the whole file is a zip archive
+	zip := "PK\x03\x04" + strings.Repeat("\x00", 26)
+	location := create(t, router, len(zip), meta("filename", "notes.txt"))
+	if w := patch(router, location, 0, zip[:2]); w.Code != http.StatusNoContent {
+		t.Fatalf("first PATCH = %d", w.Code)
+	}
+	if w := patch(router, location, 2, zip[2:]); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("last PATCH of a disallowed type = %d, want 415", w.Code)
+	}
+	if w := send(router, http.MethodHead, location, ""); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD of a rejected upload = %d, want 404", w.Code)
+	}
+	if usage, err := store.TotalFileUsage(); err != nil || usage.Files != 0 {
+		t.Errorf("usage = %+v, %v; want no files kept", usage, err)
+	}
+}
+
+func TestQuota(t *testing.T) {
+	store := memory.New()
+	router := newRouter(t, store, testConfig, config.Quota{PerStudent: 10})
+	studentID, err := store.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := meta("student_id", strconv.FormatInt(studentID, 10))
+
+	if w := send(router, http.MethodPost, BasePath, "", "Upload-Length", "11", "Upload-Metadata", owner); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("create over the quota = %d", w.Code)
+	}
+	location := create(t, router, 8, owner)
+	if w := patch(router, location, 0, "012"); w.Code != http.StatusNoContent {
+		t.Fatalf("first PATCH = %d", w.Code)
+	}
+	// Another upload of the student takes up room the resumable one counted on
+	if _, err := store.StudentFileUpload10MB(studentID, "other.txt", "text/plain", []byte("abcd")); err != nil {
+		t.Fatal(err)
+	}
+	if w := patch(router, location, 3, "34567"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("PATCH past the quota = %d", w.Code)
+	}
+	if w := send(router, http.MethodHead, location, ""); w.Code != http.StatusNotFound {
+		t.Errorf("HEAD of an upload over the quota = %d, want 404", w.Code)
+	}
+	usage, err := store.StudentFileUsage(studentID)
+	if err != nil || usage.Files != 1 || usage.Bytes != 4 {
+		t.Errorf("usage = %+v, %v", usage, err)
+	}
+}
+
+func TestQuotaCountsParallelUploads(t *testing.T) {
+	store := memory.New()
+	router := newRouter(t, store, testConfig, config.Quota{PerStudent: 10})
+	studentID, err := store.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	owner := meta("student_id", strconv.FormatInt(studentID, 10))
+
+	first := create(t, router, 8, owner)
+	second := create(t, router, 8, owner)
+	if w := patch(router, first, 0, "012345"); w.Code != http.StatusNoContent {
+		t.Fatalf("PATCH of the first upload = %d", w.Code)
+	}
+	// The bytes the first upload holds leave no room for the second
+	if w := patch(router, second, 0, "abcdef"); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("PATCH of the second upload = %d, want 413", w.Code)
+	}
+	if w := send(router, http.MethodHead, first, ""); w.Code != http.StatusOK || w.Header().Get("Upload-Offset") != "6" {
+		t.Errorf("HEAD of the first upload = %d %v", w.Code, w.Header())
+	}
+	if w := patch(router, first, 6, "67"); w.Code != http.StatusNoContent || w.Header().Get("File-Location") == "" {
+		t.Errorf("last PATCH of the first upload = %d %v", w.Code, w.Header())
+	}
+}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// ClientHeader lets a client that reaches the API through several
+// addresses, or shares one with others behind a proxy, name itself.
+const ClientHeader = "X-Client-ID"
+
+// ClientID records the caller in the request context, from ClientHeader
+// when it is set and from the remote address otherwise.
+func ClientID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		client := r.Header.Get(ClientHeader)
+		if client == "" {
+			client = r.RemoteAddr
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				client = host
+			}
+		}
+		next.ServeHTTP(w, r.WithContext(storage.ContextWithClient(r.Context(), client)))
+	})
+}
@@ -0,0 +1,150 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+const (
+	// IdempotencyKeyHeader carries a key the client picks for a POST
+	// request, such as a UUID, and sends again when it retries it.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response repeated from the first
+	// request made with the same key.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
+// Idempotency makes POST requests sent with IdempotencyKeyHeader safe to
+// retry. The first request with a key runs and its response is stored;
+// repeats get that response back without running the handler again. A
+// key reused for a different method, path or body is refused with 422,
+// and a repeat arriving while the first request still runs with 409.
+// Responses with a 5xx status are not kept, so such requests can be
+// retried for real.
+func Idempotency(store storage.Storage, cfg config.Idempotency) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(IdempotencyKeyHeader)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(fmt.Errorf("%s must not be longer than %d characters", IdempotencyKeyHeader, maxIdempotencyKeyLength)))
+				return
+			}
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBodySize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				response.WriteJson(w, http.StatusRequestEntityTooLarge, response.GenerateError(fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit)))
+				return
+			}
+			if err != nil {
+				response.WriteJson(w, http.StatusBadRequest, response.GenerateError(err))
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			// Within an atomic batch the key is kept only if the batch commits
+			store := store.WithContext(r.Context())
+			now := time.Now()
+			fingerprint := requestFingerprint(r, body)
+			reserved, ok, err := store.ReserveIdempotencyKey(key, fingerprint, now.Add(cfg.TTL), now.Add(-cfg.LockTimeout))
+			if err != nil {
+				response.WriteJson(w, http.StatusInternalServerError, response.GenerateError(err))
+				return
+			}
+			if !ok {
+				replay(w, reserved, fingerprint)
+				return
+			}
+
+			rec := &recorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+			if rec.header == nil {
+				rec.WriteHeader(http.StatusOK)
+			}
+			if rec.status >= http.StatusInternalServerError {
+				err = store.DeleteIdempotencyKey(key)
+			} else {
+				reserved.StatusCode = rec.status
+				reserved.Header = rec.header
+				reserved.Body = rec.body.Bytes()
+				err = store.CompleteIdempotencyKey(reserved)
+			}
+			if err != nil {
+				slog.Error("Saving idempotency key failed", slog.String("key", key), slog.String("error", err.Error()))
+			}
+		})
+	}
+}
+
+// replay answers a repeat of the request that took key.
+func replay(w http.ResponseWriter, key models.IdempotencyKey, fingerprint string) {
+	if key.Fingerprint != fingerprint {
+		response.WriteJson(w, http.StatusUnprocessableEntity, response.GenerateError(fmt.Errorf("%s was already used for a different request", IdempotencyKeyHeader)))
+		return
+	}
+	if key.StatusCode == 0 {
+		response.WriteJson(w, http.StatusConflict, response.GenerateError(fmt.Errorf("a request with this %s is still being processed", IdempotencyKeyHeader)))
+		return
+	}
+	for name, values := range key.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(IdempotentReplayedHeader, "true")
+	w.WriteHeader(key.StatusCode)
+	w.Write(key.Body)
+}
+
+// requestFingerprint identifies a request by its method, path, query and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "%s %s\n", r.Method, r.URL.RequestURI())
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recorder passes a response on to the client while keeping a copy of it.
+// The request id belongs to each request, so it is left out of the copy.
+type recorder struct {
+	http.ResponseWriter
+	status int
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.header == nil {
+		rec.status = status
+		rec.header = rec.ResponseWriter.Header().Clone()
+		rec.header.Del(RequestIDHeader)
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	if rec.header == nil {
+		rec.WriteHeader(http.StatusOK)
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (rec *recorder) Unwrap() http.ResponseWriter {
+	return rec.ResponseWriter
+}
@@ -0,0 +1,104 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+)
+
+var testConfig = config.Idempotency{
+	TTL:         time.Hour,
+	LockTimeout: time.Minute,
+	MaxBodySize: 1024,
+}
+
+// counter answers every request with the number of requests it has run.
+type counter struct {
+	calls  int
+	status int
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.calls++
+	w.Header().Set("Location", "/api/students/"+strconv.Itoa(c.calls))
+	w.WriteHeader(c.status)
+	w.Write([]byte(strconv.Itoa(c.calls)))
+}
+
+func post(t *testing.T, h http.Handler, key string, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/api/students", strings.NewReader(body))
+	if key != "" {
+		r.Header.Set(IdempotencyKeyHeader, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestIdempotencyReplays(t *testing.T) {
+	next := &counter{status: http.StatusCreated}
+	h := RequestID(Idempotency(memory.New(), testConfig)(next))
+
+	first := post(t, h, "key-1", `{"name":"Asha"}`)
+	again := post(t, h, "key-1", `{"name":"Asha"}`)
+	if next.calls != 1 {
+		t.Fatalf("handler ran %d times, want 1", next.calls)
+	}
+	if again.Code != http.StatusCreated || again.Body.String() != "1" || again.Header().Get("Location") != "/api/students/1" {
+		t.Errorf("replay = %d %q %v", again.Code, again.Body, again.Header())
+	}
+	if again.Header().Get(IdempotentReplayedHeader) != "true" || first.Header().Get(IdempotentReplayedHeader) != "" {
+		t.Errorf("%s not set on the replay only", IdempotentReplayedHeader)
+	}
+	if again.Header().Get(RequestIDHeader) == first.Header().Get(RequestIDHeader) {
+		t.Errorf("replay repeated the request id of the first request")
+	}
+
+	if w := post(t, h, "key-1", `{"name":"Ravi"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("key reused with another body: status %d, want 422", w.Code)
+	}
+	post(t, h, "key-2", `{"name":"Asha"}`)
+	post(t, h, "", `{"name":"Asha"}`)
+	if next.calls != 3 {
+		t.Errorf("handler ran %d times, want 3", next.calls)
+	}
+}
+
+func TestIdempotencyInProgress(t *testing.T) {
+	store := memory.New()
+	var inner *httptest.ResponseRecorder
+	var h http.Handler
+	h = Idempotency(store, testConfig)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inner = post(t, h, "key-1", "{}")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	post(t, h, "key-1", "{}")
+	if inner.Code != http.StatusConflict {
+		t.Errorf("repeat during the first request: status %d, want 409", inner.Code)
+	}
+}
+
+func TestIdempotencyServerErrorsNotKept(t *testing.T) {
+	next := &counter{status: http.StatusInternalServerError}
+	h := Idempotency(memory.New(), testConfig)(next)
+	post(t, h, "key-1", "{}")
+	next.status = http.StatusCreated
+	if w := post(t, h, "key-1", "{}"); w.Code != http.StatusCreated || next.calls != 2 {
+		t.Errorf("retry after a server error = %d after %d calls, want 201 after 2", w.Code, next.calls)
+	}
+}
+
+func TestIdempotencyBodyTooLarge(t *testing.T) {
+	next := &counter{status: http.StatusCreated}
+	h := Idempotency(memory.New(), testConfig)(next)
+	if w := post(t, h, "key-1", strings.Repeat("x", 2048)); w.Code != http.StatusRequestEntityTooLarge || next.calls != 0 {
+		t.Errorf("oversized body = %d after %d calls, want 413 after 0", w.Code, next.calls)
+	}
+}
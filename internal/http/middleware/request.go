@@ -0,0 +1,44 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+const (
+	RequestIDHeader = "X-Request-ID"
+	// ActorHeader names the person or service behind a request until the
+	// API authenticates its callers.
+	ActorHeader = "X-Actor"
+)
+
+// RequestID tags the request with the id from RequestIDHeader, or a new
+// one, and echoes it in the response so both can be matched in the logs
+// and the audit trail.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if id == "" || len(id) > 128 {
+			b := make([]byte, 16)
+			rand.Read(b)
+			id = hex.EncodeToString(b)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(storage.ContextWithRequestID(r.Context(), id)))
+	})
+}
+
+// Actor records who the request acts for, from ActorHeader or else the
+// client set by ClientID, which must run first.
+func Actor(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		actor := r.Header.Get(ActorHeader)
+		if actor == "" {
+			actor = storage.ClientFromContext(r.Context())
+		}
+		next.ServeHTTP(w, r.WithContext(storage.ContextWithActor(r.Context(), actor)))
+	})
+}
@@ -0,0 +1,131 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/openapi"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+// Validation checks requests against the operation doc describes for a
+// route before its handler runs. Parameters of the wrong type, such as a
+// path id that is not a number, and JSON bodies that are malformed or do
+// not match their schema are refused with a 400 problem listing every
+// violation. With cfg.Responses, JSON responses are checked as well and
+// replaced by a 500 problem when they do not match.
+//
+// The returned function wraps the handler registered for method and path;
+// routes doc does not describe are left as they are.
+func Validation(doc *openapi.Document, cfg config.Validation) func(method string, path string) func(http.Handler) http.Handler {
+	return func(method string, path string) func(http.Handler) http.Handler {
+		op := doc.Operation(method, path)
+		return func(next http.Handler) http.Handler {
+			if op == nil {
+				return next
+			}
+			checkResponses := cfg.Responses && method != http.MethodHead && !streams(op)
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var body []byte
+				if takesJSON(op) {
+					var err error
+					body, err = io.ReadAll(http.MaxBytesReader(w, r.Body, cfg.MaxBodySize))
+					var tooLarge *http.MaxBytesError
+					if errors.As(err, &tooLarge) {
+						response.WriteProblem(w, problem(r, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body exceeds %d bytes", tooLarge.Limit), nil))
+						return
+					}
+					if err != nil {
+						response.WriteProblem(w, problem(r, http.StatusBadRequest, err.Error(), nil))
+						return
+					}
+					r.Body = io.NopCloser(bytes.NewReader(body))
+				}
+				if violations := doc.ValidateRequest(op, r, body); len(violations) > 0 {
+					response.WriteProblem(w, problem(r, http.StatusBadRequest, "the request does not match the API description", violations))
+					return
+				}
+				if !checkResponses {
+					next.ServeHTTP(w, r)
+					return
+				}
+
+				buf := &bufferedResponse{ResponseWriter: w}
+				next.ServeHTTP(buf, r)
+				if buf.status == 0 {
+					buf.status = http.StatusOK
+				}
+				if violations := doc.ValidateResponse(op, buf.status, w.Header(), buf.body.Bytes()); len(violations) > 0 {
+					slog.Error("Response does not match the API description",
+						slog.String("method", r.Method), slog.String("path", r.URL.Path), slog.Int("status", buf.status),
+						slog.String("error", violations[0].Pointer+" "+violations[0].Detail))
+					w.Header().Del("Content-Length")
+					response.WriteProblem(w, problem(r, http.StatusInternalServerError, fmt.Sprintf("the %d response does not match the API description", buf.status), violations))
+					return
+				}
+				w.WriteHeader(buf.status)
+				w.Write(buf.body.Bytes())
+			})
+		}
+	}
+}
+
+func problem(r *http.Request, status int, detail string, violations []openapi.Violation) response.Problem {
+	p := response.NewProblem(status, detail)
+	p.Instance = r.URL.Path
+	for _, v := range violations {
+		p.Errors = append(p.Errors, response.ProblemError{In: v.In, Pointer: v.Pointer, Detail: v.Detail})
+	}
+	return p
+}
+
+func takesJSON(op *openapi.Operation) bool {
+	if op.RequestBody == nil {
+		return false
+	}
+	_, ok := op.RequestBody.Content["application/json"]
+	return ok
+}
+
+// streams reports whether op answers with an event stream, which never
+// ends and so cannot be held back to be checked.
+func streams(op *openapi.Operation) bool {
+	for _, res := range op.Responses {
+		if _, ok := res.Content["text/event-stream"]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// bufferedResponse holds a response back until it has been checked.
+// Headers go straight to the underlying writer, which sends them only
+// once the status is written.
+type bufferedResponse struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (buf *bufferedResponse) WriteHeader(status int) {
+	if buf.status == 0 {
+		buf.status = status
+	}
+}
+
+func (buf *bufferedResponse) Write(b []byte) (int, error) {
+	if buf.status == 0 {
+		buf.status = http.StatusOK
+	}
+	return buf.body.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (buf *bufferedResponse) Unwrap() http.ResponseWriter {
+	return buf.ResponseWriter
+}
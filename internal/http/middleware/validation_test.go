@@ -0,0 +1,94 @@
+package middleware
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/openapi"
+	"github.com/surajNirala/student-api/internal/utils/response"
+)
+
+// validated serves one route behind Validation; handler answers it.
+func validated(cfg config.Validation, handler http.HandlerFunc) http.Handler {
+	route := openapi.Route{
+		Method:   http.MethodPut,
+		Path:     "/api/students/{id}",
+		Params:   map[string]*openapi.Schema{"id": {Type: "integer"}},
+		Request:  models.Student{},
+		Response: models.Student{},
+	}
+	doc := openapi.Build(openapi.Info{}, []openapi.Route{route}, response.Response{}, response.Problem{})
+	mux := http.NewServeMux()
+	mux.Handle(route.Pattern(), Validation(doc, cfg)(route.Method, route.Path)(handler))
+	return mux
+}
+
+func put(h http.Handler, path string, body string) (*httptest.ResponseRecorder, response.Problem) {
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPut, path, strings.NewReader(body)))
+	var problem response.Problem
+	if w.Header().Get("Content-Type") == response.ProblemType {
+		json.Unmarshal(w.Body.Bytes(), &problem)
+	}
+	return w, problem
+}
+
+func TestValidationRefusesInvalidRequests(t *testing.T) {
+	called := false
+	h := validated(config.Validation{MaxBodySize: 64}, func(w http.ResponseWriter, r *http.Request) {
+		called = true
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+	})
+
+	w, _ := put(h, "/api/students/1", `{"name": "Asha", "email": "a@b.co", "age": 20}`)
+	if w.Code != http.StatusOK || !called || w.Body.String() != `{"name": "Asha", "email": "a@b.co", "age": 20}` {
+		t.Fatalf("valid request = %d %q, handler called %v", w.Code, w.Body, called)
+	}
+
+	for _, tt := range []struct {
+		path, body string
+		status     int
+		errors     int
+	}{
+		{"/api/students/x", `{"name": "Asha", "email": "a@b.co", "age": 20}`, http.StatusBadRequest, 1},
+		{"/api/students/1", `{"name": "Asha"`, http.StatusBadRequest, 1},
+		{"/api/students/x", `{"age": "20"}`, http.StatusBadRequest, 4},
+		{"/api/students/1", `{"name": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge, 0},
+	} {
+		called = false
+		w, problem := put(h, tt.path, tt.body)
+		if w.Code != tt.status || problem.Status != tt.status || len(problem.Errors) != tt.errors || called {
+			t.Errorf("PUT %s %s = %d %+v, handler called %v", tt.path, tt.body, w.Code, problem, called)
+		}
+	}
+}
+
+func TestValidationChecksResponses(t *testing.T) {
+	body := `{"id": 1, "name": "Asha", "email": "a@b.co", "age": "twenty"}`
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(body))
+	}
+	valid := `{"name": "Asha", "email": "a@b.co", "age": 20}`
+	if w, _ := put(validated(config.Validation{MaxBodySize: 1024}, handler), "/api/students/1", valid); w.Code != http.StatusOK {
+		t.Errorf("without response checks: %d", w.Code)
+	}
+
+	h := validated(config.Validation{MaxBodySize: 1024, Responses: true}, handler)
+	w, problem := put(h, "/api/students/1", valid)
+	if w.Code != http.StatusInternalServerError || len(problem.Errors) != 1 || problem.Errors[0].Pointer != "/age" {
+		t.Errorf("invalid response = %d %+v", w.Code, problem)
+	}
+
+	body = `{"id": 1, "name": "Asha", "email": "a@b.co", "age": 20}`
+	if w, _ := put(h, "/api/students/1", valid); w.Code != http.StatusOK || w.Body.String() != body {
+		t.Errorf("valid response = %d %q", w.Code, w.Body)
+	}
+}
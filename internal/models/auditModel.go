@@ -0,0 +1,19 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// AuditEntry records one change to a record: who made it, in which
+// request, and the fields it changed as {"field": {"from": .., "to": ..}}.
+type AuditEntry struct {
+	Id         uint64          `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityId   int64           `json:"entity_id"`
+	Changes    json.RawMessage `json:"changes"`
+	RequestId  string          `json:"request_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
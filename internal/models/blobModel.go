@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Blob is stored file content, shared by every file with the same digest.
+type Blob struct {
+	SHA256    string    `json:"sha256"`
+	Size      int64     `json:"size"`
+	Path      string    `json:"-"`
+	RefCount  int64     `json:"ref_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
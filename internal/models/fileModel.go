@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type File struct {
+	Id          uint64    `json:"id"`
+	StudentId   int64     `json:"student_id,omitempty"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	Path        string    `json:"-"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"net/http"
+	"time"
+)
+
+// IdempotencyKey remembers a request sent with an Idempotency-Key header.
+// Fingerprint identifies the request the key was first used for.
+// StatusCode is 0 while that request is being handled; afterwards Header
+// and Body hold its response, which repeats of the request get back.
+type IdempotencyKey struct {
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	Header      http.Header
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
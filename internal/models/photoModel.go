@@ -0,0 +1,19 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Photo links a student to the stored files of its profile photo.
+type Photo struct {
+	StudentId      int64     `json:"student_id"`
+	OriginalFileId uint64    `json:"original_file_id"`
+	MediumFileId   uint64    `json:"medium_file_id"`
+	ThumbFileId    uint64    `json:"thumb_file_id"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+func StudentPhotoURL(studentID uint64) string {
+	return fmt.Sprintf("/api/students/%d/photo", studentID)
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// StudentRevision is a student as it stood after one change. Revisions
+// are numbered from 1 per student; a deletion is recorded as a revision
+// with Deleted set and the last values the student had.
+type StudentRevision struct {
+	StudentId int64     `json:"student_id"`
+	Revision  int       `json:"revision"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Age       int       `json:"age"`
+	Deleted   bool      `json:"deleted"`
+	Actor     string    `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Student returns the student as of this revision.
+func (r StudentRevision) Student() Student {
+	return Student{Id: uint64(r.StudentId), Name: r.Name, Email: r.Email, Age: r.Age, UpdatedAt: r.CreatedAt}
+}
@@ -7,6 +7,7 @@ type Student struct {
 	Name      string    `validate:"required" json:"name"`
 	Email     string    `validate:"required" json:"email"`
 	Age       int       `validate:"required" json:"age"`
+	PhotoURL  string    `json:"photo_url,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 }
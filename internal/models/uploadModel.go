@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Upload tracks a resumable upload while its bytes arrive in chunks.
+type Upload struct {
+	Id          string    `json:"id"`
+	StudentId   int64     `json:"student_id,omitempty"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Length      int64     `json:"length"`
+	Offset      int64     `json:"offset"`
+	Path        string    `json:"-"`
+	FileId      uint64    `json:"file_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func (u Upload) Complete() bool {
+	return u.Offset == u.Length
+}
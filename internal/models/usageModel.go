@@ -0,0 +1,8 @@
+package models
+
+// FileUsage sums up the files stored for a student, or for everyone.
+type FileUsage struct {
+	StudentId int64 `json:"student_id,omitempty"`
+	Files     int64 `json:"files"`
+	Bytes     int64 `json:"used_bytes"`
+}
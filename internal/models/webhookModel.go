@@ -0,0 +1,50 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Event is a change to a student, kept in the outbox until it has been
+// handed to every subscription interested in it.
+type Event struct {
+	Id        uint64          `json:"id"`
+	Type      string          `json:"type"`
+	StudentId int64           `json:"student_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Subscription registers a URL for events of the listed types, or of
+// every type when Events is empty. Secret signs each delivery.
+type Subscription struct {
+	Id        uint64    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Delivery is one event on its way to one subscription.
+type Delivery struct {
+	Id             uint64    `json:"id"`
+	EventId        uint64    `json:"event_id"`
+	SubscriptionId uint64    `json:"subscription_id"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	// Event and URL are what to send; Secret signs it.
+	Event  Event  `json:"event"`
+	URL    string `json:"url"`
+	Secret string `json:"-"`
+}
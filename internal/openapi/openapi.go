@@ -0,0 +1,247 @@
+// Package openapi describes the API as an OpenAPI 3.1 document, built from
+// the route table and the Go types the handlers read and write.
+package openapi
+
+import (
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const Version = "3.1.0"
+
+// Route is an endpoint as registered with the router and described in the
+// document. Request and Response are values of the JSON body types, such
+// as models.Student{} or []models.Student{}; nil leaves the body out.
+// Bodies that are not JSON are named by RequestType and ResponseType.
+type Route struct {
+	Method  string
+	Path    string
+	Handler http.Handler
+	Summary string
+	Tag     string
+	// Query maps query parameters to their descriptions.
+	Query map[string]string
+	// Params gives the schemas of path and query parameters by name;
+	// those left out are strings.
+	Params      map[string]*Schema
+	Request     any
+	RequestType string
+	// OptionalBody marks a Request the handler can do without.
+	OptionalBody bool
+	Status       int
+	Response     any
+	ResponseType string
+	// Idempotent routes honour the Idempotency-Key header.
+	Idempotent bool
+	Deprecated bool
+}
+
+// Pattern is the ServeMux pattern of r.
+func (r Route) Pattern() string {
+	return r.Method + " " + r.Path
+}
+
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// PathItem holds the operations of one path, keyed by lower case method.
+type PathItem map[string]*Operation
+
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+	Parameters  []Parameter          `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required,omitempty"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+const (
+	jsonType = "application/json"
+	// problemType is the media type of Problem responses (RFC 9457).
+	problemType = "application/problem+json"
+)
+
+// errorSchema names the body every handler answers errors with, and
+// problemSchema the one of requests refused for not matching the document.
+const (
+	errorSchema   = "Error"
+	problemSchema = "Problem"
+)
+
+// pathParam matches the wildcards of a ServeMux pattern, which OpenAPI
+// writes the same way apart from the trailing "...".
+var pathParam = regexp.MustCompile(`\{([^}.]+)(\.\.\.)?\}`)
+
+// Build describes routes. Routes without a method, which ServeMux
+// matches for any, are left out. errorBody is the type of error responses
+// and problemBody that of the 400 responses to requests with invalid
+// parameters or bodies.
+func Build(info Info, routes []Route, errorBody any, problemBody any) *Document {
+	doc := &Document{OpenAPI: Version, Info: info, Paths: make(map[string]*PathItem)}
+	schemas := newSchemaSet()
+	errRef := schemas.named(errorSchema, errorBody)
+	problemRef := schemas.named(problemSchema, problemBody)
+
+	for _, route := range routes {
+		if route.Method == "" {
+			continue
+		}
+		path := pathParam.ReplaceAllString(route.Path, "{$1}")
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			doc.Paths[path] = item
+		}
+		op := &Operation{
+			OperationID: operationID(route.Method, path),
+			Summary:     route.Summary,
+			Deprecated:  route.Deprecated,
+			Responses:   make(map[string]*Response),
+		}
+		if route.Tag != "" {
+			op.Tags = []string{route.Tag}
+		}
+		for _, match := range pathParam.FindAllStringSubmatch(route.Path, -1) {
+			op.Parameters = append(op.Parameters, Parameter{Name: match[1], In: "path", Required: true, Schema: route.param(match[1])})
+		}
+		names := make([]string, 0, len(route.Query))
+		for name := range route.Query {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			op.Parameters = append(op.Parameters, Parameter{Name: name, In: "query", Description: route.Query[name], Schema: route.param(name)})
+		}
+		if route.Idempotent {
+			op.Parameters = append(op.Parameters, Parameter{
+				Name:        "Idempotency-Key",
+				In:          "header",
+				Description: "Repeats of the request with the same key get the first response back instead of running again.",
+				Schema:      &Schema{Type: "string", MaxLength: ptr(255)},
+			})
+		}
+
+		switch {
+		case route.Request != nil:
+			op.RequestBody = &RequestBody{Required: !route.OptionalBody, Content: map[string]*MediaType{jsonType: {Schema: schemas.of(route.Request)}}}
+		case route.RequestType == "multipart/form-data":
+			form := &Schema{Type: "object", Properties: map[string]*Schema{"file": binary("")}, Required: []string{"file"}}
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{route.RequestType: {Schema: form}}}
+		case route.RequestType != "":
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{route.RequestType: {Schema: binary(route.RequestType)}}}
+		}
+
+		status := route.Status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		success := &Response{Description: http.StatusText(status)}
+		switch {
+		case route.Response != nil:
+			success.Content = map[string]*MediaType{jsonType: {Schema: schemas.of(route.Response)}}
+		case route.ResponseType != "":
+			success.Content = map[string]*MediaType{route.ResponseType: {Schema: binary(route.ResponseType)}}
+		}
+		op.Responses[strconv.Itoa(status)] = success
+		if len(op.Parameters) > 0 || op.RequestBody != nil {
+			// Handlers also refuse requests themselves, with the usual error body
+			op.Responses[strconv.Itoa(http.StatusBadRequest)] = &Response{Description: "Invalid request", Content: map[string]*MediaType{
+				problemType: {Schema: problemRef},
+				jsonType:    {Schema: errRef},
+			}}
+		}
+		op.Responses["default"] = &Response{Description: "Error", Content: map[string]*MediaType{jsonType: {Schema: errRef}}}
+
+		(*item)[strings.ToLower(route.Method)] = op
+	}
+	doc.Components.Schemas = schemas.components
+	return doc
+}
+
+// Operation finds the operation of a route by its method and ServeMux path.
+func (d *Document) Operation(method string, path string) *Operation {
+	item, ok := d.Paths[openAPIPath(path)]
+	if !ok {
+		return nil
+	}
+	return (*item)[strings.ToLower(method)]
+}
+
+func (r Route) param(name string) *Schema {
+	if schema, ok := r.Params[name]; ok {
+		return schema
+	}
+	return &Schema{Type: "string"}
+}
+
+func openAPIPath(path string) string {
+	return pathParam.ReplaceAllString(path, "{$1}")
+}
+
+// operationID turns "GET /api/students/{id}/photo" into getStudentsByIdPhoto.
+func operationID(method string, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/api"), "/") {
+		if name, ok := strings.CutPrefix(segment, "{"); ok {
+			b.WriteString("By")
+			segment = strings.TrimSuffix(name, "}")
+		}
+		for _, word := range strings.FieldsFunc(segment, func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) }) {
+			b.WriteString(strings.ToUpper(word[:1]) + word[1:])
+		}
+	}
+	return b.String()
+}
+
+func binary(mediaType string) *Schema {
+	if mediaType == "" {
+		mediaType = "application/octet-stream"
+	}
+	return &Schema{Type: "string", ContentMediaType: mediaType}
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
@@ -0,0 +1,138 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+type errorBody struct {
+	Status string `json:"status"`
+	Error  string `json:"error"`
+}
+
+type student struct {
+	Id        uint64    `json:"id"`
+	Name      string    `json:"name" validate:"required,min=2,max=50"`
+	Email     string    `json:"email" validate:"required,email"`
+	Age       int       `json:"age" validate:"required,gt=0,lte=120"`
+	Grade     string    `json:"grade,omitempty" validate:"oneof=A B C"`
+	Tags      []string  `json:"tags" validate:"max=3,dive,alphanum"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type problemBody struct {
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+}
+
+func testRoutes() []Route {
+	return []Route{
+		{Method: http.MethodGet, Path: "/api/students/{id}", Params: map[string]*Schema{"id": {Type: "integer"}}, Query: map[string]string{"as_of": "a time"}, Response: student{}},
+		{Method: http.MethodPost, Path: "/api/students", Request: student{}, Status: http.StatusCreated, Response: map[string]any{}, Idempotent: true},
+		{Method: http.MethodGet, Path: "/files/{path...}", ResponseType: "application/octet-stream"},
+		{Method: http.MethodPut, Path: "/api/students/{id}/photo", RequestType: "multipart/form-data"},
+		{Path: "/"},
+	}
+}
+
+func TestBuild(t *testing.T) {
+	doc := Build(Info{Title: "Test", Version: "1"}, testRoutes(), errorBody{}, problemBody{})
+	if doc.OpenAPI != Version {
+		t.Errorf("openapi = %q", doc.OpenAPI)
+	}
+	if len(doc.Paths) != 4 {
+		t.Errorf("paths = %v, want 4 without the catch-all", doc.Paths)
+	}
+
+	get := (*doc.Paths["/api/students/{id}"])["get"]
+	if get == nil || get.OperationID != "getStudentsById" {
+		t.Fatalf("get operation = %+v", get)
+	}
+	if len(get.Parameters) != 2 || get.Parameters[0] != (Parameter{Name: "id", In: "path", Required: true, Schema: get.Parameters[0].Schema}) || get.Parameters[1].Name != "as_of" {
+		t.Errorf("parameters = %+v", get.Parameters)
+	}
+	if ref := get.Responses["200"].Content[jsonType].Schema.Ref; ref != "#/components/schemas/student" {
+		t.Errorf("response schema ref = %q", ref)
+	}
+	if ref := get.Responses["default"].Content[jsonType].Schema.Ref; ref != "#/components/schemas/Error" {
+		t.Errorf("error schema ref = %q", ref)
+	}
+
+	post := (*doc.Paths["/api/students"])["post"]
+	if post.Responses["201"] == nil || post.RequestBody == nil {
+		t.Errorf("post operation = %+v", post)
+	}
+	if n := len(post.Parameters); n != 1 || post.Parameters[0].Name != "Idempotency-Key" {
+		t.Errorf("post parameters = %+v", post.Parameters)
+	}
+
+	if bad := post.Responses["400"]; bad == nil || bad.Content[problemType].Schema.Ref != "#/components/schemas/Problem" {
+		t.Errorf("400 response = %+v", bad)
+	}
+
+	if _, ok := doc.Paths["/files/{path}"]; !ok {
+		t.Error("wildcard path not written as {path}")
+	}
+	put := (*doc.Paths["/api/students/{id}/photo"])["put"]
+	if form := put.RequestBody.Content["multipart/form-data"]; form == nil || form.Schema.Properties["file"] == nil {
+		t.Errorf("multipart request body = %+v", put.RequestBody)
+	}
+}
+
+func TestValidateConstraints(t *testing.T) {
+	doc := Build(Info{}, testRoutes(), errorBody{}, problemBody{})
+	schema := doc.Components.Schemas["student"]
+	if schema == nil {
+		t.Fatal("no student component")
+	}
+	if !slices.Equal(schema.Required, []string{"name", "email", "age"}) {
+		t.Errorf("required = %v", schema.Required)
+	}
+	if _, ok := schema.Properties["Secret"]; ok {
+		t.Error("json:\"-\" field described")
+	}
+	props := schema.Properties
+	if name := props["name"]; *name.MinLength != 2 || *name.MaxLength != 50 {
+		t.Errorf("name = %+v", name)
+	}
+	if props["email"].Format != "email" {
+		t.Errorf("email format = %q", props["email"].Format)
+	}
+	if age := props["age"]; age.ExclusiveMinimum == nil || *age.ExclusiveMinimum != 0 || *age.Maximum != 120 {
+		t.Errorf("age = %+v", age)
+	}
+	if grade := props["grade"]; !slices.Equal(grade.Enum, []any{"A", "B", "C"}) {
+		t.Errorf("grade enum = %v", grade.Enum)
+	}
+	if tags := props["tags"]; *tags.MaxItems != 3 || tags.Items.Pattern != "^[a-zA-Z0-9]+$" {
+		t.Errorf("tags = %+v, items %+v", tags, tags.Items)
+	}
+	if data, _ := json.Marshal(props["tags"]); !strings.Contains(string(data), `"type":["array","null"]`) {
+		t.Errorf("tags = %s, want a nullable array", data)
+	}
+	if props["created_at"].Format != "date-time" {
+		t.Errorf("created_at = %+v", props["created_at"])
+	}
+}
+
+func TestHandlers(t *testing.T) {
+	doc := Build(Info{Title: "Test", Version: "1"}, testRoutes(), errorBody{}, problemBody{})
+	w := httptest.NewRecorder()
+	Handler(doc)(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	var got map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil || got["openapi"] != Version {
+		t.Errorf("document = %s, %v", w.Body, err)
+	}
+
+	w = httptest.NewRecorder()
+	Viewer().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `data-spec="/openapi.json"`) {
+		t.Errorf("viewer = %d %.200s", w.Code, w.Body)
+	}
+}
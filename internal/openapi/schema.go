@@ -0,0 +1,262 @@
+package openapi
+
+import (
+	"encoding/json"
+	"path"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Schema is the subset of JSON Schema the document uses.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	ContentMediaType     string             `json:"contentMediaType,omitempty"`
+	ContentEncoding      string             `json:"contentEncoding,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	ExclusiveMinimum     *float64           `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum     *float64           `json:"exclusiveMaximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+	// Nullable also allows null, as encoding/json writes for nil slices
+	// and maps. It is written as a second type.
+	Nullable bool `json:"-"`
+}
+
+func (s Schema) MarshalJSON() ([]byte, error) {
+	type plain Schema
+	if !s.Nullable || s.Type == "" {
+		return json.Marshal(plain(s))
+	}
+	return json.Marshal(struct {
+		plain
+		Type []string `json:"type"`
+	}{plain(s), []string{s.Type, "null"}})
+}
+
+var (
+	timeType       = reflect.TypeFor[time.Time]()
+	rawMessageType = reflect.TypeFor[json.RawMessage]()
+)
+
+// schemaSet turns Go types into schemas. Named structs become components
+// and are referred to by name.
+type schemaSet struct {
+	components map[string]*Schema
+	names      map[reflect.Type]string
+}
+
+func newSchemaSet() *schemaSet {
+	return &schemaSet{components: make(map[string]*Schema), names: make(map[reflect.Type]string)}
+}
+
+func (s *schemaSet) of(v any) *Schema {
+	return s.schema(reflect.TypeOf(v))
+}
+
+// named adds the struct type of v as the component name.
+func (s *schemaSet) named(name string, v any) *Schema {
+	t := reflect.TypeOf(v)
+	s.names[t] = name
+	s.components[name] = s.object(t)
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+func (s *schemaSet) schema(t reflect.Type) *Schema {
+	switch t {
+	case timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case rawMessageType:
+		return &Schema{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.schema(t.Elem())
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", ContentEncoding: "base64", Nullable: t.Kind() == reflect.Slice}
+		}
+		return &Schema{Type: "array", Items: s.schema(t.Elem()), Nullable: t.Kind() == reflect.Slice}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: s.schema(t.Elem()), Nullable: true}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name, ok := s.names[t]
+		if !ok {
+			name = s.componentName(t)
+			s.names[t] = name
+			// Registered before its fields so a type can refer to itself
+			s.components[name] = nil
+			s.components[name] = s.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	}
+	return &Schema{}
+}
+
+// componentName is the type's own name, prefixed with its package when
+// another type took that name first.
+func (s *schemaSet) componentName(t reflect.Type) string {
+	name := t.Name()
+	if _, taken := s.components[name]; !taken {
+		return name
+	}
+	pkg := []rune(path.Base(t.PkgPath()))
+	pkg[0] = unicode.ToUpper(pkg[0])
+	return string(pkg) + name
+}
+
+// object describes a struct by the fields encoding/json would write.
+func (s *schemaSet) object(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	s.fields(schema, t)
+	return schema
+}
+
+func (s *schemaSet) fields(schema *Schema, t reflect.Type) {
+	for i := range t.NumField() {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (!field.IsExported() && !field.Anonymous) {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.fields(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		prop := s.schema(field.Type)
+		if constrain(prop, field.Tag.Get("validate")) {
+			schema.Required = append(schema.Required, name)
+		}
+		schema.Properties[name] = prop
+	}
+}
+
+// constrain adds the rules of a validate tag that JSON Schema can express
+// to schema, and reports whether the tag makes the field required. Rules
+// after "dive" apply to the items of a list.
+func constrain(schema *Schema, tag string) bool {
+	required := false
+	rules := strings.Split(tag, ",")
+	for i, rule := range rules {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "dive":
+			if schema.Items != nil {
+				constrain(schema.Items, strings.Join(rules[i+1:], ","))
+			}
+			return required
+		case "email":
+			schema.Format = "email"
+		case "url", "uri", "http_url":
+			schema.Format = "uri"
+		case "uuid", "uuid4":
+			schema.Format = "uuid"
+		case "alpha":
+			schema.Pattern = "^[a-zA-Z]+$"
+		case "alphanum":
+			schema.Pattern = "^[a-zA-Z0-9]+$"
+		case "numeric":
+			schema.Pattern = "^[-+]?[0-9]+(\\.[0-9]+)?$"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				schema.Enum = append(schema.Enum, enumValue(schema, value))
+			}
+		case "len":
+			bound(schema, param, true, false)
+			bound(schema, param, false, false)
+		case "min", "gte":
+			bound(schema, param, true, false)
+		case "max", "lte":
+			bound(schema, param, false, false)
+		case "gt":
+			bound(schema, param, true, true)
+		case "lt":
+			bound(schema, param, false, true)
+		}
+	}
+	return required
+}
+
+// bound sets a lower or upper limit: on the length of strings, the number
+// of items in arrays and the value of numbers.
+func bound(schema *Schema, param string, lower bool, exclusive bool) {
+	n, err := strconv.ParseFloat(param, 64)
+	if err != nil {
+		return
+	}
+	switch schema.Type {
+	case "string", "array":
+		// Lengths are whole, so an exclusive limit is the next one in
+		size := int(n)
+		if exclusive && lower {
+			size++
+		} else if exclusive {
+			size--
+		}
+		switch {
+		case schema.Type == "string" && lower:
+			schema.MinLength = &size
+		case schema.Type == "string":
+			schema.MaxLength = &size
+		case lower:
+			schema.MinItems = &size
+		default:
+			schema.MaxItems = &size
+		}
+	case "integer", "number":
+		switch {
+		case lower && exclusive:
+			schema.ExclusiveMinimum = &n
+			schema.Minimum = nil
+		case lower:
+			schema.Minimum = &n
+		case exclusive:
+			schema.ExclusiveMaximum = &n
+		default:
+			schema.Maximum = &n
+		}
+	}
+}
+
+func enumValue(schema *Schema, value string) any {
+	if schema.Type == "integer" || schema.Type == "number" {
+		if n, err := strconv.ParseFloat(value, 64); err == nil {
+			return n
+		}
+	}
+	return value
+}
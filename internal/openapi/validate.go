@@ -0,0 +1,328 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// A Violation is one value that does not match the document. In is where
+// it was found: "path", "query", "header", "body" or "response". Pointer
+// names the parameter, or for bodies is a JSON pointer to the value.
+type Violation struct {
+	In      string
+	Pointer string
+	Detail  string
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// patterns caches the compiled Pattern of schemas.
+var patterns sync.Map
+
+// ValidateRequest checks the parameters of r, and body if op takes JSON,
+// against op. Path values are read from r, so it must have been routed.
+func (d *Document) ValidateRequest(op *Operation, r *http.Request, body []byte) []Violation {
+	var violations []Violation
+	query := r.URL.Query()
+	for _, param := range op.Parameters {
+		var value string
+		var present bool
+		switch param.In {
+		case "path":
+			value = r.PathValue(param.Name)
+			present = true
+		case "query":
+			// An empty value, as forms send for blank fields, counts as none
+			value = query.Get(param.Name)
+			present = value != ""
+		case "header":
+			value = r.Header.Get(param.Name)
+			present = value != ""
+		}
+		if !present {
+			if param.Required {
+				violations = append(violations, Violation{param.In, param.Name, "is required"})
+			}
+			continue
+		}
+		violations = append(violations, d.validateParam(param, value)...)
+	}
+
+	if op.RequestBody == nil {
+		return violations
+	}
+	media, ok := op.RequestBody.Content[jsonType]
+	if !ok {
+		return violations
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.RequestBody.Required {
+			violations = append(violations, Violation{"body", "", "a JSON body is required"})
+		}
+		return violations
+	}
+	value, err := decode(body)
+	if err != nil {
+		return append(violations, Violation{"body", "", err.Error()})
+	}
+	return append(violations, d.validate(media.Schema, value, "body", "")...)
+}
+
+// ValidateResponse checks a JSON response body against what op documents
+// for status. Bodies of other media types are not checked.
+func (d *Document) ValidateResponse(op *Operation, status int, header http.Header, body []byte) []Violation {
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil || (mediaType != jsonType && mediaType != problemType) {
+		return nil
+	}
+	res, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		res, ok = op.Responses["default"]
+	}
+	if !ok {
+		return []Violation{{"response", "", fmt.Sprintf("status %d is not documented", status)}}
+	}
+	media, ok := res.Content[mediaType]
+	if !ok {
+		return []Violation{{"response", "", fmt.Sprintf("%s is not documented for status %d", mediaType, status)}}
+	}
+	value, err := decode(body)
+	if err != nil {
+		return []Violation{{"response", "", err.Error()}}
+	}
+	return d.validate(media.Schema, value, "response", "")
+}
+
+// decode reads one JSON value, keeping numbers as written so integers can
+// be told apart from other numbers.
+func decode(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var value any
+	if err := dec.Decode(&value); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("invalid JSON: more than one value")
+	}
+	return value, nil
+}
+
+// validateParam converts the text of a parameter to the type its schema
+// asks for before checking it.
+func (d *Document) validateParam(param Parameter, text string) []Violation {
+	schema := d.resolve(param.Schema)
+	var value any = text
+	switch schema.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(text, 64); err != nil {
+			return []Violation{{param.In, param.Name, "must be " + schema.typeName()}}
+		}
+		value = json.Number(text)
+	case "boolean":
+		b, err := strconv.ParseBool(text)
+		if err != nil {
+			return []Violation{{param.In, param.Name, "must be a boolean"}}
+		}
+		value = b
+	}
+	violations := d.validate(schema, value, param.In, "")
+	for i := range violations {
+		violations[i].Pointer = param.Name
+	}
+	return violations
+}
+
+func (d *Document) resolve(schema *Schema) *Schema {
+	for schema != nil && schema.Ref != "" {
+		schema = d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	return schema
+}
+
+// validate checks value, as decoded by decode, against schema.
+func (d *Document) validate(schema *Schema, value any, in string, pointer string) []Violation {
+	schema = d.resolve(schema)
+	if schema == nil {
+		return nil
+	}
+	fail := func(format string, args ...any) []Violation {
+		return []Violation{{in, pointer, fmt.Sprintf(format, args...)}}
+	}
+	if value == nil {
+		if schema.Type == "" || schema.Nullable {
+			return nil
+		}
+		return fail("must be %s, not null", schema.typeName())
+	}
+	if len(schema.Enum) > 0 && !slices.ContainsFunc(schema.Enum, func(option any) bool { return sameValue(option, value) }) {
+		return fail("must be one of %s", enumList(schema.Enum))
+	}
+
+	switch schema.Type {
+	case "object":
+		object, ok := value.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		var violations []Violation
+		for _, name := range schema.Required {
+			if _, ok := object[name]; !ok {
+				violations = append(violations, Violation{in, pointer + "/" + escape(name), "is required"})
+			}
+		}
+		names := make([]string, 0, len(object))
+		for name := range object {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			prop, ok := schema.Properties[name]
+			if !ok {
+				prop = schema.AdditionalProperties
+			}
+			violations = append(violations, d.validate(prop, object[name], in, pointer+"/"+escape(name))...)
+		}
+		return violations
+	case "array":
+		items, ok := value.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		if schema.MinItems != nil && len(items) < *schema.MinItems {
+			return fail("must have at least %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(items) > *schema.MaxItems {
+			return fail("must have at most %d items", *schema.MaxItems)
+		}
+		var violations []Violation
+		for i, item := range items {
+			violations = append(violations, d.validate(schema.Items, item, in, pointer+"/"+strconv.Itoa(i))...)
+		}
+		return violations
+	case "string":
+		s, ok := value.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		length := utf8.RuneCountInString(s)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			return fail("must be at least %d characters long", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			return fail("must be at most %d characters long", *schema.MaxLength)
+		}
+		if schema.Pattern != "" && !matches(schema.Pattern, s) {
+			return fail("must match %s", schema.Pattern)
+		}
+		if !validFormat(schema.Format, s) {
+			return fail("must be a valid %s", schema.Format)
+		}
+	case "integer", "number":
+		n, ok := value.(json.Number)
+		if !ok {
+			return fail("must be %s", schema.typeName())
+		}
+		f, err := n.Float64()
+		if err != nil {
+			return fail("must be %s", schema.typeName())
+		}
+		if schema.Type == "integer" {
+			if _, err := n.Int64(); err != nil {
+				return fail("must be an integer")
+			}
+		}
+		switch {
+		case schema.Minimum != nil && f < *schema.Minimum:
+			return fail("must be at least %v", *schema.Minimum)
+		case schema.ExclusiveMinimum != nil && f <= *schema.ExclusiveMinimum:
+			return fail("must be greater than %v", *schema.ExclusiveMinimum)
+		case schema.Maximum != nil && f > *schema.Maximum:
+			return fail("must be at most %v", *schema.Maximum)
+		case schema.ExclusiveMaximum != nil && f >= *schema.ExclusiveMaximum:
+			return fail("must be less than %v", *schema.ExclusiveMaximum)
+		}
+	case "boolean":
+		if _, ok := value.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	}
+	return nil
+}
+
+func (s *Schema) typeName() string {
+	switch s.Type {
+	case "":
+		return "a value"
+	case "integer", "array", "object":
+		return "an " + s.Type
+	}
+	return "a " + s.Type
+}
+
+func matches(pattern string, s string) bool {
+	re, ok := patterns.Load(pattern)
+	if !ok {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return true
+		}
+		re, _ = patterns.LoadOrStore(pattern, compiled)
+	}
+	return re.(*regexp.Regexp).MatchString(s)
+}
+
+func validFormat(format string, s string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	case "uri":
+		u, err := url.Parse(s)
+		return err == nil && u.IsAbs()
+	case "uuid":
+		return uuidPattern.MatchString(s)
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, s)
+		return err == nil
+	}
+	return true
+}
+
+// sameValue compares an enum option with a decoded value; numbers are
+// equal when their values are.
+func sameValue(option any, value any) bool {
+	if n, ok := value.(json.Number); ok {
+		f, err := n.Float64()
+		o, isNumber := option.(float64)
+		return err == nil && isNumber && f == o
+	}
+	return option == value
+}
+
+func enumList(enum []any) string {
+	options := make([]string, len(enum))
+	for i, option := range enum {
+		options[i] = fmt.Sprint(option)
+	}
+	return strings.Join(options, ", ")
+}
+
+// escape writes name as a JSON pointer token (RFC 6901).
+func escape(name string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(name, "~", "~0"), "/", "~1")
+}
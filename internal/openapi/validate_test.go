@@ -0,0 +1,89 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func validateRequest(t *testing.T, doc *Document, method string, target string, body string) []Violation {
+	t.Helper()
+	mux := http.NewServeMux()
+	var violations []Violation
+	for _, route := range testRoutes() {
+		if route.Method == "" {
+			continue
+		}
+		mux.HandleFunc(route.Pattern(), func(w http.ResponseWriter, r *http.Request) {
+			violations = doc.ValidateRequest(doc.Operation(route.Method, route.Path), r, []byte(body))
+		})
+	}
+	mux.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(method, target, strings.NewReader(body)))
+	return violations
+}
+
+func TestValidateRequest(t *testing.T) {
+	doc := Build(Info{}, testRoutes(), errorBody{}, problemBody{})
+	tests := []struct {
+		method, target, body string
+		want                 []string
+	}{
+		{"GET", "/api/students/1", "", nil},
+		{"GET", "/api/students/x", "", []string{"path id must be an integer"}},
+		{"GET", "/api/students/1?as_of=", "", nil},
+		{"POST", "/api/students", `{"name": "Asha", "email": "asha@example.com", "age": 20, "tags": ["a1"]}`, nil},
+		{"POST", "/api/students", ``, []string{"body  a JSON body is required"}},
+		{"POST", "/api/students", `{"name": `, []string{"body  invalid JSON: unexpected EOF"}},
+		{"POST", "/api/students", `{} {}`, []string{"body  invalid JSON: more than one value"}},
+		{"POST", "/api/students", `[]`, []string{"body  must be an object"}},
+		{"POST", "/api/students", `{"name": "A", "email": "nope", "age": 0, "grade": "D", "tags": ["a", "b!"]}`, []string{
+			"body /age must be greater than 0",
+			"body /email must be a valid email",
+			"body /grade must be one of A, B, C",
+			"body /name must be at least 2 characters long",
+			"body /tags/1 must match ^[a-zA-Z0-9]+$",
+		}},
+		{"POST", "/api/students", `{"name": "Asha", "age": 20.5, "tags": null}`, []string{
+			"body /email is required",
+			"body /age must be an integer",
+		}},
+	}
+	for _, tt := range tests {
+		violations := validateRequest(t, doc, tt.method, tt.target, tt.body)
+		var got []string
+		for _, v := range violations {
+			got = append(got, v.In+" "+v.Pointer+" "+v.Detail)
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("%s %s %s:\n got %q\nwant %q", tt.method, tt.target, tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestValidateResponse(t *testing.T) {
+	doc := Build(Info{}, testRoutes(), errorBody{}, problemBody{})
+	op := doc.Operation(http.MethodGet, "/api/students/{id}")
+	header := http.Header{"Content-Type": {"application/json; charset=utf-8"}}
+	tests := []struct {
+		status int
+		body   string
+		ok     bool
+	}{
+		{http.StatusOK, `{"id": 1, "name": "Asha", "email": "asha@example.com", "age": 20, "tags": null, "created_at": "2024-01-02T03:04:05Z"}`, true},
+		{http.StatusOK, `{"id": 1, "name": "Asha", "email": "asha@example.com", "age": 20, "created_at": "yesterday"}`, false},
+		{http.StatusOK, `{"id": -1, "name": "Asha", "email": "asha@example.com", "age": 20}`, false},
+		{http.StatusNotFound, `{"status": "Error", "error": "not found"}`, true},
+		{http.StatusNotFound, `{"status": 1}`, false},
+	}
+	for _, tt := range tests {
+		violations := doc.ValidateResponse(op, tt.status, header, []byte(tt.body))
+		if ok := len(violations) == 0; ok != tt.ok {
+			t.Errorf("%d %s: violations %+v", tt.status, tt.body, violations)
+		}
+	}
+	// Only JSON is checked
+	if violations := doc.ValidateResponse(op, http.StatusOK, http.Header{"Content-Type": {"text/plain"}}, []byte("hello")); len(violations) > 0 {
+		t.Errorf("text response: %+v", violations)
+	}
+}
@@ -0,0 +1,35 @@
+package openapi
+
+import (
+	"embed"
+	"encoding/json"
+	"io/fs"
+	"net/http"
+)
+
+//go:embed viewer
+var viewerFiles embed.FS
+
+// Handler serves doc as JSON. The document does not change once built, so
+// it is encoded only once.
+func Handler(doc *Document) http.HandlerFunc {
+	data, err := json.Marshal(doc)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	}
+}
+
+// Viewer serves a page that lists the operations of /openapi.json and
+// lets them be tried from the browser. Mount it with the prefix stripped.
+func Viewer() http.Handler {
+	files, err := fs.Sub(viewerFiles, "viewer")
+	if err != nil {
+		panic(err)
+	}
+	return http.FileServerFS(files)
+}
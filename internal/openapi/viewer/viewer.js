@@ -0,0 +1,272 @@
+// Renders the OpenAPI document named by #operations[data-spec] as a list
+// of operations, each with its parameters, example bodies and a form that
+// sends the request from the browser.
+(function () {
+  "use strict";
+
+  var container = document.getElementById("operations");
+  var spec;
+
+  function el(tag, attrs, children) {
+    var node = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (name) {
+      if (name === "text") node.textContent = attrs[name];
+      else if (name === "class") node.className = attrs[name];
+      else node.setAttribute(name, attrs[name]);
+    });
+    (children || []).forEach(function (child) {
+      if (child) node.appendChild(typeof child === "string" ? document.createTextNode(child) : child);
+    });
+    return node;
+  }
+
+  function resolve(schema) {
+    var seen = 0;
+    while (schema && schema.$ref && seen++ < 32) {
+      var name = schema.$ref.replace("#/components/schemas/", "");
+      schema = (spec.components.schemas || {})[name];
+    }
+    return schema || {};
+  }
+
+  // example builds a value that matches schema, for request bodies and
+  // to show what a response looks like.
+  function example(schema, depth) {
+    schema = resolve(schema);
+    if ((depth || 0) > 6) return null;
+    if (schema.enum && schema.enum.length) return schema.enum[0];
+    // Nullable schemas list "null" as a second type
+    var type = Array.isArray(schema.type) ? schema.type[0] : schema.type;
+    switch (type) {
+      case "object":
+        var value = {};
+        Object.keys(schema.properties || {}).forEach(function (name) {
+          value[name] = example(schema.properties[name], (depth || 0) + 1);
+        });
+        if (schema.additionalProperties && !schema.properties) {
+          value.key = example(schema.additionalProperties, (depth || 0) + 1);
+        }
+        return value;
+      case "array":
+        return [example(schema.items, (depth || 0) + 1)];
+      case "integer":
+      case "number":
+        if (schema.minimum !== undefined) return schema.minimum;
+        if (schema.exclusiveMinimum !== undefined) return schema.exclusiveMinimum + 1;
+        return 0;
+      case "boolean":
+        return false;
+      case "string":
+        if (schema.format === "date-time") return new Date(0).toISOString();
+        if (schema.format === "email") return "user@example.com";
+        if (schema.format === "uri") return "https://example.com/";
+        if (schema.format === "uuid") return "3fa85f64-5717-4562-b3fc-2c963f66afa6";
+        return "string";
+    }
+    return null;
+  }
+
+  function pretty(value) {
+    return JSON.stringify(value, null, 2);
+  }
+
+  function jsonContent(content) {
+    return content && content["application/json"];
+  }
+
+  function renderParameters(op, inputs) {
+    var params = op.parameters || [];
+    if (!params.length) return null;
+    var rows = params.map(function (param) {
+      var input = el("input", { type: "text", placeholder: param.name });
+      inputs.push({ param: param, input: input });
+      return el("tr", {}, [
+        el("td", {}, [
+          el("div", { class: "param-name", text: param.name }),
+          param.required ? el("div", { class: "required", text: "* required" }) : null,
+          el("div", { class: "in", text: "(" + param.in + ")" })
+        ]),
+        el("td", {}, [param.description ? el("div", { text: param.description }) : null, input])
+      ]);
+    });
+    return el("div", {}, [
+      el("h3", { text: "Parameters" }),
+      el("table", {}, [el("tr", {}, [el("th", { text: "Name" }), el("th", { text: "Value" })])].concat(rows))
+    ]);
+  }
+
+  function renderRequestBody(op, body) {
+    if (!op.requestBody) return null;
+    var content = op.requestBody.content || {};
+    var type = Object.keys(content)[0];
+    body.type = type;
+    if (type === "application/json") {
+      body.input = el("textarea", {});
+      body.input.value = pretty(example(content[type].schema));
+    } else {
+      body.input = el("input", { type: "file" });
+    }
+    return el("div", {}, [el("h3", {}, ["Request body ", el("span", { class: "schema-name", text: type })]), body.input]);
+  }
+
+  function renderResponses(op) {
+    var rows = Object.keys(op.responses || {}).map(function (code) {
+      var res = op.responses[code];
+      var media = jsonContent(res.content);
+      var types = Object.keys(res.content || {});
+      return el("tr", {}, [
+        el("td", { class: "param-name", text: code }),
+        el("td", {}, [
+          el("div", { text: res.description }),
+          media ? el("pre", { text: pretty(example(media.schema)) }) : null,
+          !media && types.length ? el("div", { class: "schema-name", text: types.join(", ") }) : null
+        ])
+      ]);
+    });
+    return el("div", {}, [
+      el("h3", { text: "Responses" }),
+      el("table", {}, [el("tr", {}, [el("th", { text: "Code" }), el("th", { text: "Description" })])].concat(rows))
+    ]);
+  }
+
+  function execute(method, path, inputs, body, output, button) {
+    var url = path;
+    var query = new URLSearchParams();
+    var headers = {};
+    for (var i = 0; i < inputs.length; i++) {
+      var param = inputs[i].param;
+      var value = inputs[i].input.value;
+      if (value === "") {
+        if (param.required) {
+          output.replaceChildren(el("div", { class: "status fail", text: param.name + " is required" }));
+          return;
+        }
+        continue;
+      }
+      if (param.in === "path") url = url.replace("{" + param.name + "}", encodeURIComponent(value));
+      else if (param.in === "query") query.append(param.name, value);
+      else if (param.in === "header") headers[param.name] = value;
+    }
+    if (query.toString()) url += "?" + query.toString();
+
+    var init = { method: method.toUpperCase(), headers: headers };
+    if (body.input) {
+      if (body.type === "application/json") {
+        headers["Content-Type"] = body.type;
+        init.body = body.input.value;
+      } else if (body.input.files && body.input.files[0]) {
+        if (body.type === "multipart/form-data") {
+          init.body = new FormData();
+          init.body.append("file", body.input.files[0]);
+        } else {
+          headers["Content-Type"] = body.type;
+          init.body = body.input.files[0];
+        }
+      }
+    }
+
+    button.disabled = true;
+    var status;
+    fetch(url, init)
+      .then(function (res) {
+        status = res;
+        return res.text();
+      })
+      .then(function (text) {
+        try {
+          text = pretty(JSON.parse(text));
+        } catch (e) {}
+        output.replaceChildren(
+          el("h3", {}, ["Response ", el("span", { class: "status " + (status.ok ? "ok" : "fail"), text: status.status + " " + status.statusText })]),
+          el("div", { class: "schema-name", text: "GET" === init.method ? url : init.method + " " + url }),
+          el("pre", { text: text || "(empty)" })
+        );
+      })
+      .catch(function (err) {
+        output.replaceChildren(el("div", { class: "status fail", text: String(err) }));
+      })
+      .then(function () {
+        button.disabled = false;
+      });
+  }
+
+  function renderOperation(method, path, op) {
+    var inputs = [];
+    var body = {};
+    var output = el("div", {});
+    var streams = Object.keys((op.responses["200"] || {}).content || {}).indexOf("text/event-stream") >= 0;
+    var button = el("button", { type: "button", text: "Execute" });
+    button.addEventListener("click", function () {
+      execute(method, path, inputs, body, output, button);
+    });
+    var details = el("details", { class: "op " + method + (op.deprecated ? " deprecated" : "") }, [
+      el("summary", {}, [
+        el("span", { class: "method", text: method }),
+        el("span", { class: "path", text: path }),
+        el("span", { class: "summary", text: op.summary || "" })
+      ]),
+      el("div", { class: "body" }, [
+        renderParameters(op, inputs),
+        renderRequestBody(op, body),
+        renderResponses(op),
+        streams ? el("p", { class: "note", text: "This endpoint streams; open it with an EventSource instead." }) : button,
+        output
+      ])
+    ]);
+    details.dataset.search = (path + " " + (op.summary || "")).toLowerCase();
+    return details;
+  }
+
+  function render() {
+    document.title = spec.info.title;
+    document.getElementById("title").textContent = spec.info.title;
+    document.getElementById("version").textContent = spec.info.version;
+
+    var groups = {};
+    var order = [];
+    Object.keys(spec.paths).sort().forEach(function (path) {
+      var item = spec.paths[path];
+      ["get", "post", "put", "patch", "delete", "head", "options"].forEach(function (method) {
+        var op = item[method];
+        if (!op) return;
+        var tag = (op.tags && op.tags[0]) || "default";
+        if (!groups[tag]) {
+          groups[tag] = [];
+          order.push(tag);
+        }
+        groups[tag].push(renderOperation(method, path, op));
+      });
+    });
+    container.replaceChildren();
+    order.forEach(function (tag) {
+      var section = el("section", {}, [el("h2", { class: "tag", text: tag })].concat(groups[tag]));
+      container.appendChild(section);
+    });
+  }
+
+  document.getElementById("filter").addEventListener("input", function (event) {
+    var term = event.target.value.toLowerCase();
+    container.querySelectorAll("section").forEach(function (section) {
+      var visible = 0;
+      section.querySelectorAll("details.op").forEach(function (op) {
+        var match = op.dataset.search.indexOf(term) >= 0;
+        op.hidden = !match;
+        if (match) visible++;
+      });
+      section.hidden = visible === 0;
+    });
+  });
+
+  fetch(container.dataset.spec)
+    .then(function (res) {
+      if (!res.ok) throw new Error(res.status + " " + res.statusText);
+      return res.json();
+    })
+    .then(function (doc) {
+      spec = doc;
+      render();
+    })
+    .catch(function (err) {
+      container.replaceChildren(el("p", { class: "note", text: "Could not load " + container.dataset.spec + ": " + err.message }));
+    });
+})();
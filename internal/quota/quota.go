@@ -0,0 +1,73 @@
+// Package quota enforces the per-student and total upload byte limits.
+// Usage is read from the file metadata and the bytes unfinished resumable
+// uploads have received, so concurrent uploads may overshoot a limit by at
+// most what they stream at the same time.
+package quota
+
+import (
+	"errors"
+	"io"
+	"math"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+var ErrExceeded = errors.New("upload exceeds storage quota")
+
+// Unlimited is returned by Remaining when no quota applies.
+const Unlimited = math.MaxInt64
+
+// Remaining returns how many more bytes may be stored for studentID, taking
+// the total quota into account as well. Bytes held by unfinished resumable
+// uploads count as used. Unowned files (studentID 0) are only subject to
+// the total quota.
+func Remaining(storage storage.Storage, cfg config.Quota, studentID int64) (int64, error) {
+	remaining := int64(Unlimited)
+	if cfg.Total > 0 {
+		usage, err := storage.TotalFileUsage()
+		if err != nil {
+			return 0, err
+		}
+		pending, err := storage.TotalUploadUsage()
+		if err != nil {
+			return 0, err
+		}
+		remaining = min(remaining, max(cfg.Total-usage.Bytes-pending.Bytes, 0))
+	}
+	if cfg.PerStudent > 0 && studentID != 0 {
+		usage, err := storage.StudentFileUsage(studentID)
+		if err != nil {
+			return 0, err
+		}
+		pending, err := storage.StudentUploadUsage(studentID)
+		if err != nil {
+			return 0, err
+		}
+		remaining = min(remaining, max(cfg.PerStudent-usage.Bytes-pending.Bytes, 0))
+	}
+	return remaining, nil
+}
+
+// Limit wraps r so that reading more than remaining bytes fails with
+// ErrExceeded, aborting the upload it feeds.
+func Limit(r io.Reader, remaining int64) io.Reader {
+	if remaining == Unlimited {
+		return r
+	}
+	return &limitedReader{r: r, remaining: remaining}
+}
+
+type limitedReader struct {
+	r         io.Reader
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		return n, ErrExceeded
+	}
+	return n, err
+}
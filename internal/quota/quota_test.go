@@ -0,0 +1,99 @@
+package quota
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+)
+
+func TestRemaining(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := memory.New()
+	asha, err := store.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ravi, err := store.CreateStudent("Ravi", "ravi@example.com", 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []struct {
+		owner   int64
+		content string
+	}{
+		{asha, "0123456789"},
+		{ravi, "abcde"},
+		{0, "xyz"},
+	} {
+		if _, err := store.StudentFileUpload10MB(f.owner, "f.txt", "text/plain", []byte(f.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		cfg     config.Quota
+		student int64
+		want    int64
+	}{
+		{"no quota", config.Quota{}, asha, Unlimited},
+		{"per student", config.Quota{PerStudent: 25}, asha, 15},
+		{"per student of another", config.Quota{PerStudent: 25}, ravi, 20},
+		{"per student does not limit unowned files", config.Quota{PerStudent: 25}, 0, Unlimited},
+		{"total", config.Quota{Total: 30}, 0, 12},
+		{"tighter of both", config.Quota{PerStudent: 25, Total: 30}, asha, 12},
+		{"used up", config.Quota{PerStudent: 8}, asha, 0},
+	}
+	for _, tt := range tests {
+		got, err := Remaining(store, tt.cfg, tt.student)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: Remaining = %d, %v; want %d", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestLimit(t *testing.T) {
+	tests := []struct {
+		name      string
+		remaining int64
+		err       error
+	}{
+		{"unlimited", Unlimited, nil},
+		{"room to spare", 100, nil},
+		{"exact fit", 5, nil},
+		{"one byte short", 4, ErrExceeded},
+		{"nothing left", 0, ErrExceeded},
+	}
+	for _, tt := range tests {
+		_, err := io.ReadAll(Limit(strings.NewReader("hello"), tt.remaining))
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: read error %v, want %v", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRemainingCountsUploads(t *testing.T) {
+	t.Chdir(t.TempDir())
+	store := memory.New()
+	asha, err := store.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	upload, err := store.CreateUpload(asha, "part.bin", "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.WriteUploadChunk(upload.Id, 0, strings.NewReader("0123")); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := Remaining(store, config.Quota{PerStudent: 25}, asha); err != nil || got != 21 {
+		t.Errorf("per student: Remaining = %d, %v; want 21", got, err)
+	}
+	if got, err := Remaining(store, config.Quota{Total: 30}, 0); err != nil || got != 26 {
+		t.Errorf("total: Remaining = %d, %v; want 26", got, err)
+	}
+}
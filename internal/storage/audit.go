@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/surajNirala/student-api/internal/models"
+)
+
+// Audited actions on students.
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditPhoto  = "photo"
+	AuditRevert = "revert"
+)
+
+const EntityStudent = "student"
+
+// Change is the value of a field before and after a change; From is nil
+// for a created record and To for a deleted one.
+type Change struct {
+	From any `json:"from"`
+	To   any `json:"to"`
+}
+
+// StudentChanges lists the fields that differ between before and after.
+// Pass nil as before for a new student and as after for a deleted one.
+func StudentChanges(before, after *models.Student) json.RawMessage {
+	fields := func(s *models.Student) map[string]any {
+		if s == nil {
+			return map[string]any{"name": nil, "email": nil, "age": nil}
+		}
+		return map[string]any{"name": s.Name, "email": s.Email, "age": s.Age}
+	}
+	from, to := fields(before), fields(after)
+	changes := make(map[string]Change)
+	for field := range from {
+		if from[field] != to[field] {
+			changes[field] = Change{From: from[field], To: to[field]}
+		}
+	}
+	data, _ := json.Marshal(changes)
+	return data
+}
+
+// PhotoChanges describes replacing a student's photo by the file ids of
+// the original images; previous is zero when the student had none.
+func PhotoChanges(previous, current uint64) json.RawMessage {
+	var from any
+	if previous != 0 {
+		from = previous
+	}
+	data, _ := json.Marshal(map[string]Change{"original_file_id": {From: from, To: current}})
+	return data
+}
+
+// NewAuditEntry fills in the actor and request id recorded in ctx.
+func NewAuditEntry(ctx context.Context, action string, entityType string, entityID int64, changes json.RawMessage) models.AuditEntry {
+	return models.AuditEntry{
+		Actor:      ActorFromContext(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityId:   entityID,
+		Changes:    changes,
+		RequestId:  RequestIDFromContext(ctx),
+	}
+}
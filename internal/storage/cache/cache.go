@@ -0,0 +1,224 @@
+// Package cache keeps recently read students in memory in front of any
+// storage backend.
+package cache
+
+import (
+	"container/list"
+	"context"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+const listKey = "students"
+
+// Stats counts lookups since the cache was created.
+type Stats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+// Cache serves student reads from a least recently used cache whose
+// entries expire after a TTL, and forgets the affected entries on every
+// write that goes through it. Writes made by other instances of the API
+// are only seen once the TTL runs out. Misses are read from the primary,
+// so a lagging read replica cannot put a copy older than a write made
+// through the cache back into it.
+type Cache struct {
+	storage.Storage
+	lru *lru
+	ctx context.Context
+}
+
+// New wraps s with a cache sized and timed by cfg.
+func New(s storage.Storage, cfg config.Cache) *Cache {
+	return &Cache{Storage: s, lru: newLRU(cfg.Size, cfg.TTL), ctx: context.Background()}
+}
+
+func (c *Cache) Unwrap() storage.Storage {
+	return c.Storage
+}
+
+// WithContext scopes the wrapped storage to ctx but keeps sharing the
+// cache. Inside a transaction reads skip the cache, since what they see
+// may still be rolled back.
+func (c *Cache) WithContext(ctx context.Context) storage.Storage {
+	return &Cache{Storage: c.Storage.WithContext(ctx), lru: c.lru, ctx: ctx}
+}
+
+func (c *Cache) Stats() Stats {
+	return c.lru.stats()
+}
+
+// primary returns the wrapped storage reading from the primary, to fill the cache from.
+func (c *Cache) primary() storage.Storage {
+	return c.Storage.WithContext(storage.ContextWithPrimaryReads(c.ctx))
+}
+
+func studentKey(id int64) string {
+	return "student:" + strconv.FormatInt(id, 10)
+}
+
+func (c *Cache) StudentList() ([]models.Student, error) {
+	if storage.InTransaction(c.ctx) {
+		return c.Storage.StudentList()
+	}
+	if cached, ok := c.lru.get(listKey); ok {
+		return slices.Clone(cached.([]models.Student)), nil
+	}
+	generation := c.lru.generation()
+	list, err := c.primary().StudentList()
+	if err != nil {
+		return list, err
+	}
+	c.lru.add(listKey, slices.Clone(list), generation)
+	return list, nil
+}
+
+func (c *Cache) GetStudentByID(id int64) (models.Student, error) {
+	if storage.InTransaction(c.ctx) {
+		return c.Storage.GetStudentByID(id)
+	}
+	key := studentKey(id)
+	if cached, ok := c.lru.get(key); ok {
+		return cached.(models.Student), nil
+	}
+	generation := c.lru.generation()
+	student, err := c.primary().GetStudentByID(id)
+	if err != nil {
+		return student, err
+	}
+	c.lru.add(key, student, generation)
+	return student, nil
+}
+
+func (c *Cache) CreateStudent(name string, email string, age int) (int64, error) {
+	defer c.remove(listKey)
+	return c.Storage.CreateStudent(name, email, age)
+}
+
+func (c *Cache) UpdateStudentByID(name string, email string, age int, id int64) (string, error) {
+	defer c.remove(listKey, studentKey(id))
+	return c.Storage.UpdateStudentByID(name, email, age, id)
+}
+
+func (c *Cache) DeleteStudentByID(id int64) (string, error) {
+	defer c.remove(listKey, studentKey(id))
+	return c.Storage.DeleteStudentByID(id)
+}
+
+func (c *Cache) RevertStudent(studentID int64, revision int) (models.StudentRevision, error) {
+	defer c.remove(listKey, studentKey(studentID))
+	return c.Storage.RevertStudent(studentID, revision)
+}
+
+// SetStudentPhoto changes the photo URL shown with the student.
+func (c *Cache) SetStudentPhoto(studentID int64, photo models.Photo) error {
+	defer c.remove(listKey, studentKey(studentID))
+	return c.Storage.SetStudentPhoto(studentID, photo)
+}
+
+// remove forgets keys, and inside a transaction forgets them again once
+// it commits, dropping whatever was read from before the commit meanwhile.
+func (c *Cache) remove(keys ...string) {
+	c.lru.remove(keys...)
+	storage.AfterCommit(c.ctx, func() { c.lru.remove(keys...) })
+}
+
+type entry struct {
+	key     string
+	value   any
+	expires time.Time
+}
+
+type lru struct {
+	mu        sync.Mutex
+	size      int
+	ttl       time.Duration
+	order     *list.List
+	items     map[string]*list.Element
+	gen       uint64
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
+}
+
+func newLRU(size int, ttl time.Duration) *lru {
+	return &lru{size: size, ttl: ttl, order: list.New(), items: make(map[string]*list.Element)}
+}
+
+func (l *lru) get(key string) (any, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	elem, ok := l.items[key]
+	if ok && time.Now().After(elem.Value.(*entry).expires) {
+		l.order.Remove(elem)
+		delete(l.items, key)
+		ok = false
+	}
+	if !ok {
+		l.misses.Add(1)
+		return nil, false
+	}
+	l.hits.Add(1)
+	l.order.MoveToFront(elem)
+	return elem.Value.(*entry).value, true
+}
+
+// generation identifies the state of the cache before a backend read, so
+// add can tell whether a write invalidated entries while the read ran.
+func (l *lru) generation() uint64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.gen
+}
+
+// add stores value unless something was removed since generation, in
+// which case value may predate that write.
+func (l *lru) add(key string, value any, generation uint64) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if generation != l.gen {
+		return
+	}
+	expires := time.Now().Add(l.ttl)
+	if elem, ok := l.items[key]; ok {
+		elem.Value = &entry{key: key, value: value, expires: expires}
+		l.order.MoveToFront(elem)
+		return
+	}
+	l.items[key] = l.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for l.order.Len() > l.size {
+		oldest := l.order.Back()
+		l.order.Remove(oldest)
+		delete(l.items, oldest.Value.(*entry).key)
+		l.evictions.Add(1)
+	}
+}
+
+func (l *lru) remove(keys ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.gen++
+	for _, key := range keys {
+		if elem, ok := l.items[key]; ok {
+			l.order.Remove(elem)
+			delete(l.items, key)
+		}
+	}
+}
+
+func (l *lru) stats() Stats {
+	l.mu.Lock()
+	entries := l.order.Len()
+	l.mu.Unlock()
+	return Stats{Hits: l.hits.Load(), Misses: l.misses.Load(), Evictions: l.evictions.Load(), Entries: entries}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+	"github.com/surajNirala/student-api/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New(memory.New(), config.Cache{Size: 100, TTL: time.Minute})
+	})
+}
+
+func TestEviction(t *testing.T) {
+	c := New(memory.New(), config.Cache{Size: 2, TTL: time.Minute})
+	var ids []int64
+	for _, name := range []string{"a", "b", "c"} {
+		id, err := c.CreateStudent(name, name+"@example.com", 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+		if _, err := c.GetStudentByID(id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// The first student was pushed out by the third
+	if _, err := c.GetStudentByID(ids[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetStudentByID(ids[2]); err != nil {
+		t.Fatal(err)
+	}
+	stats := c.Stats()
+	if stats.Hits != 1 || stats.Misses != 4 || stats.Evictions != 2 || stats.Entries != 2 {
+		t.Errorf("Stats() = %+v, want 1 hit, 4 misses, 2 evictions, 2 entries", stats)
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	c := New(memory.New(), config.Cache{Size: 10, TTL: 10 * time.Millisecond})
+	id, err := c.CreateStudent("a", "a@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.GetStudentByID(id)
+	c.GetStudentByID(id)
+	time.Sleep(20 * time.Millisecond)
+	c.GetStudentByID(id)
+	if stats := c.Stats(); stats.Hits != 1 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 1 hit and 2 misses", stats)
+	}
+}
+
+func TestWriteThroughScopedStorageInvalidates(t *testing.T) {
+	c := New(memory.New(), config.Cache{Size: 10, TTL: time.Minute})
+	id, err := c.CreateStudent("a", "a@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetStudentByID(id); err != nil {
+		t.Fatal(err)
+	}
+	scoped := c.WithContext(t.Context())
+	if _, err := scoped.UpdateStudentByID("b", "b@example.com", 21, id); err != nil {
+		t.Fatal(err)
+	}
+	student, err := c.GetStudentByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if student.Name != "b" {
+		t.Errorf("cache served %q after an update through a scoped storage", student.Name)
+	}
+}
+
+// lagging serves reads from a replica that never catches up, unless the
+// context asks for the primary.
+type lagging struct {
+	storage.Storage
+	replica storage.Storage
+	ctx     context.Context
+}
+
+func (l *lagging) WithContext(ctx context.Context) storage.Storage {
+	return &lagging{Storage: l.Storage.WithContext(ctx), replica: l.replica, ctx: ctx}
+}
+
+func (l *lagging) read() storage.Storage {
+	if storage.PrimaryReads(l.ctx) {
+		return l.Storage
+	}
+	return l.replica
+}
+
+func (l *lagging) StudentList() ([]models.Student, error) {
+	return l.read().StudentList()
+}
+
+func (l *lagging) GetStudentByID(id int64) (models.Student, error) {
+	return l.read().GetStudentByID(id)
+}
+
+func TestFillsFromPrimary(t *testing.T) {
+	primary, replica := memory.New(), memory.New()
+	for _, s := range []storage.Storage{primary, replica} {
+		if _, err := s.CreateStudent("a", "a@example.com", 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+	c := New(&lagging{Storage: primary, replica: replica, ctx: context.Background()}, config.Cache{Size: 10, TTL: time.Minute})
+	if _, err := c.UpdateStudentByID("b", "b@example.com", 21, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	// The miss and the hit after it both see the update
+	for range 2 {
+		student, err := c.GetStudentByID(1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if student.Name != "b" {
+			t.Errorf("GetStudentByID served %q from before the update", student.Name)
+		}
+		list, err := c.StudentList()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 1 || list[0].Name != "b" {
+			t.Errorf("StudentList served %+v from before the update", list)
+		}
+	}
+	if stats := c.Stats(); stats.Hits != 2 || stats.Misses != 2 {
+		t.Errorf("Stats() = %+v, want 2 hits and 2 misses", stats)
+	}
+}
@@ -0,0 +1,62 @@
+package storage
+
+import "context"
+
+type clientKey struct{}
+
+// ContextWithClient records who is making the request, so a backend can
+// give one client a consistent view of its own writes.
+func ContextWithClient(ctx context.Context, client string) context.Context {
+	return context.WithValue(ctx, clientKey{}, client)
+}
+
+// ClientFromContext returns the client recorded by ContextWithClient, or "".
+func ClientFromContext(ctx context.Context) string {
+	client, _ := ctx.Value(clientKey{}).(string)
+	return client
+}
+
+type actorKey struct{}
+
+// ContextWithActor records who a change is made by, for the audit log.
+func ContextWithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey{}, actor)
+}
+
+// ActorFromContext returns the actor recorded by ContextWithActor, or
+// "system" for changes made outside a request.
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey{}).(string); ok && actor != "" {
+		return actor
+	}
+	return "system"
+}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID records the request a change is made in, so audit
+// entries can be matched with request logs.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the id recorded by ContextWithRequestID, or "".
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+type primaryKey struct{}
+
+// ContextWithPrimaryReads asks backends with read replicas to serve reads
+// from the primary, for callers that keep what they read, such as a cache,
+// and must not keep a replica's lagging copy.
+func ContextWithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// PrimaryReads reports whether ctx was made by ContextWithPrimaryReads.
+func PrimaryReads(ctx context.Context) bool {
+	primary, _ := ctx.Value(primaryKey{}).(bool)
+	return primary
+}
@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+
+	"github.com/surajNirala/student-api/internal/models"
+)
+
+// Types of the events published for student changes.
+const (
+	EventStudentCreated = "student.created"
+	EventStudentUpdated = "student.updated"
+	EventStudentDeleted = "student.deleted"
+)
+
+// EventTypes lists every event type a subscription can ask for.
+var EventTypes = []string{EventStudentCreated, EventStudentUpdated, EventStudentDeleted}
+
+// StudentEventData is the data of a student event; a deleted student is
+// described by the values it had last.
+func StudentEventData(student models.Student) json.RawMessage {
+	data, _ := json.Marshal(struct {
+		Id    uint64 `json:"id"`
+		Name  string `json:"name"`
+		Email string `json:"email"`
+		Age   int    `json:"age"`
+	}{student.Id, student.Name, student.Email, student.Age})
+	return data
+}
+
+// Subscribed reports whether a subscription to events, or to every event
+// when events is empty, covers eventType.
+func Subscribed(events []string, eventType string) bool {
+	return len(events) == 0 || slices.Contains(events, eventType)
+}
+
+// JoinEvents and SplitEvents store the event types of a subscription in
+// a single column.
+func JoinEvents(events []string) string {
+	return strings.Join(events, ",")
+}
+
+func SplitEvents(column string) []string {
+	if column == "" {
+		return []string{}
+	}
+	return strings.Split(column, ",")
+}
@@ -0,0 +1,206 @@
+package filestore
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Dir is where uploaded files are kept, relative to the working directory.
+const Dir = "uploads"
+
+var (
+	// blobDir holds file contents named after their SHA-256 digest, so
+	// identical uploads share one copy on disk.
+	blobDir = filepath.Join(Dir, "blobs")
+	// tmpDir receives uploads while they are hashed.
+	tmpDir = filepath.Join(Dir, ".tmp")
+	// partialDir holds resumable uploads that have not received all their bytes.
+	partialDir = filepath.Join(Dir, ".partial")
+)
+
+// Blob describes content written to disk but not yet committed under its digest.
+type Blob struct {
+	Path   string
+	Size   int64
+	SHA256 string
+}
+
+// Save copies r into a temporary file, hashing it on the way. Nothing is
+// left behind on disk when the copy fails.
+func Save(r io.Reader) (Blob, error) {
+	if err := os.MkdirAll(tmpDir, os.ModePerm); err != nil {
+		return Blob{}, err
+	}
+	dstFile, err := os.CreateTemp(tmpDir, "upload-*")
+	if err != nil {
+		return Blob{}, err
+	}
+	defer dstFile.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dstFile, hash), r)
+	if err != nil {
+		os.Remove(dstFile.Name())
+		return Blob{}, err
+	}
+	return Blob{Path: dstFile.Name(), Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// Hash computes the size and SHA-256 digest of the file at filePath.
+func Hash(filePath string) (Blob, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return Blob{}, err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, f)
+	if err != nil {
+		return Blob{}, err
+	}
+	return Blob{Path: filePath, Size: size, SHA256: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// BlobPath is where content with the given digest is stored.
+func BlobPath(sum string) string {
+	return filepath.Join(blobDir, sum[:2], sum)
+}
+
+// Commit moves blob to its content-addressed location and returns that
+// path. When the content is already stored the new copy is dropped.
+func Commit(blob Blob) (string, error) {
+	blobPath := BlobPath(blob.SHA256)
+	if err := os.MkdirAll(filepath.Dir(blobPath), os.ModePerm); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(blobPath); err == nil {
+		return blobPath, os.Remove(blob.Path)
+	}
+	if err := os.Rename(blob.Path, blobPath); err != nil {
+		return "", err
+	}
+	return blobPath, nil
+}
+
+// CreatePartial creates an empty file for a resumable upload and returns
+// the upload id together with the file's path.
+func CreatePartial() (string, string, error) {
+	if err := os.MkdirAll(partialDir, os.ModePerm); err != nil {
+		return "", "", err
+	}
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	id := hex.EncodeToString(buf)
+	filePath := filepath.Join(partialDir, id)
+	f, err := os.OpenFile(filePath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", "", err
+	}
+	return id, filePath, f.Close()
+}
+
+// WritePartial writes r into the partial file at offset. The number of
+// bytes written is returned even when the copy is interrupted, so callers
+// can record how far the upload got.
+func WritePartial(filePath string, offset int64, r io.Reader) (int64, error) {
+	f, err := os.OpenFile(filePath, os.O_WRONLY, 0o644)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	if _, err := f.Seek(offset, io.SeekStart); err != nil {
+		return 0, err
+	}
+	return io.Copy(f, r)
+}
+
+// Truncate discards everything in a partial file past size.
+func Truncate(filePath string, size int64) error {
+	return os.Truncate(filePath, size)
+}
+
+// Remove deletes a stored file, ignoring files that are already gone.
+func Remove(filePath string) error {
+	if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Entry is a file found on disk while scanning the upload directories.
+type Entry struct {
+	Path    string
+	Name    string
+	Size    int64
+	ModTime time.Time
+}
+
+// Blobs lists every file under the content-addressed blob directory; each
+// entry's Name is the digest it is stored under.
+func Blobs() ([]Entry, error) {
+	var entries []Entry
+	err := filepath.WalkDir(blobDir, func(path string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) {
+			return filepath.SkipDir
+		}
+		if err != nil || d.IsDir() {
+			return err
+		}
+		entry, err := newEntry(path, d)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	return entries, err
+}
+
+// Temps lists uploads left behind in the temporary directory.
+func Temps() ([]Entry, error) {
+	return list(tmpDir)
+}
+
+// Partials lists the files of resumable uploads; each entry's Name is the upload id.
+func Partials() ([]Entry, error) {
+	return list(partialDir)
+}
+
+func list(dir string) ([]Entry, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	for _, d := range dirEntries {
+		if d.IsDir() {
+			continue
+		}
+		entry, err := newEntry(filepath.Join(dir, d.Name()), d)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func newEntry(path string, d fs.DirEntry) (Entry, error) {
+	info, err := d.Info()
+	if err != nil {
+		return Entry{}, err
+	}
+	return Entry{Path: path, Name: d.Name(), Size: info.Size(), ModTime: info.ModTime()}, nil
+}
@@ -0,0 +1,55 @@
+package filestore
+
+import "sync"
+
+// locks hands out one mutex per key while any goroutine holds or waits for it.
+var locks = keyLocks{held: make(map[string]*keyLock)}
+
+type keyLocks struct {
+	mu   sync.Mutex
+	held map[string]*keyLock
+}
+
+type keyLock struct {
+	sync.Mutex
+	users int
+}
+
+func (l *keyLocks) lock(key string) (unlock func()) {
+	l.mu.Lock()
+	kl, ok := l.held[key]
+	if !ok {
+		kl = &keyLock{}
+		l.held[key] = kl
+	}
+	kl.users++
+	l.mu.Unlock()
+
+	kl.Lock()
+	return func() {
+		kl.Unlock()
+		l.mu.Lock()
+		kl.users--
+		if kl.users == 0 {
+			delete(l.held, key)
+		}
+		l.mu.Unlock()
+	}
+}
+
+// LockUpload serialises the writers of one resumable upload, so checking
+// its offset, writing the chunk and recording the new offset happen as
+// one step. Partial files live on this instance's disk, so a lock in the
+// process is enough.
+func LockUpload(id string) (unlock func()) {
+	return locks.lock("upload:" + id)
+}
+
+// LockBlob serialises changes to the stored copy of one digest. Adding a
+// reference holds it from recording the reference until the copy is on
+// disk and the reference committed; dropping the last reference holds it
+// until the copy is removed. Without it a new reference could be
+// recorded to a copy that is about to be removed.
+func LockBlob(sum string) (unlock func()) {
+	return locks.lock("blob:" + sum)
+}
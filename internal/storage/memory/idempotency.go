@@ -0,0 +1,65 @@
+package memory
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/models"
+)
+
+func (m *Memory) ReserveIdempotencyKey(key string, fingerprint string, expires time.Time, stale time.Time) (models.IdempotencyKey, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	created := now()
+	if existing, ok := m.idempotency[key]; ok {
+		unfinished := existing.StatusCode == 0 && !existing.CreatedAt.After(stale)
+		if existing.ExpiresAt.After(created) && !unfinished {
+			return copyIdempotencyKey(existing), false, nil
+		}
+	}
+	reserved := models.IdempotencyKey{Key: key, Fingerprint: fingerprint, CreatedAt: created, ExpiresAt: expires.UTC()}
+	m.idempotency[key] = reserved
+	return reserved, true, nil
+}
+
+func (m *Memory) CompleteIdempotencyKey(key models.IdempotencyKey) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	existing, ok := m.idempotency[key.Key]
+	if !ok {
+		return nil
+	}
+	existing.StatusCode = key.StatusCode
+	existing.Header = key.Header
+	existing.Body = key.Body
+	m.idempotency[key.Key] = copyIdempotencyKey(existing)
+	return nil
+}
+
+func (m *Memory) DeleteIdempotencyKey(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.idempotency, key)
+	return nil
+}
+
+func (m *Memory) DeleteExpiredIdempotencyKeys(before time.Time) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var deleted int64
+	for key, record := range m.idempotency {
+		if record.ExpiresAt.Before(before) {
+			delete(m.idempotency, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// copyIdempotencyKey keeps callers from changing a stored response
+// through the slices and maps they were handed.
+func copyIdempotencyKey(key models.IdempotencyKey) models.IdempotencyKey {
+	key.Header = key.Header.Clone()
+	key.Body = bytes.Clone(key.Body)
+	return key
+}
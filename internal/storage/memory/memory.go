@@ -0,0 +1,643 @@
+// Package memory keeps all metadata in process memory, for tests and
+// demo instances that should not need a database. File contents are still
+// written to the shared file store on disk. Nothing survives a restart.
+package memory
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/filestore"
+	"github.com/surajNirala/student-api/internal/utils/digest"
+)
+
+// Memory reports missing records with sql.ErrNoRows, like the SQL backends.
+type Memory struct {
+	*tables
+	ctx context.Context
+}
+
+// tables is shared by every Memory returned from WithContext.
+type tables struct {
+	mu             sync.RWMutex
+	students       map[int64]models.Student
+	files          map[int64]models.File
+	blobs          map[string]models.Blob
+	photos         map[int64]models.Photo
+	uploads        map[string]models.Upload
+	audit          []models.AuditEntry
+	revisions      map[int64][]models.StudentRevision
+	events         []models.Event
+	dispatched     int
+	subscriptions  map[int64]models.Subscription
+	deliveries     map[int64]models.Delivery
+	idempotency    map[string]models.IdempotencyKey
+	lastStudentID  int64
+	lastFileID     int64
+	lastSubID      int64
+	lastDeliveryID int64
+}
+
+func init() {
+	storage.Register("memory", func(cfg *config.Config) (storage.Storage, error) {
+		return New(), nil
+	})
+}
+
+func New() *Memory {
+	return &Memory{
+		tables: &tables{
+			students:      make(map[int64]models.Student),
+			files:         make(map[int64]models.File),
+			blobs:         make(map[string]models.Blob),
+			photos:        make(map[int64]models.Photo),
+			uploads:       make(map[string]models.Upload),
+			revisions:     make(map[int64][]models.StudentRevision),
+			subscriptions: make(map[int64]models.Subscription),
+			deliveries:    make(map[int64]models.Delivery),
+			idempotency:   make(map[string]models.IdempotencyKey),
+		},
+		ctx: context.Background(),
+	}
+}
+
+func now() time.Time {
+	return time.Now().UTC()
+}
+
+func (m *Memory) WithContext(ctx context.Context) storage.Storage {
+	return &Memory{tables: m.tables, ctx: ctx}
+}
+
+// record appends an audit entry; it must be called with m.mu held.
+func (m *Memory) record(action string, id int64, changes json.RawMessage) {
+	entry := storage.NewAuditEntry(m.ctx, action, storage.EntityStudent, id, changes)
+	entry.Id = uint64(len(m.audit) + 1)
+	entry.CreatedAt = now()
+	m.audit = append(m.audit, entry)
+}
+
+func (m *Memory) StudentList() ([]models.Student, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []models.Student
+	for _, student := range m.students {
+		list = append(list, m.withPhoto(student))
+	}
+	slices.SortFunc(list, func(a, b models.Student) int {
+		return cmp.Compare(b.Id, a.Id)
+	})
+	return list, nil
+}
+
+func (m *Memory) CreateStudent(name string, email string, age int) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastStudentID++
+	created := now()
+	m.students[m.lastStudentID] = models.Student{
+		Id:        uint64(m.lastStudentID),
+		Name:      name,
+		Email:     email,
+		Age:       age,
+		CreatedAt: created,
+		UpdatedAt: created,
+	}
+	student := m.students[m.lastStudentID]
+	m.record(storage.AuditCreate, m.lastStudentID, storage.StudentChanges(nil, &student))
+	m.revise(student, false)
+	m.publish(storage.EventStudentCreated, student)
+	return m.lastStudentID, nil
+}
+
+func (m *Memory) GetStudentByID(id int64) (models.Student, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	student, ok := m.students[id]
+	if !ok {
+		return models.Student{}, sql.ErrNoRows
+	}
+	return m.withPhoto(student), nil
+}
+
+// withPhoto must be called with m.mu held.
+func (m *Memory) withPhoto(student models.Student) models.Student {
+	if _, ok := m.photos[int64(student.Id)]; ok {
+		student.PhotoURL = models.StudentPhotoURL(student.Id)
+	}
+	return student
+}
+
+func (m *Memory) UpdateStudentByID(name string, email string, age int, id int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	student, ok := m.students[id]
+	if !ok {
+		return "", fmt.Errorf("no student found with id %d: %w", id, sql.ErrNoRows)
+	}
+	before := student
+	student.Name = name
+	student.Email = email
+	student.Age = age
+	student.UpdatedAt = now()
+	m.students[id] = student
+	m.record(storage.AuditUpdate, id, storage.StudentChanges(&before, &student))
+	m.revise(student, false)
+	m.publish(storage.EventStudentUpdated, student)
+	return fmt.Sprintf("student with id %d updated successfully", id), nil
+}
+
+func (m *Memory) DeleteStudentByID(id int64) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	student, ok := m.students[id]
+	if !ok {
+		return "", fmt.Errorf("no student found with id %d: %w", id, sql.ErrNoRows)
+	}
+	delete(m.students, id)
+	// A reverted student must not come back with a photo whose files are
+	// gone; they are orphaned now and left to garbage collection.
+	delete(m.photos, id)
+	m.record(storage.AuditDelete, id, storage.StudentChanges(&student, nil))
+	m.revise(student, true)
+	m.publish(storage.EventStudentDeleted, student)
+	return fmt.Sprintf("student with id %d deleted successfully", id), nil
+}
+
+func (m *Memory) StudentHistory(studentID int64) ([]models.AuditEntry, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []models.AuditEntry
+	for _, entry := range m.audit {
+		if entry.EntityType == storage.EntityStudent && entry.EntityId == studentID {
+			list = append(list, entry)
+		}
+	}
+	return list, nil
+}
+
+// revise records student as its next revision; it must be called with
+// m.mu held.
+func (m *Memory) revise(student models.Student, deleted bool) models.StudentRevision {
+	id := int64(student.Id)
+	rev := models.StudentRevision{
+		StudentId: id,
+		Revision:  len(m.revisions[id]) + 1,
+		Name:      student.Name,
+		Email:     student.Email,
+		Age:       student.Age,
+		Deleted:   deleted,
+		Actor:     storage.ActorFromContext(m.ctx),
+		CreatedAt: now(),
+	}
+	m.revisions[id] = append(m.revisions[id], rev)
+	return rev
+}
+
+func (m *Memory) StudentRevisions(studentID int64) ([]models.StudentRevision, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return slices.Clone(m.revisions[studentID]), nil
+}
+
+func (m *Memory) StudentAsOf(studentID int64, at time.Time) (models.Student, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	revisions := m.revisions[studentID]
+	for i := len(revisions) - 1; i >= 0; i-- {
+		if revisions[i].CreatedAt.After(at) {
+			continue
+		}
+		if revisions[i].Deleted {
+			break
+		}
+		student := revisions[i].Student()
+		student.CreatedAt = revisions[0].CreatedAt
+		return student, nil
+	}
+	return models.Student{}, sql.ErrNoRows
+}
+
+func (m *Memory) RevertStudent(studentID int64, revision int) (models.StudentRevision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	revisions := m.revisions[studentID]
+	if revision < 1 || revision > len(revisions) {
+		return models.StudentRevision{}, sql.ErrNoRows
+	}
+	target := revisions[revision-1]
+	if target.Deleted {
+		return models.StudentRevision{}, storage.ErrDeletedRevision
+	}
+	before, exists := m.students[studentID]
+	student := before
+	if !exists {
+		// Deleted since the revision, so it comes back under its old id
+		student = models.Student{Id: uint64(studentID), CreatedAt: now()}
+	}
+	student.Name, student.Email, student.Age = target.Name, target.Email, target.Age
+	student.UpdatedAt = now()
+	m.students[studentID] = student
+	if exists {
+		m.record(storage.AuditRevert, studentID, storage.StudentChanges(&before, &student))
+		m.publish(storage.EventStudentUpdated, student)
+	} else {
+		m.record(storage.AuditRevert, studentID, storage.StudentChanges(nil, &student))
+		m.publish(storage.EventStudentCreated, student)
+	}
+	return m.revise(student, false), nil
+}
+
+func (m *Memory) StudentFileUpload10MB(studentID int64, fileName string, contentType string, fileData []byte) (models.File, error) {
+	return m.StudentLargeFileUpload(studentID, fileName, contentType, bytes.NewReader(fileData))
+}
+
+func (m *Memory) StudentLargeFileUpload(studentID int64, fileName string, contentType string, fileReader io.Reader) (models.File, error) {
+	blob, err := filestore.Save(fileReader)
+	if err != nil {
+		return models.File{}, err
+	}
+	file, err := m.addFile(studentID, fileName, contentType, blob)
+	if err != nil {
+		filestore.Remove(blob.Path)
+	}
+	return file, err
+}
+
+// addFile commits blob under its digest, sharing the stored copy when the
+// same content was uploaded before, and records a file pointing at it.
+func (m *Memory) addFile(studentID int64, fileName string, contentType string, blob filestore.Blob) (models.File, error) {
+	unlock := filestore.LockBlob(blob.SHA256)
+	defer unlock()
+	blobPath, err := filestore.Commit(blob)
+	if err != nil {
+		return models.File{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	created := now()
+	stored, ok := m.blobs[blob.SHA256]
+	if !ok {
+		stored = models.Blob{SHA256: blob.SHA256, Size: blob.Size, Path: blobPath, CreatedAt: created}
+	}
+	stored.RefCount++
+	m.blobs[blob.SHA256] = stored
+
+	m.lastFileID++
+	file := models.File{
+		Id:          uint64(m.lastFileID),
+		StudentId:   studentID,
+		Name:        fileName,
+		ContentType: contentType,
+		Size:        blob.Size,
+		SHA256:      blob.SHA256,
+		Path:        blobPath,
+		CreatedAt:   created,
+		UpdatedAt:   created,
+	}
+	m.files[m.lastFileID] = file
+	return file, nil
+}
+
+func (m *Memory) OpenFile(id int64) (models.File, io.ReadSeekCloser, error) {
+	file, err := m.GetFileByID(id)
+	if err != nil {
+		return file, nil, err
+	}
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return file, nil, err
+	}
+	return file, f, nil
+}
+
+func (m *Memory) DeleteFileByID(id int64) (string, error) {
+	file, err := m.GetFileByID(id)
+	if err != nil {
+		return "", err
+	}
+	unlock := filestore.LockBlob(file.SHA256)
+	defer unlock()
+	m.mu.Lock()
+	if _, ok := m.files[id]; !ok {
+		m.mu.Unlock()
+		return "", sql.ErrNoRows
+	}
+	delete(m.files, id)
+	blob := m.blobs[file.SHA256]
+	blob.RefCount--
+	unreferenced := blob.RefCount <= 0
+	if unreferenced {
+		delete(m.blobs, file.SHA256)
+	} else {
+		m.blobs[file.SHA256] = blob
+	}
+	m.mu.Unlock()
+
+	// Other files may still share the content; only the last one removes it
+	if unreferenced {
+		if err := filestore.Remove(file.Path); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("file with id %d deleted successfully", id), nil
+}
+
+func (m *Memory) GetFileByID(id int64) (models.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	file, ok := m.files[id]
+	if !ok {
+		return models.File{}, sql.ErrNoRows
+	}
+	return file, nil
+}
+
+func (m *Memory) StudentFileUsage(studentID int64) (models.FileUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	usage := models.FileUsage{StudentId: studentID}
+	for _, file := range m.files {
+		if file.StudentId == studentID {
+			usage.Files++
+			usage.Bytes += file.Size
+		}
+	}
+	return usage, nil
+}
+
+// TotalFileUsage counts every file but measures the bytes actually on
+// disk, where content shared by several files is stored once.
+func (m *Memory) TotalFileUsage() (models.FileUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	usage := models.FileUsage{Files: int64(len(m.files))}
+	for _, blob := range m.blobs {
+		usage.Bytes += blob.Size
+	}
+	return usage, nil
+}
+
+func (m *Memory) StudentUploadUsage(studentID int64) (models.FileUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	usage := models.FileUsage{StudentId: studentID}
+	for _, upload := range m.uploads {
+		if upload.StudentId == studentID && !upload.Complete() {
+			usage.Files++
+			usage.Bytes += upload.Offset
+		}
+	}
+	return usage, nil
+}
+
+func (m *Memory) TotalUploadUsage() (models.FileUsage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var usage models.FileUsage
+	for _, upload := range m.uploads {
+		if !upload.Complete() {
+			usage.Files++
+			usage.Bytes += upload.Offset
+		}
+	}
+	return usage, nil
+}
+
+// SetStudentPhoto points a student at a new set of photo files and
+// releases the files of the photo it replaces.
+func (m *Memory) SetStudentPhoto(studentID int64, photo models.Photo) error {
+	m.mu.Lock()
+	previous, replaced := m.photos[studentID]
+	photo.StudentId = studentID
+	photo.UpdatedAt = now()
+	m.photos[studentID] = photo
+	m.record(storage.AuditPhoto, studentID, storage.PhotoChanges(previous.OriginalFileId, photo.OriginalFileId))
+	m.mu.Unlock()
+
+	if replaced {
+		for _, id := range []uint64{previous.OriginalFileId, previous.MediumFileId, previous.ThumbFileId} {
+			if _, err := m.DeleteFileByID(int64(id)); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (m *Memory) GetStudentPhoto(studentID int64) (models.Photo, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	photo, ok := m.photos[studentID]
+	if !ok {
+		return models.Photo{}, sql.ErrNoRows
+	}
+	return photo, nil
+}
+
+func (m *Memory) CreateUpload(studentID int64, fileName string, contentType string, length int64) (models.Upload, error) {
+	id, filePath, err := filestore.CreatePartial()
+	if err != nil {
+		return models.Upload{}, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	created := now()
+	upload := models.Upload{
+		Id:          id,
+		StudentId:   studentID,
+		Name:        fileName,
+		ContentType: contentType,
+		Length:      length,
+		Path:        filePath,
+		CreatedAt:   created,
+		UpdatedAt:   created,
+	}
+	m.uploads[id] = upload
+	return upload, nil
+}
+
+func (m *Memory) GetUpload(id string) (models.Upload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	upload, ok := m.uploads[id]
+	if !ok {
+		return models.Upload{}, sql.ErrNoRows
+	}
+	return upload, nil
+}
+
+func (m *Memory) WriteUploadChunk(id string, offset int64, data io.Reader) (models.Upload, error) {
+	// A retried PATCH may race the one it retries; only one of them may write at offset
+	unlock := filestore.LockUpload(id)
+	defer unlock()
+	upload, err := m.GetUpload(id)
+	if err != nil {
+		return upload, err
+	}
+	if upload.Offset != offset || upload.Complete() {
+		return upload, storage.ErrOffsetMismatch
+	}
+	// Read one byte past the remaining length to notice oversized chunks
+	written, copyErr := filestore.WritePartial(upload.Path, offset, io.LimitReader(data, upload.Length-offset+1))
+	if copyErr == nil && offset+written > upload.Length {
+		copyErr = storage.ErrChunkTooLarge
+	}
+	if errors.Is(copyErr, storage.ErrChunkTooLarge) || errors.Is(copyErr, digest.ErrMismatch) {
+		// Rejected chunks are discarded as a whole
+		if err := filestore.Truncate(upload.Path, offset); err != nil {
+			return upload, err
+		}
+		return upload, copyErr
+	}
+
+	// Record whatever arrived, even on a dropped connection, so the client can resume from there
+	m.mu.Lock()
+	current, ok := m.uploads[id]
+	if !ok || current.Offset != offset {
+		m.mu.Unlock()
+		return upload, storage.ErrOffsetMismatch
+	}
+	current.Offset = offset + written
+	current.UpdatedAt = now()
+	m.uploads[id] = current
+	m.mu.Unlock()
+
+	if copyErr != nil || !current.Complete() {
+		return current, copyErr
+	}
+	return m.completeUpload(current)
+}
+
+// completeUpload turns a fully received upload into a regular file.
+func (m *Memory) completeUpload(upload models.Upload) (models.Upload, error) {
+	// Trust the received bytes over the type declared when the upload was created
+	if mime, err := mimetype.DetectFile(upload.Path); err == nil {
+		upload.ContentType = mime.String()
+	}
+	blob, err := filestore.Hash(upload.Path)
+	if err != nil {
+		return upload, err
+	}
+	file, err := m.addFile(upload.StudentId, upload.Name, upload.ContentType, blob)
+	if err != nil {
+		return upload, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	upload.FileId = file.Id
+	upload.Path = file.Path
+	upload.UpdatedAt = now()
+	if _, ok := m.uploads[upload.Id]; ok {
+		m.uploads[upload.Id] = upload
+	}
+	return upload, nil
+}
+
+func (m *Memory) DeleteUpload(id string) error {
+	m.mu.Lock()
+	upload, ok := m.uploads[id]
+	if !ok {
+		m.mu.Unlock()
+		return sql.ErrNoRows
+	}
+	delete(m.uploads, id)
+	m.mu.Unlock()
+
+	// A completed upload's bytes now belong to its file
+	if upload.Complete() {
+		return nil
+	}
+	return filestore.Remove(upload.Path)
+}
+
+func (m *Memory) ExpiredUploads(before time.Time) ([]models.Upload, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []models.Upload
+	for _, upload := range m.uploads {
+		if upload.UpdatedAt.Before(before) {
+			list = append(list, upload)
+		}
+	}
+	return list, nil
+}
+
+// OrphanedFiles lists files whose owning student has been deleted.
+func (m *Memory) OrphanedFiles() ([]models.File, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []models.File
+	for _, file := range m.files {
+		if _, ok := m.students[file.StudentId]; file.StudentId != 0 && !ok {
+			list = append(list, file)
+		}
+	}
+	return list, nil
+}
+
+// UnreferencedBlobs lists stored content that no file points at, whatever
+// its reference count claims.
+func (m *Memory) UnreferencedBlobs() ([]models.Blob, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []models.Blob
+	for _, blob := range m.blobs {
+		if !m.referenced(blob.SHA256) {
+			list = append(list, blob)
+		}
+	}
+	return list, nil
+}
+
+// referenced must be called with m.mu held.
+func (m *Memory) referenced(sha256 string) bool {
+	for _, file := range m.files {
+		if file.SHA256 == sha256 {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Memory) HasBlob(sha256 string) (bool, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	_, ok := m.blobs[sha256]
+	return ok, nil
+}
+
+// DeleteBlob removes stored content, provided no file has started
+// pointing at it again. It reports whether anything was removed.
+func (m *Memory) DeleteBlob(sha256 string) (bool, error) {
+	unlock := filestore.LockBlob(sha256)
+	defer unlock()
+	m.mu.Lock()
+	blob, ok := m.blobs[sha256]
+	if !ok {
+		m.mu.Unlock()
+		return false, sql.ErrNoRows
+	}
+	if m.referenced(sha256) {
+		m.mu.Unlock()
+		return false, nil
+	}
+	delete(m.blobs, sha256)
+	m.mu.Unlock()
+	return true, filestore.Remove(blob.Path)
+}
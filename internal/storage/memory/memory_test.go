@@ -0,0 +1,35 @@
+package memory
+
+import (
+	"context"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		return New()
+	})
+}
+
+func TestInstances(t *testing.T) {
+	a, b := New(), New()
+	id, err := a.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.GetStudentByID(id); err == nil {
+		t.Error("a student created in one instance is visible in another")
+	}
+	// A view bound to a request context shares the instance's data
+	view := a.WithContext(context.Background())
+	if _, err := view.GetStudentByID(id); err != nil {
+		t.Errorf("GetStudentByID through WithContext: %v", err)
+	}
+	if id, err := view.CreateStudent("Ravi", "ravi@example.com", 21); err != nil || id != 2 {
+		t.Errorf("CreateStudent through WithContext = %d, %v; want id 2", id, err)
+	}
+
+}
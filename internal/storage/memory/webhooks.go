@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"cmp"
+	"database/sql"
+	"slices"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// publish adds an event about student to the outbox; it must be called
+// with m.mu held.
+func (m *Memory) publish(eventType string, student models.Student) {
+	m.events = append(m.events, models.Event{
+		Id:        uint64(len(m.events) + 1),
+		Type:      eventType,
+		StudentId: int64(student.Id),
+		Data:      storage.StudentEventData(student),
+		CreatedAt: now(),
+	})
+}
+
+func (m *Memory) DispatchEvents(limit int) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	pending := m.events[m.dispatched:]
+	pending = pending[:min(limit, len(pending))]
+	subs := m.sortedSubscriptions()
+	created := now()
+	for _, event := range pending {
+		for _, sub := range subs {
+			if !storage.Subscribed(sub.Events, event.Type) {
+				continue
+			}
+			m.lastDeliveryID++
+			m.deliveries[m.lastDeliveryID] = models.Delivery{
+				Id:             uint64(m.lastDeliveryID),
+				EventId:        event.Id,
+				SubscriptionId: sub.Id,
+				Status:         models.DeliveryPending,
+				NextAttemptAt:  created,
+				CreatedAt:      created,
+				UpdatedAt:      created,
+				Event:          event,
+				URL:            sub.URL,
+				Secret:         sub.Secret,
+			}
+		}
+	}
+	m.dispatched += len(pending)
+	return len(pending), nil
+}
+
+func (m *Memory) EventsAfter(id uint64, limit int) ([]models.Event, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	// Event ids are positions in m.events, counting from one
+	after := m.events[min(id, uint64(len(m.events))):]
+	return slices.Clone(after[:min(limit, len(after))]), nil
+}
+
+func (m *Memory) LastEventID() (uint64, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return uint64(len(m.events)), nil
+}
+
+func (m *Memory) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]models.Delivery, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var list []models.Delivery
+	for _, delivery := range m.deliveries {
+		if delivery.Status == models.DeliveryPending && !delivery.NextAttemptAt.After(now) {
+			list = append(list, delivery)
+		}
+	}
+	slices.SortFunc(list, func(a, b models.Delivery) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	list = list[:min(limit, len(list))]
+	for _, delivery := range list {
+		delivery.NextAttemptAt = now.Add(lease)
+		m.deliveries[int64(delivery.Id)] = delivery
+	}
+	return list, nil
+}
+
+func (m *Memory) UpdateDelivery(delivery models.Delivery) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	stored, ok := m.deliveries[int64(delivery.Id)]
+	if !ok {
+		return nil
+	}
+	stored.Status = delivery.Status
+	stored.Attempts = delivery.Attempts
+	stored.NextAttemptAt = delivery.NextAttemptAt
+	stored.LastError = delivery.LastError
+	stored.UpdatedAt = now()
+	m.deliveries[int64(delivery.Id)] = stored
+	return nil
+}
+
+func (m *Memory) Deliveries(status string, limit int) ([]models.Delivery, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var list []models.Delivery
+	for _, delivery := range m.deliveries {
+		if status == "" || delivery.Status == status {
+			list = append(list, delivery)
+		}
+	}
+	slices.SortFunc(list, func(a, b models.Delivery) int {
+		return cmp.Compare(b.Id, a.Id)
+	})
+	return list[:min(limit, len(list))], nil
+}
+
+func (m *Memory) ReplayDelivery(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delivery, ok := m.deliveries[id]
+	if !ok {
+		return sql.ErrNoRows
+	}
+	delivery.Status = models.DeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = now()
+	delivery.LastError = ""
+	delivery.UpdatedAt = now()
+	m.deliveries[id] = delivery
+	return nil
+}
+
+func (m *Memory) CreateSubscription(url string, secret string, events []string) (models.Subscription, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastSubID++
+	sub := models.Subscription{
+		Id:        uint64(m.lastSubID),
+		URL:       url,
+		Secret:    secret,
+		Events:    slices.Clone(events),
+		CreatedAt: now(),
+	}
+	if sub.Events == nil {
+		sub.Events = []string{}
+	}
+	m.subscriptions[m.lastSubID] = sub
+	return sub, nil
+}
+
+func (m *Memory) Subscriptions() ([]models.Subscription, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sortedSubscriptions(), nil
+}
+
+// sortedSubscriptions must be called with m.mu held.
+func (m *Memory) sortedSubscriptions() []models.Subscription {
+	var list []models.Subscription
+	for _, sub := range m.subscriptions {
+		list = append(list, sub)
+	}
+	slices.SortFunc(list, func(a, b models.Subscription) int {
+		return cmp.Compare(a.Id, b.Id)
+	})
+	return list
+}
+
+func (m *Memory) DeleteSubscription(id int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.subscriptions[id]; !ok {
+		return sql.ErrNoRows
+	}
+	delete(m.subscriptions, id)
+	for deliveryID, delivery := range m.deliveries {
+		if int64(delivery.SubscriptionId) == id {
+			delete(m.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package mysql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/models"
+)
+
+func (m *MySQL) ReserveIdempotencyKey(key string, fingerprint string, expires time.Time, stale time.Time) (models.IdempotencyKey, bool, error) {
+	now := time.Now().UTC()
+	if _, err := m.conn().Exec("DELETE FROM idempotency_keys WHERE idempotency_key = ? AND (expires_at <= ? OR (status_code = 0 AND created_at <= ?))", key, now, stale.UTC()); err != nil {
+		return models.IdempotencyKey{}, false, err
+	}
+	res, err := m.conn().Exec("INSERT IGNORE INTO idempotency_keys (idempotency_key,fingerprint,created_at,expires_at) VALUES (?,?,?,?)", key, fingerprint, now, expires.UTC())
+	if err != nil {
+		return models.IdempotencyKey{}, false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return models.IdempotencyKey{}, false, err
+	}
+	if inserted == 1 {
+		return models.IdempotencyKey{Key: key, Fingerprint: fingerprint, CreatedAt: now, ExpiresAt: expires.UTC()}, true, nil
+	}
+	existing, err := scanIdempotencyKey(m.conn().QueryRow("SELECT idempotency_key,fingerprint,status_code,header,body,created_at,expires_at FROM idempotency_keys WHERE idempotency_key = ?", key))
+	return existing, false, err
+}
+
+func scanIdempotencyKey(row *sql.Row) (models.IdempotencyKey, error) {
+	var key models.IdempotencyKey
+	var header []byte
+	if err := row.Scan(&key.Key, &key.Fingerprint, &key.StatusCode, &header, &key.Body, &key.CreatedAt, &key.ExpiresAt); err != nil {
+		return models.IdempotencyKey{}, err
+	}
+	if len(header) > 0 {
+		if err := json.Unmarshal(header, &key.Header); err != nil {
+			return models.IdempotencyKey{}, err
+		}
+	}
+	return key, nil
+}
+
+func (m *MySQL) CompleteIdempotencyKey(key models.IdempotencyKey) error {
+	header, err := json.Marshal(key.Header)
+	if err != nil {
+		return err
+	}
+	_, err = m.conn().Exec("UPDATE idempotency_keys SET status_code = ?, header = ?, body = ? WHERE idempotency_key = ?", key.StatusCode, string(header), key.Body, key.Key)
+	return err
+}
+
+func (m *MySQL) DeleteIdempotencyKey(key string) error {
+	_, err := m.conn().Exec("DELETE FROM idempotency_keys WHERE idempotency_key = ?", key)
+	return err
+}
+
+func (m *MySQL) DeleteExpiredIdempotencyKeys(before time.Time) (int64, error) {
+	res, err := m.conn().Exec("DELETE FROM idempotency_keys WHERE expires_at < ?", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
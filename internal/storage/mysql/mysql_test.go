@@ -0,0 +1,52 @@
+package mysql
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/storagetest"
+)
+
+// The suite empties every table it uses, so point MYSQL_TEST_HOST at a
+// throwaway database. MYSQL_TEST_PORT, MYSQL_TEST_USER, MYSQL_TEST_PASSWORD
+// and MYSQL_TEST_DBNAME override the defaults below.
+func TestConformance(t *testing.T) {
+	host := os.Getenv("MYSQL_TEST_HOST")
+	if host == "" {
+		t.Skip("MYSQL_TEST_HOST not set")
+	}
+	port, err := strconv.Atoi(envOr("MYSQL_TEST_PORT", "3306"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{MySQL: &config.MySQLConfig{
+		Host:     host,
+		Port:     port,
+		User:     envOr("MYSQL_TEST_USER", "root"),
+		Password: os.Getenv("MYSQL_TEST_PASSWORD"),
+		DBName:   envOr("MYSQL_TEST_DBNAME", "student_api_test"),
+	}}
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		m, err := MysqlConnect(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { m.Db.Close() })
+		for _, table := range []string{"students", "files", "blobs", "tus_uploads", "student_photos", "audit_log", "student_revisions", "outbox", "webhook_subscriptions", "webhook_deliveries", "idempotency_keys"} {
+			if _, err := m.Db.Exec("TRUNCATE TABLE " + table); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return m
+	})
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
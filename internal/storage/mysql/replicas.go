@@ -0,0 +1,148 @@
+package mysql
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+type replica struct {
+	db        *sql.DB
+	addr      string
+	downUntil time.Time
+}
+
+// replicaSet spreads reads over the replicas in turn and remembers which
+// clients wrote recently. It is shared by every copy of a MySQL handed
+// out by WithContext.
+type replicaSet struct {
+	mu        sync.Mutex
+	replicas  []*replica
+	next      int
+	sticky    time.Duration
+	cooldown  time.Duration
+	lastWrite map[string]time.Time
+	// now is the clock of the sticky windows and cooldowns.
+	now func() time.Time
+}
+
+func openReplicas(cfg *config.Config) (*replicaSet, error) {
+	set := &replicaSet{
+		sticky:    cfg.MySQL.StickyWindow,
+		cooldown:  cfg.MySQL.ReplicaCooldown,
+		lastWrite: make(map[string]time.Time),
+		now:       time.Now,
+	}
+	for _, dsn := range cfg.MySQL.Replicas {
+		replicaCfg, err := mysqldriver.ParseDSN(dsn)
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		replicaCfg.ParseTime = true
+		replicaCfg.Timeout = cfg.MySQL.DialTimeout
+		replicaCfg.ReadTimeout = cfg.MySQL.ReadTimeout
+		replicaCfg.WriteTimeout = cfg.MySQL.WriteTimeout
+		db, err := sql.Open("mysql", replicaCfg.FormatDSN())
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		storage.ConfigurePool(db, cfg.Storage.Pool)
+		// A replica that is down now is retried on the first read after its cooldown
+		r := &replica{db: db, addr: replicaCfg.Addr}
+		if err := db.Ping(); err != nil {
+			slog.Warn("MySQL replica unavailable", slog.String("replica", r.addr), slog.String("error", err.Error()))
+			r.downUntil = set.now().Add(set.cooldown)
+		}
+		set.replicas = append(set.replicas, r)
+	}
+	return set, nil
+}
+
+func (set *replicaSet) close() {
+	for _, r := range set.replicas {
+		r.db.Close()
+	}
+}
+
+// wrote starts the sticky window of client.
+func (set *replicaSet) wrote(client string) {
+	if set == nil || client == "" || len(set.replicas) == 0 {
+		return
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	now := set.now()
+	set.lastWrite[client] = now
+	// Forget clients whose window closed, so the map stays small
+	if len(set.lastWrite) > 1024 {
+		for c, at := range set.lastWrite {
+			if now.Sub(at) > set.sticky {
+				delete(set.lastWrite, c)
+			}
+		}
+	}
+}
+
+// candidates lists the replicas client may read from, in the order to try
+// them. It is empty while client is inside its sticky window.
+func (set *replicaSet) candidates(client string) []*replica {
+	if set == nil || len(set.replicas) == 0 {
+		return nil
+	}
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	now := set.now()
+	if at, ok := set.lastWrite[client]; ok && client != "" && now.Sub(at) <= set.sticky {
+		return nil
+	}
+	var list []*replica
+	for i := range set.replicas {
+		r := set.replicas[(set.next+i)%len(set.replicas)]
+		if now.After(r.downUntil) {
+			list = append(list, r)
+		}
+	}
+	set.next = (set.next + 1) % len(set.replicas)
+	return list
+}
+
+func (set *replicaSet) markDown(r *replica, err error) {
+	set.mu.Lock()
+	defer set.mu.Unlock()
+	r.downUntil = set.now().Add(set.cooldown)
+	slog.Warn("MySQL replica unavailable, reading from the primary", slog.String("replica", r.addr), slog.String("error", err.Error()))
+}
+
+// read runs query on a replica when client may use one, moving on to the
+// next replica and finally to the primary when a replica cannot be reached.
+// Inside a transaction it reads from the transaction, and it reads from the
+// primary when the context asks for primary reads.
+func (m *MySQL) read(query func(db storage.Querier) error) error {
+	if storage.InTransaction(m.ctx) {
+		return query(m.conn())
+	}
+	if storage.PrimaryReads(m.ctx) {
+		return query(m.Db)
+	}
+	for _, r := range m.replicas.candidates(storage.ClientFromContext(m.ctx)) {
+		err := query(r.db)
+		if err == nil || !(errors.Is(err, mysqldriver.ErrInvalidConn) || storage.IsConnectionError(err)) {
+			return err
+		}
+		m.replicas.markDown(r, err)
+	}
+	return query(m.Db)
+}
+
+// wrote keeps the client of m reading from the primary for a while.
+func (m *MySQL) wrote() {
+	m.replicas.wrote(storage.ClientFromContext(m.ctx))
+}
@@ -0,0 +1,188 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// newReplicaSet returns a set of n replicas on a clock the test moves with
+// advance. The replicas are never dialled; the queries in these tests only
+// look at which of them they were given.
+func newReplicaSet(t *testing.T, n int) (set *replicaSet, advance func(time.Duration)) {
+	t.Helper()
+	now := time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+	set = &replicaSet{
+		sticky:    5 * time.Second,
+		cooldown:  30 * time.Second,
+		lastWrite: make(map[string]time.Time),
+		now:       func() time.Time { return now },
+	}
+	for range n {
+		set.replicas = append(set.replicas, &replica{db: openStub(t)})
+	}
+	return set, func(d time.Duration) { now = now.Add(d) }
+}
+
+func openStub(t *testing.T) *sql.DB {
+	t.Helper()
+	db, err := sql.Open("mysql", "user@tcp(127.0.0.1:1)/students")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func dbs(list []*replica) []*sql.DB {
+	var out []*sql.DB
+	for _, r := range list {
+		out = append(out, r.db)
+	}
+	return out
+}
+
+func TestCandidates(t *testing.T) {
+	set, advance := newReplicaSet(t, 2)
+	r0, r1 := set.replicas[0].db, set.replicas[1].db
+
+	// Reads take turns on the replicas
+	if got := dbs(set.candidates("a")); !slices.Equal(got, []*sql.DB{r0, r1}) {
+		t.Errorf("first read tries %v", got)
+	}
+	if got := dbs(set.candidates("a")); !slices.Equal(got, []*sql.DB{r1, r0}) {
+		t.Errorf("second read tries %v", got)
+	}
+
+	set.wrote("a")
+	if got := set.candidates("a"); len(got) != 0 {
+		t.Errorf("client that just wrote may read from %d replicas", len(got))
+	}
+	if got := set.candidates("b"); len(got) != 2 {
+		t.Errorf("another client may read from %d replicas, want 2", len(got))
+	}
+	advance(5 * time.Second)
+	if got := set.candidates("a"); len(got) != 0 {
+		t.Errorf("client at the end of its sticky window may read from %d replicas", len(got))
+	}
+	advance(time.Millisecond)
+	if got := set.candidates("a"); len(got) != 2 {
+		t.Errorf("client past its sticky window may read from %d replicas, want 2", len(got))
+	}
+
+	// Anonymous clients cannot be followed, so they are never sticky
+	set.wrote("")
+	if got := set.candidates(""); len(got) != 2 {
+		t.Errorf("anonymous client may read from %d replicas, want 2", len(got))
+	}
+
+	set.markDown(set.replicas[0], driver.ErrBadConn)
+	for range 2 {
+		if got := dbs(set.candidates("b")); !slices.Equal(got, []*sql.DB{r1}) {
+			t.Errorf("with replica 0 down a read tries %v", got)
+		}
+	}
+	advance(30*time.Second + time.Millisecond)
+	if got := set.candidates("b"); len(got) != 2 {
+		t.Errorf("after the cooldown a read tries %d replicas, want 2", len(got))
+	}
+}
+
+func TestWroteForgetsOldClients(t *testing.T) {
+	set, advance := newReplicaSet(t, 1)
+	for i := range 1025 {
+		set.wrote(string(rune('a' + i)))
+	}
+	advance(time.Minute)
+	set.wrote("latest")
+	if len(set.lastWrite) != 1 {
+		t.Errorf("%d clients remembered, want only the latest", len(set.lastWrite))
+	}
+}
+
+func TestRead(t *testing.T) {
+	set, advance := newReplicaSet(t, 2)
+	r0, r1 := set.replicas[0].db, set.replicas[1].db
+	primary := openStub(t)
+	m := &MySQL{Db: primary, replicas: set, ctx: storage.ContextWithClient(context.Background(), "a")}
+
+	// read returns the databases a query went to; down ones fail to connect
+	down := map[*sql.DB]bool{}
+	read := func(m *MySQL) ([]*sql.DB, error) {
+		var tried []*sql.DB
+		err := m.read(func(db storage.Querier) error {
+			tried = append(tried, db.(*sql.DB))
+			if down[db.(*sql.DB)] {
+				return driver.ErrBadConn
+			}
+			return nil
+		})
+		return tried, err
+	}
+
+	if tried, err := read(m); err != nil || !slices.Equal(tried, []*sql.DB{r0}) {
+		t.Errorf("read = %v, %v; want replica 0", tried, err)
+	}
+
+	down[r0] = true
+	if tried, err := read(m); err != nil || !slices.Equal(tried, []*sql.DB{r1}) {
+		t.Errorf("read = %v, %v; want replica 1", tried, err)
+	}
+	if tried, err := read(m); err != nil || !slices.Equal(tried, []*sql.DB{r0, r1}) {
+		t.Errorf("read = %v, %v; want replica 0, then 1", tried, err)
+	}
+	// Replica 0 now sits out its cooldown
+	if tried, err := read(m); err != nil || !slices.Equal(tried, []*sql.DB{r1}) {
+		t.Errorf("read = %v, %v; want replica 1 only", tried, err)
+	}
+
+	down[r1] = true
+	if tried, err := read(m); err != nil || !slices.Equal(tried, []*sql.DB{r1, primary}) {
+		t.Errorf("read = %v, %v; want replica 1, then the primary", tried, err)
+	}
+	if tried, err := read(m); err != nil || !slices.Equal(tried, []*sql.DB{primary}) {
+		t.Errorf("read with every replica down = %v, %v; want the primary", tried, err)
+	}
+
+	delete(down, r0)
+	delete(down, r1)
+	advance(30*time.Second + time.Millisecond)
+	if tried, err := read(m); err != nil || len(tried) != 1 || tried[0] == primary {
+		t.Errorf("read after the cooldown = %v, %v; want a replica", tried, err)
+	}
+
+	m.wrote()
+	if tried, err := read(m); err != nil || !slices.Equal(tried, []*sql.DB{primary}) {
+		t.Errorf("read after a write = %v, %v; want the primary", tried, err)
+	}
+
+	// A cache filling itself asks for the primary
+	advance(time.Minute)
+	filling := &MySQL{Db: primary, replicas: set, ctx: storage.ContextWithPrimaryReads(m.ctx)}
+	if tried, err := read(filling); err != nil || !slices.Equal(tried, []*sql.DB{primary}) {
+		t.Errorf("primary read = %v, %v; want the primary", tried, err)
+	}
+
+	// Errors other than a broken connection are the answer, not a reason to move on
+	tried := 0
+	err := m.read(func(db storage.Querier) error {
+		tried++
+		return sql.ErrNoRows
+	})
+	if !errors.Is(err, sql.ErrNoRows) || tried != 1 {
+		t.Errorf("read = %v after %d queries, want sql.ErrNoRows after 1", err, tried)
+	}
+
+	// Without replicas every read goes to the primary
+	plain := &MySQL{Db: primary, ctx: context.Background()}
+	if tried, err := read(plain); err != nil || !slices.Equal(tried, []*sql.DB{primary}) {
+		t.Errorf("read without replicas = %v, %v", tried, err)
+	}
+	plain.wrote()
+}
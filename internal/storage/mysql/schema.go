@@ -0,0 +1,123 @@
+package mysql
+
+// schema creates the tables this backend needs; every statement must be
+// safe to run against an existing database.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS students (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		name VARCHAR(255),
+		email VARCHAR(255),
+		age INT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS blobs (
+		sha256 CHAR(64) PRIMARY KEY,
+		size BIGINT NOT NULL,
+		path VARCHAR(1024) NOT NULL,
+		ref_count INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS files (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		student_id BIGINT NULL,
+		name VARCHAR(255) NOT NULL,
+		content_type VARCHAR(255) NOT NULL,
+		size BIGINT NOT NULL,
+		sha256 CHAR(64) NOT NULL,
+		path VARCHAR(1024) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_files_sha256 (sha256)
+	)`,
+	`CREATE TABLE IF NOT EXISTS tus_uploads (
+		id VARCHAR(64) PRIMARY KEY,
+		student_id BIGINT NULL,
+		name VARCHAR(255) NOT NULL,
+		content_type VARCHAR(255) NOT NULL,
+		length BIGINT NOT NULL,
+		upload_offset BIGINT NOT NULL DEFAULT 0,
+		path VARCHAR(1024) NOT NULL,
+		file_id BIGINT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS student_photos (
+		student_id BIGINT PRIMARY KEY,
+		original_file_id BIGINT NOT NULL,
+		medium_file_id BIGINT NOT NULL,
+		thumb_file_id BIGINT NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		actor VARCHAR(255) NOT NULL,
+		action VARCHAR(32) NOT NULL,
+		entity_type VARCHAR(64) NOT NULL,
+		entity_id BIGINT NOT NULL,
+		changes JSON NOT NULL,
+		request_id VARCHAR(128) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		INDEX idx_audit_log_entity (entity_type, entity_id)
+	)`,
+	// created_at is written in UTC and compared against UTC points in time,
+	// so like next_attempt_at it is a DATETIME free of the session time zone.
+	`CREATE TABLE IF NOT EXISTS student_revisions (
+		student_id BIGINT NOT NULL,
+		revision INT NOT NULL,
+		name VARCHAR(255),
+		email VARCHAR(255),
+		age INT,
+		deleted BOOLEAN NOT NULL DEFAULT FALSE,
+		actor VARCHAR(255) NOT NULL,
+		created_at DATETIME(6) NOT NULL,
+		PRIMARY KEY (student_id, revision)
+	)`,
+	// Students from before revisions were kept start with their current
+	// values, as of their last update. UNIX_TIMESTAMP takes the TIMESTAMP
+	// out of the session time zone.
+	`INSERT INTO student_revisions (student_id,revision,name,email,age,deleted,actor,created_at)
+		SELECT id,1,name,email,age,FALSE,'system',COALESCE(TIMESTAMPADD(SECOND,UNIX_TIMESTAMP(updated_at),'1970-01-01'),UTC_TIMESTAMP(6)) FROM students
+		WHERE id NOT IN (SELECT student_id FROM student_revisions)`,
+	`CREATE TABLE IF NOT EXISTS outbox (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		event_type VARCHAR(64) NOT NULL,
+		student_id BIGINT NOT NULL,
+		payload JSON NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		dispatched_at DATETIME(6) NULL,
+		INDEX idx_outbox_pending (dispatched_at, id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		url VARCHAR(2048) NOT NULL,
+		secret VARCHAR(255) NOT NULL,
+		events VARCHAR(1024) NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// next_attempt_at is always written by the API in UTC, so it is kept
+	// as a DATETIME free of the session time zone.
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id BIGINT AUTO_INCREMENT PRIMARY KEY,
+		event_id BIGINT NOT NULL,
+		subscription_id BIGINT NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME(6) NOT NULL,
+		last_error VARCHAR(1024) NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+		INDEX idx_webhook_deliveries_due (status, next_attempt_at),
+		INDEX idx_webhook_deliveries_subscription (subscription_id)
+	)`,
+	`CREATE TABLE IF NOT EXISTS idempotency_keys (
+		idempotency_key VARCHAR(255) PRIMARY KEY,
+		fingerprint CHAR(64) NOT NULL,
+		status_code INT NOT NULL DEFAULT 0,
+		header JSON NULL,
+		body LONGBLOB NULL,
+		created_at DATETIME(6) NOT NULL,
+		expires_at DATETIME(6) NOT NULL,
+		INDEX idx_idempotency_keys_expires (expires_at)
+	)`,
+}
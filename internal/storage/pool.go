@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/surajNirala/student-api/internal/config"
+)
+
+// Pinger is implemented by backends that can check their connection.
+type Pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// PoolStatser is implemented by backends built on a database/sql pool.
+type PoolStatser interface {
+	PoolStats() sql.DBStats
+}
+
+// ConfigurePool applies cfg to the pool of a SQL backend. Zero values
+// keep the database/sql defaults.
+func ConfigurePool(db *sql.DB, cfg config.Pool) {
+	if cfg.MaxOpenConns > 0 {
+		db.SetMaxOpenConns(cfg.MaxOpenConns)
+	}
+	if cfg.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	if cfg.ConnMaxLifetime > 0 {
+		db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	}
+	if cfg.ConnMaxIdleTime > 0 {
+		db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	}
+}
@@ -0,0 +1,45 @@
+package postgres
+
+import (
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// lockStudent reads the fields of a student that the audit log tracks, locking its row until tx ends.
+func lockStudent(tx *storage.Tx, id int64) (models.Student, error) {
+	var student models.Student
+	err := tx.QueryRow("SELECT id,name,email,age FROM students WHERE id = $1 FOR UPDATE", id).Scan(&student.Id, &student.Name, &student.Email, &student.Age)
+	return student, err
+}
+
+// audit records entry as part of tx, so a change and its audit entry are
+// committed or rolled back together.
+func (p *Postgres) audit(tx *storage.Tx, entry models.AuditEntry) error {
+	_, err := tx.Exec("INSERT INTO audit_log (actor,action,entity_type,entity_id,changes,request_id) VALUES ($1,$2,$3,$4,$5,$6)", entry.Actor, entry.Action, entry.EntityType, entry.EntityId, string(entry.Changes), entry.RequestId)
+	return err
+}
+
+func (p *Postgres) StudentHistory(studentID int64) ([]models.AuditEntry, error) {
+	var list []models.AuditEntry
+	stmt, err := p.conn().Prepare("SELECT id,actor,action,entity_type,entity_id,changes,request_id,created_at FROM audit_log WHERE entity_type = $1 AND entity_id = $2 ORDER BY id")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(storage.EntityStudent, studentID)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry models.AuditEntry
+		var changes []byte
+		err := rows.Scan(&entry.Id, &entry.Actor, &entry.Action, &entry.EntityType, &entry.EntityId, &changes, &entry.RequestId, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Changes = changes
+		list = append(list, entry)
+	}
+	return list, rows.Err()
+}
@@ -0,0 +1,65 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/models"
+)
+
+func (p *Postgres) ReserveIdempotencyKey(key string, fingerprint string, expires time.Time, stale time.Time) (models.IdempotencyKey, bool, error) {
+	now := time.Now().UTC()
+	if _, err := p.conn().Exec("DELETE FROM idempotency_keys WHERE idempotency_key = $1 AND (expires_at <= $2 OR (status_code = 0 AND created_at <= $3))", key, now, stale.UTC()); err != nil {
+		return models.IdempotencyKey{}, false, err
+	}
+	res, err := p.conn().Exec("INSERT INTO idempotency_keys (idempotency_key,fingerprint,created_at,expires_at) VALUES ($1,$2,$3,$4) ON CONFLICT DO NOTHING", key, fingerprint, now, expires.UTC())
+	if err != nil {
+		return models.IdempotencyKey{}, false, err
+	}
+	inserted, err := res.RowsAffected()
+	if err != nil {
+		return models.IdempotencyKey{}, false, err
+	}
+	if inserted == 1 {
+		return models.IdempotencyKey{Key: key, Fingerprint: fingerprint, CreatedAt: now, ExpiresAt: expires.UTC()}, true, nil
+	}
+	existing, err := scanIdempotencyKey(p.conn().QueryRow("SELECT idempotency_key,fingerprint,status_code,header,body,created_at,expires_at FROM idempotency_keys WHERE idempotency_key = $1", key))
+	return existing, false, err
+}
+
+func scanIdempotencyKey(row *sql.Row) (models.IdempotencyKey, error) {
+	var key models.IdempotencyKey
+	var header []byte
+	if err := row.Scan(&key.Key, &key.Fingerprint, &key.StatusCode, &header, &key.Body, &key.CreatedAt, &key.ExpiresAt); err != nil {
+		return models.IdempotencyKey{}, err
+	}
+	if len(header) > 0 {
+		if err := json.Unmarshal(header, &key.Header); err != nil {
+			return models.IdempotencyKey{}, err
+		}
+	}
+	return key, nil
+}
+
+func (p *Postgres) CompleteIdempotencyKey(key models.IdempotencyKey) error {
+	header, err := json.Marshal(key.Header)
+	if err != nil {
+		return err
+	}
+	_, err = p.conn().Exec("UPDATE idempotency_keys SET status_code = $1, header = $2, body = $3 WHERE idempotency_key = $4", key.StatusCode, string(header), key.Body, key.Key)
+	return err
+}
+
+func (p *Postgres) DeleteIdempotencyKey(key string) error {
+	_, err := p.conn().Exec("DELETE FROM idempotency_keys WHERE idempotency_key = $1", key)
+	return err
+}
+
+func (p *Postgres) DeleteExpiredIdempotencyKeys(before time.Time) (int64, error) {
+	res, err := p.conn().Exec("DELETE FROM idempotency_keys WHERE expires_at < $1", before.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
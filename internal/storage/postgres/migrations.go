@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"database/sql"
+	"fmt"
+)
+
+// migrations are applied in order, each at most once; the number of the
+// last one applied is kept in schema_migrations. Append new migrations,
+// never edit or reorder the ones already released.
+var migrations = []string{
+	`CREATE TABLE students (
+		id BIGSERIAL PRIMARY KEY,
+		name TEXT,
+		email TEXT,
+		age INT,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE blobs (
+		sha256 CHAR(64) PRIMARY KEY,
+		size BIGINT NOT NULL,
+		path VARCHAR(1024) NOT NULL,
+		ref_count INT NOT NULL DEFAULT 0,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE files (
+		id BIGSERIAL PRIMARY KEY,
+		student_id BIGINT NULL,
+		name VARCHAR(255) NOT NULL,
+		content_type VARCHAR(255) NOT NULL,
+		size BIGINT NOT NULL,
+		sha256 CHAR(64) NOT NULL,
+		path VARCHAR(1024) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX idx_files_sha256 ON files (sha256)`,
+	`CREATE INDEX idx_files_student_id ON files (student_id)`,
+	`CREATE TABLE tus_uploads (
+		id VARCHAR(64) PRIMARY KEY,
+		student_id BIGINT NULL,
+		name VARCHAR(255) NOT NULL,
+		content_type VARCHAR(255) NOT NULL,
+		length BIGINT NOT NULL,
+		upload_offset BIGINT NOT NULL DEFAULT 0,
+		path VARCHAR(1024) NOT NULL,
+		file_id BIGINT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE student_photos (
+		student_id BIGINT PRIMARY KEY,
+		original_file_id BIGINT NOT NULL,
+		medium_file_id BIGINT NOT NULL,
+		thumb_file_id BIGINT NOT NULL,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE audit_log (
+		id BIGSERIAL PRIMARY KEY,
+		actor VARCHAR(255) NOT NULL,
+		action VARCHAR(32) NOT NULL,
+		entity_type VARCHAR(64) NOT NULL,
+		entity_id BIGINT NOT NULL,
+		changes JSONB NOT NULL,
+		request_id VARCHAR(128) NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX idx_audit_log_entity ON audit_log (entity_type, entity_id)`,
+	`CREATE TABLE student_revisions (
+		student_id BIGINT NOT NULL,
+		revision INT NOT NULL,
+		name TEXT,
+		email TEXT,
+		age INT,
+		deleted BOOLEAN NOT NULL DEFAULT FALSE,
+		actor VARCHAR(255) NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (student_id, revision)
+	)`,
+	`INSERT INTO student_revisions (student_id,revision,name,email,age,deleted,actor,created_at)
+		SELECT id,1,name,email,age,FALSE,'system',updated_at FROM students`,
+	`CREATE TABLE outbox (
+		id BIGSERIAL PRIMARY KEY,
+		event_type VARCHAR(64) NOT NULL,
+		student_id BIGINT NOT NULL,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		dispatched_at TIMESTAMPTZ NULL
+	)`,
+	`CREATE INDEX idx_outbox_pending ON outbox (id) WHERE dispatched_at IS NULL`,
+	`CREATE TABLE webhook_subscriptions (
+		id BIGSERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE webhook_deliveries (
+		id BIGSERIAL PRIMARY KEY,
+		event_id BIGINT NOT NULL,
+		subscription_id BIGINT NOT NULL,
+		status VARCHAR(16) NOT NULL,
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMPTZ NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+	`CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries (subscription_id)`,
+	`CREATE TABLE idempotency_keys (
+		idempotency_key VARCHAR(255) PRIMARY KEY,
+		fingerprint CHAR(64) NOT NULL,
+		status_code INT NOT NULL DEFAULT 0,
+		header JSONB,
+		body BYTEA,
+		created_at TIMESTAMPTZ NOT NULL,
+		expires_at TIMESTAMPTZ NOT NULL
+	)`,
+	`CREATE INDEX idx_idempotency_keys_expires ON idempotency_keys (expires_at)`,
+}
+
+// migrate brings the database up to date with migrations. Each migration
+// runs in its own transaction together with the version bump, and a lock
+// keeps instances starting at the same time from applying it twice.
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INT NOT NULL)"); err != nil {
+		return err
+	}
+	for {
+		applied, err := migrateNext(db)
+		if err != nil {
+			return err
+		}
+		if !applied {
+			return nil
+		}
+	}
+}
+
+func migrateNext(db *sql.DB) (bool, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("LOCK TABLE schema_migrations IN EXCLUSIVE MODE"); err != nil {
+		return false, err
+	}
+	var version int
+	if err := tx.QueryRow("SELECT COALESCE(MAX(version),0) FROM schema_migrations").Scan(&version); err != nil {
+		return false, err
+	}
+	if version >= len(migrations) {
+		return false, nil
+	}
+	if _, err := tx.Exec(migrations[version]); err != nil {
+		return false, fmt.Errorf("migration %d: %w", version+1, err)
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version+1); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
@@ -0,0 +1,714 @@
+package postgres
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/lib/pq"
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/filestore"
+	"github.com/surajNirala/student-api/internal/utils/backoff"
+	"github.com/surajNirala/student-api/internal/utils/digest"
+)
+
+type Postgres struct {
+	Db  *sql.DB
+	ctx context.Context
+}
+
+func init() {
+	storage.Register("postgres", func(cfg *config.Config) (storage.Storage, error) {
+		if cfg.Postgres == nil {
+			return nil, errors.New("postgres section is missing")
+		}
+		return New(cfg)
+	})
+}
+
+func New(cfg *config.Config) (*Postgres, error) {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.Postgres.User, cfg.Postgres.Password),
+		Host:   fmt.Sprintf("%s:%d", cfg.Postgres.Host, cfg.Postgres.Port),
+		Path:   cfg.Postgres.DBName,
+		RawQuery: url.Values{
+			"sslmode":         {cfg.Postgres.SSLMode},
+			"connect_timeout": {strconv.Itoa(int(cfg.Postgres.ConnectTimeout.Round(time.Second).Seconds()))},
+		}.Encode(),
+	}
+	db, err := sql.Open("postgres", dsn.String())
+	if err != nil {
+		return nil, err
+	}
+	storage.ConfigurePool(db, cfg.Storage.Pool)
+
+	if err = backoff.Until(context.Background(), backoff.New(cfg.Storage.Retry), cfg.Storage.Retry.MaxWait, "PostgreSQL", db.Ping); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err = migrate(db); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Postgres{Db: db, ctx: context.Background()}, nil
+}
+
+// IsTransient reports serialization failures, deadlocks, a server that is
+// shutting down or starting up, and broken connections.
+func (p *Postgres) IsTransient(err error) bool {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code {
+		case "40001", "40P01", "57P01", "57P03":
+			return true
+		}
+		return pqErr.Code.Class() == "08"
+	}
+	return storage.IsConnectionError(err)
+}
+
+func (p *Postgres) Ping(ctx context.Context) error {
+	return p.Db.PingContext(ctx)
+}
+
+func (p *Postgres) PoolStats() sql.DBStats {
+	return p.Db.Stats()
+}
+
+func (p *Postgres) WithContext(ctx context.Context) storage.Storage {
+	scoped := *p
+	scoped.ctx = ctx
+	return &scoped
+}
+
+func (p *Postgres) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return storage.RunInTx(ctx, p.Db, fn)
+}
+
+// conn is where statements outside a transaction of their own run.
+func (p *Postgres) conn() storage.Querier {
+	return storage.Conn(p.ctx, p.Db)
+}
+
+func (p *Postgres) StudentList() ([]models.Student, error) {
+	var list []models.Student
+	stmt, err := p.conn().Prepare("SELECT s.id,s.name,s.email,s.age,s.created_at,s.updated_at,p.student_id FROM students s LEFT JOIN student_photos p ON p.student_id = s.id ORDER BY s.id DESC")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query()
+	if err != nil {
+		return list, err
+	}
+	for rows.Next() {
+		var student models.Student
+		var photo sql.NullInt64
+		err := rows.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.CreatedAt, &student.UpdatedAt, &photo)
+		if err != nil {
+			return nil, err
+		}
+		if photo.Valid {
+			student.PhotoURL = models.StudentPhotoURL(student.Id)
+		}
+
+		list = append(list, student)
+	}
+	return list, nil
+}
+
+func (p *Postgres) CreateStudent(name string, email string, age int) (int64, error) {
+	tx, err := storage.Begin(p.ctx, p.Db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var lastID int64
+	if err := tx.QueryRow("INSERT INTO students (name,email,age) VALUES ($1,$2,$3) RETURNING id", name, email, age).Scan(&lastID); err != nil {
+		return 0, err
+	}
+	changes := storage.StudentChanges(nil, &models.Student{Name: name, Email: email, Age: age})
+	if err := p.audit(tx, storage.NewAuditEntry(p.ctx, storage.AuditCreate, storage.EntityStudent, lastID, changes)); err != nil {
+		return 0, err
+	}
+	created := models.Student{Id: uint64(lastID), Name: name, Email: email, Age: age}
+	if _, err := p.revise(tx, created, false); err != nil {
+		return 0, err
+	}
+	if err := p.publish(tx, storage.EventStudentCreated, created); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return lastID, nil
+}
+
+func (p *Postgres) GetStudentByID(id int64) (models.Student, error) {
+	var student models.Student
+	stmt, err := p.conn().Prepare("SELECT s.id,s.name,s.email,s.age,s.created_at,s.updated_at,p.student_id FROM students s LEFT JOIN student_photos p ON p.student_id = s.id WHERE s.id = $1")
+	if err != nil {
+		return student, err
+	}
+	defer stmt.Close()
+	row := stmt.QueryRow(id)
+	var photo sql.NullInt64
+	err = row.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.CreatedAt, &student.UpdatedAt, &photo)
+	if err != nil {
+		return student, err
+	}
+	if photo.Valid {
+		student.PhotoURL = models.StudentPhotoURL(student.Id)
+	}
+	return student, nil
+}
+
+func (p *Postgres) DeleteStudentByID(id int64) (string, error) {
+	tx, err := storage.Begin(p.ctx, p.Db)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	before, err := lockStudent(tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("no student found with id %d: %w", id, sql.ErrNoRows)
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("DELETE FROM students WHERE id = $1", id); err != nil {
+		return "", err
+	}
+	// A reverted student must not come back with a photo whose files are
+	// gone; they are orphaned now and left to garbage collection.
+	if _, err := tx.Exec("DELETE FROM student_photos WHERE student_id = $1", id); err != nil {
+		return "", err
+	}
+	changes := storage.StudentChanges(&before, nil)
+	if err := p.audit(tx, storage.NewAuditEntry(p.ctx, storage.AuditDelete, storage.EntityStudent, id, changes)); err != nil {
+		return "", err
+	}
+	if _, err := p.revise(tx, before, true); err != nil {
+		return "", err
+	}
+	if err := p.publish(tx, storage.EventStudentDeleted, before); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("student with id %d deleted successfully", id), nil
+}
+
+func (p *Postgres) UpdateStudentByID(name string, email string, age int, id int64) (string, error) {
+	tx, err := storage.Begin(p.ctx, p.Db)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	before, err := lockStudent(tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("no student found with id %d: %w", id, sql.ErrNoRows)
+	}
+	if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec("UPDATE students SET name = $1, email = $2, age = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $4", name, email, age, id); err != nil {
+		return "", err
+	}
+	after := before
+	after.Name, after.Email, after.Age = name, email, age
+	changes := storage.StudentChanges(&before, &after)
+	if err := p.audit(tx, storage.NewAuditEntry(p.ctx, storage.AuditUpdate, storage.EntityStudent, id, changes)); err != nil {
+		return "", err
+	}
+	if _, err := p.revise(tx, after, false); err != nil {
+		return "", err
+	}
+	if err := p.publish(tx, storage.EventStudentUpdated, after); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("student with id %d updated successfully", id), nil
+}
+
+func (p *Postgres) StudentFileUpload10MB(studentID int64, fileName string, contentType string, fileData []byte) (models.File, error) {
+	return p.StudentLargeFileUpload(studentID, fileName, contentType, bytes.NewReader(fileData))
+}
+
+func (p *Postgres) StudentLargeFileUpload(studentID int64, fileName string, contentType string, fileReader io.Reader) (models.File, error) {
+	blob, err := filestore.Save(fileReader)
+	if err != nil {
+		return models.File{}, err
+	}
+	file, err := p.addFile(studentID, fileName, contentType, blob)
+	if err != nil {
+		filestore.Remove(blob.Path)
+	}
+	return file, err
+}
+
+// addFile commits blob under its digest, sharing the stored copy when the
+// same content was uploaded before, and records a file pointing at it.
+func (p *Postgres) addFile(studentID int64, fileName string, contentType string, blob filestore.Blob) (models.File, error) {
+	// The reference is recorded before the content is committed to disk,
+	// so a delete waiting on the lock sees it and leaves the copy alone
+	unlock := filestore.LockBlob(blob.SHA256)
+	defer unlock()
+	blobPath := filestore.BlobPath(blob.SHA256)
+	tx, err := storage.Begin(p.ctx, p.Db)
+	if err != nil {
+		return models.File{}, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec("INSERT INTO blobs (sha256,size,path,ref_count) VALUES ($1,$2,$3,1) ON CONFLICT (sha256) DO UPDATE SET ref_count = blobs.ref_count + 1", blob.SHA256, blob.Size, blobPath)
+	if err != nil {
+		return models.File{}, err
+	}
+	owner := sql.NullInt64{Int64: studentID, Valid: studentID != 0}
+	var lastID int64
+	err = tx.QueryRow("INSERT INTO files (student_id,name,content_type,size,sha256,path) VALUES ($1,$2,$3,$4,$5,$6) RETURNING id", owner, fileName, contentType, blob.Size, blob.SHA256, blobPath).Scan(&lastID)
+	if err != nil {
+		return models.File{}, err
+	}
+	if _, err := filestore.Commit(blob); err != nil {
+		return models.File{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.File{}, err
+	}
+	return p.GetFileByID(lastID)
+}
+
+func (p *Postgres) OpenFile(id int64) (models.File, io.ReadSeekCloser, error) {
+	file, err := p.GetFileByID(id)
+	if err != nil {
+		return file, nil, err
+	}
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return file, nil, err
+	}
+	return file, f, nil
+}
+
+func (p *Postgres) DeleteFileByID(id int64) (string, error) {
+	file, err := p.GetFileByID(id)
+	if err != nil {
+		return "", err
+	}
+	unlock := filestore.LockBlob(file.SHA256)
+	defer unlock()
+	tx, err := storage.Begin(p.ctx, p.Db)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback()
+
+	deleted, err := tx.Exec("DELETE FROM files WHERE id = $1", id)
+	if err != nil {
+		return "", err
+	}
+	n, err := deleted.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	// A concurrent delete of the same file got here first
+	if n == 0 {
+		return "", sql.ErrNoRows
+	}
+	if _, err := tx.Exec("UPDATE blobs SET ref_count = ref_count - 1 WHERE sha256 = $1", file.SHA256); err != nil {
+		return "", err
+	}
+	result, err := tx.Exec("DELETE FROM blobs WHERE sha256 = $1 AND ref_count <= 0", file.SHA256)
+	if err != nil {
+		return "", err
+	}
+	unreferenced, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	// Other files may still share the content; only the last one removes it
+	if unreferenced > 0 {
+		if err := storage.RemoveBlob(p.ctx, p, file.SHA256, file.Path); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("file with id %d deleted successfully", id), nil
+}
+
+func (p *Postgres) GetFileByID(id int64) (models.File, error) {
+	var file models.File
+	var owner sql.NullInt64
+	stmt, err := p.conn().Prepare("SELECT id,student_id,name,content_type,size,sha256,path,created_at,updated_at FROM files WHERE id = $1")
+	if err != nil {
+		return file, err
+	}
+	defer stmt.Close()
+	row := stmt.QueryRow(id)
+	err = row.Scan(&file.Id, &owner, &file.Name, &file.ContentType, &file.Size, &file.SHA256, &file.Path, &file.CreatedAt, &file.UpdatedAt)
+	if err != nil {
+		return file, err
+	}
+	file.StudentId = owner.Int64
+	return file, nil
+}
+
+func (p *Postgres) StudentFileUsage(studentID int64) (models.FileUsage, error) {
+	usage := models.FileUsage{StudentId: studentID}
+	stmt, err := p.conn().Prepare("SELECT COUNT(*),COALESCE(SUM(size),0)::BIGINT FROM files WHERE student_id = $1")
+	if err != nil {
+		return usage, err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow(studentID).Scan(&usage.Files, &usage.Bytes)
+	return usage, err
+}
+
+// TotalFileUsage counts every file but measures the bytes actually on
+// disk, where content shared by several files is stored once.
+func (p *Postgres) TotalFileUsage() (models.FileUsage, error) {
+	var usage models.FileUsage
+	stmt, err := p.conn().Prepare("SELECT (SELECT COUNT(*) FROM files),(SELECT COALESCE(SUM(size),0)::BIGINT FROM blobs)")
+	if err != nil {
+		return usage, err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow().Scan(&usage.Files, &usage.Bytes)
+	return usage, err
+}
+
+func (p *Postgres) StudentUploadUsage(studentID int64) (models.FileUsage, error) {
+	usage := models.FileUsage{StudentId: studentID}
+	stmt, err := p.conn().Prepare("SELECT COUNT(*),COALESCE(SUM(upload_offset),0)::BIGINT FROM tus_uploads WHERE student_id = $1 AND upload_offset < length")
+	if err != nil {
+		return usage, err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow(studentID).Scan(&usage.Files, &usage.Bytes)
+	return usage, err
+}
+
+func (p *Postgres) TotalUploadUsage() (models.FileUsage, error) {
+	var usage models.FileUsage
+	stmt, err := p.conn().Prepare("SELECT COUNT(*),COALESCE(SUM(upload_offset),0)::BIGINT FROM tus_uploads WHERE upload_offset < length")
+	if err != nil {
+		return usage, err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow().Scan(&usage.Files, &usage.Bytes)
+	return usage, err
+}
+
+// SetStudentPhoto points a student at a new set of photo files and
+// releases the files of the photo it replaces.
+func (p *Postgres) SetStudentPhoto(studentID int64, photo models.Photo) error {
+	tx, err := storage.Begin(p.ctx, p.Db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous models.Photo
+	err = tx.QueryRow("SELECT original_file_id,medium_file_id,thumb_file_id FROM student_photos WHERE student_id = $1 FOR UPDATE", studentID).Scan(&previous.OriginalFileId, &previous.MediumFileId, &previous.ThumbFileId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO student_photos (student_id,original_file_id,medium_file_id,thumb_file_id) VALUES ($1,$2,$3,$4) ON CONFLICT (student_id) DO UPDATE SET original_file_id = EXCLUDED.original_file_id, medium_file_id = EXCLUDED.medium_file_id, thumb_file_id = EXCLUDED.thumb_file_id, updated_at = CURRENT_TIMESTAMP", studentID, photo.OriginalFileId, photo.MediumFileId, photo.ThumbFileId); err != nil {
+		return err
+	}
+	changes := storage.PhotoChanges(previous.OriginalFileId, photo.OriginalFileId)
+	if err := p.audit(tx, storage.NewAuditEntry(p.ctx, storage.AuditPhoto, storage.EntityStudent, studentID, changes)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if previous.OriginalFileId != 0 {
+		for _, id := range []uint64{previous.OriginalFileId, previous.MediumFileId, previous.ThumbFileId} {
+			if _, err := p.DeleteFileByID(int64(id)); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Postgres) GetStudentPhoto(studentID int64) (models.Photo, error) {
+	var photo models.Photo
+	stmt, err := p.conn().Prepare("SELECT student_id,original_file_id,medium_file_id,thumb_file_id,updated_at FROM student_photos WHERE student_id = $1")
+	if err != nil {
+		return photo, err
+	}
+	defer stmt.Close()
+	row := stmt.QueryRow(studentID)
+	err = row.Scan(&photo.StudentId, &photo.OriginalFileId, &photo.MediumFileId, &photo.ThumbFileId, &photo.UpdatedAt)
+	if err != nil {
+		return photo, err
+	}
+	return photo, nil
+}
+
+func (p *Postgres) CreateUpload(studentID int64, fileName string, contentType string, length int64) (models.Upload, error) {
+	id, filePath, err := filestore.CreatePartial()
+	if err != nil {
+		return models.Upload{}, err
+	}
+	stmt, err := p.conn().Prepare("INSERT INTO tus_uploads (id,student_id,name,content_type,length,upload_offset,path) VALUES ($1,$2,$3,$4,$5,0,$6)")
+	if err != nil {
+		filestore.Remove(filePath)
+		return models.Upload{}, err
+	}
+	defer stmt.Close()
+	owner := sql.NullInt64{Int64: studentID, Valid: studentID != 0}
+	if _, err := stmt.Exec(id, owner, fileName, contentType, length, filePath); err != nil {
+		filestore.Remove(filePath)
+		return models.Upload{}, err
+	}
+	return p.GetUpload(id)
+}
+
+func (p *Postgres) GetUpload(id string) (models.Upload, error) {
+	var upload models.Upload
+	var owner, fileID sql.NullInt64
+	stmt, err := p.conn().Prepare("SELECT id,student_id,name,content_type,length,upload_offset,path,file_id,created_at,updated_at FROM tus_uploads WHERE id = $1")
+	if err != nil {
+		return upload, err
+	}
+	defer stmt.Close()
+	row := stmt.QueryRow(id)
+	err = row.Scan(&upload.Id, &owner, &upload.Name, &upload.ContentType, &upload.Length, &upload.Offset, &upload.Path, &fileID, &upload.CreatedAt, &upload.UpdatedAt)
+	if err != nil {
+		return upload, err
+	}
+	upload.StudentId = owner.Int64
+	upload.FileId = uint64(fileID.Int64)
+	return upload, nil
+}
+
+func (p *Postgres) WriteUploadChunk(id string, offset int64, data io.Reader) (models.Upload, error) {
+	// A retried PATCH may race the one it retries; only one of them may write at offset
+	unlock := filestore.LockUpload(id)
+	defer unlock()
+	upload, err := p.GetUpload(id)
+	if err != nil {
+		return upload, err
+	}
+	if upload.Offset != offset || upload.Complete() {
+		return upload, storage.ErrOffsetMismatch
+	}
+	// Read one byte past the remaining length to notice oversized chunks
+	written, copyErr := filestore.WritePartial(upload.Path, offset, io.LimitReader(data, upload.Length-offset+1))
+	if copyErr == nil && offset+written > upload.Length {
+		copyErr = storage.ErrChunkTooLarge
+	}
+	if errors.Is(copyErr, storage.ErrChunkTooLarge) || errors.Is(copyErr, digest.ErrMismatch) {
+		// Rejected chunks are discarded as a whole
+		if err := filestore.Truncate(upload.Path, offset); err != nil {
+			return upload, err
+		}
+		return upload, copyErr
+	}
+
+	// Record whatever arrived, even on a dropped connection, so the client can resume from there
+	stmt, err := p.conn().Prepare("UPDATE tus_uploads SET upload_offset = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND upload_offset = $3")
+	if err != nil {
+		return upload, err
+	}
+	defer stmt.Close()
+	result, err := stmt.Exec(offset+written, id, offset)
+	if err != nil {
+		return upload, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return upload, err
+	}
+	if rowsAffected == 0 {
+		return upload, storage.ErrOffsetMismatch
+	}
+	upload.Offset = offset + written
+	if copyErr != nil || !upload.Complete() {
+		return upload, copyErr
+	}
+	return p.completeUpload(upload)
+}
+
+// completeUpload turns a fully received upload into a regular file.
+func (p *Postgres) completeUpload(upload models.Upload) (models.Upload, error) {
+	// Trust the received bytes over the type declared when the upload was created
+	if mime, err := mimetype.DetectFile(upload.Path); err == nil {
+		upload.ContentType = mime.String()
+	}
+	blob, err := filestore.Hash(upload.Path)
+	if err != nil {
+		return upload, err
+	}
+	file, err := p.addFile(upload.StudentId, upload.Name, upload.ContentType, blob)
+	if err != nil {
+		return upload, err
+	}
+	stmt, err := p.conn().Prepare("UPDATE tus_uploads SET file_id = $1, path = $2, updated_at = CURRENT_TIMESTAMP WHERE id = $3")
+	if err != nil {
+		return upload, err
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(file.Id, file.Path, upload.Id); err != nil {
+		return upload, err
+	}
+	upload.FileId = file.Id
+	upload.Path = file.Path
+	return upload, nil
+}
+
+func (p *Postgres) DeleteUpload(id string) error {
+	upload, err := p.GetUpload(id)
+	if err != nil {
+		return err
+	}
+	stmt, err := p.conn().Prepare("DELETE FROM tus_uploads WHERE id = $1")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(id); err != nil {
+		return err
+	}
+	// A completed upload's bytes now belong to its file
+	if upload.Complete() {
+		return nil
+	}
+	return storage.RemoveFile(p.ctx, upload.Path)
+}
+
+func (p *Postgres) ExpiredUploads(before time.Time) ([]models.Upload, error) {
+	var list []models.Upload
+	stmt, err := p.conn().Prepare("SELECT id,student_id,name,content_type,length,upload_offset,path,file_id,created_at,updated_at FROM tus_uploads WHERE updated_at < $1")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(before.UTC())
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var upload models.Upload
+		var owner, fileID sql.NullInt64
+		err := rows.Scan(&upload.Id, &owner, &upload.Name, &upload.ContentType, &upload.Length, &upload.Offset, &upload.Path, &fileID, &upload.CreatedAt, &upload.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		upload.StudentId = owner.Int64
+		upload.FileId = uint64(fileID.Int64)
+		list = append(list, upload)
+	}
+	return list, rows.Err()
+}
+
+// OrphanedFiles lists files whose owning student has been deleted.
+func (p *Postgres) OrphanedFiles() ([]models.File, error) {
+	var list []models.File
+	stmt, err := p.conn().Prepare("SELECT f.id,f.student_id,f.name,f.content_type,f.size,f.sha256,f.path,f.created_at,f.updated_at FROM files f LEFT JOIN students s ON s.id = f.student_id WHERE f.student_id IS NOT NULL AND s.id IS NULL")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query()
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var file models.File
+		var owner sql.NullInt64
+		err := rows.Scan(&file.Id, &owner, &file.Name, &file.ContentType, &file.Size, &file.SHA256, &file.Path, &file.CreatedAt, &file.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		file.StudentId = owner.Int64
+		list = append(list, file)
+	}
+	return list, rows.Err()
+}
+
+// UnreferencedBlobs lists stored content that no file points at, whatever
+// its reference count claims.
+func (p *Postgres) UnreferencedBlobs() ([]models.Blob, error) {
+	var list []models.Blob
+	stmt, err := p.conn().Prepare("SELECT b.sha256,b.size,b.path,b.ref_count,b.created_at FROM blobs b WHERE NOT EXISTS (SELECT 1 FROM files f WHERE f.sha256 = b.sha256)")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query()
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var blob models.Blob
+		if err := rows.Scan(&blob.SHA256, &blob.Size, &blob.Path, &blob.RefCount, &blob.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, blob)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) HasBlob(sha256 string) (bool, error) {
+	stmt, err := p.conn().Prepare("SELECT COUNT(*) FROM blobs WHERE sha256 = $1")
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	var count int64
+	err = stmt.QueryRow(sha256).Scan(&count)
+	return count > 0, err
+}
+
+// DeleteBlob removes stored content, provided no file has started
+// pointing at it again. It reports whether anything was removed.
+func (p *Postgres) DeleteBlob(sha256 string) (bool, error) {
+	unlock := filestore.LockBlob(sha256)
+	defer unlock()
+	var blobPath string
+	stmt, err := p.conn().Prepare("SELECT path FROM blobs WHERE sha256 = $1")
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	if err := stmt.QueryRow(sha256).Scan(&blobPath); err != nil {
+		return false, err
+	}
+	result, err := p.conn().Exec("DELETE FROM blobs WHERE sha256 = $1 AND NOT EXISTS (SELECT 1 FROM files WHERE sha256 = $1)", sha256)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+	return true, storage.RemoveBlob(p.ctx, p, sha256, blobPath)
+}
@@ -0,0 +1,52 @@
+package postgres
+
+import (
+	"os"
+	"strconv"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/storagetest"
+)
+
+// The suite empties every table it uses, so point POSTGRES_TEST_HOST at a
+// throwaway database. POSTGRES_TEST_PORT, POSTGRES_TEST_USER,
+// POSTGRES_TEST_PASSWORD, POSTGRES_TEST_DBNAME and POSTGRES_TEST_SSLMODE
+// override the defaults below.
+func TestConformance(t *testing.T) {
+	host := os.Getenv("POSTGRES_TEST_HOST")
+	if host == "" {
+		t.Skip("POSTGRES_TEST_HOST not set")
+	}
+	port, err := strconv.Atoi(envOr("POSTGRES_TEST_PORT", "5432"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Postgres: &config.PostgresConfig{
+		Host:     host,
+		Port:     port,
+		User:     envOr("POSTGRES_TEST_USER", "postgres"),
+		Password: os.Getenv("POSTGRES_TEST_PASSWORD"),
+		DBName:   envOr("POSTGRES_TEST_DBNAME", "student_api_test"),
+		SSLMode:  envOr("POSTGRES_TEST_SSLMODE", "disable"),
+	}}
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		p, err := New(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { p.Db.Close() })
+		if _, err := p.Db.Exec("TRUNCATE TABLE students, files, blobs, tus_uploads, student_photos, audit_log, student_revisions, outbox, webhook_subscriptions, webhook_deliveries, idempotency_keys RESTART IDENTITY"); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	})
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// revise records student as its next revision in tx and returns the
+// revision number. Callers hold the student's row, so revisions of one
+// student are numbered one at a time.
+func (p *Postgres) revise(tx *storage.Tx, student models.Student, deleted bool) (int, error) {
+	var revision int
+	err := tx.QueryRow("SELECT COALESCE(MAX(revision),0)+1 FROM student_revisions WHERE student_id = $1", student.Id).Scan(&revision)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("INSERT INTO student_revisions (student_id,revision,name,email,age,deleted,actor) VALUES ($1,$2,$3,$4,$5,$6,$7)", student.Id, revision, student.Name, student.Email, student.Age, deleted, storage.ActorFromContext(p.ctx))
+	return revision, err
+}
+
+func studentRevision(tx *storage.Tx, studentID int64, revision int) (models.StudentRevision, error) {
+	var rev models.StudentRevision
+	err := tx.QueryRow("SELECT student_id,revision,name,email,age,deleted,actor,created_at FROM student_revisions WHERE student_id = $1 AND revision = $2", studentID, revision).Scan(&rev.StudentId, &rev.Revision, &rev.Name, &rev.Email, &rev.Age, &rev.Deleted, &rev.Actor, &rev.CreatedAt)
+	return rev, err
+}
+
+func (p *Postgres) StudentRevisions(studentID int64) ([]models.StudentRevision, error) {
+	var list []models.StudentRevision
+	stmt, err := p.conn().Prepare("SELECT student_id,revision,name,email,age,deleted,actor,created_at FROM student_revisions WHERE student_id = $1 ORDER BY revision")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(studentID)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rev models.StudentRevision
+		err := rows.Scan(&rev.StudentId, &rev.Revision, &rev.Name, &rev.Email, &rev.Age, &rev.Deleted, &rev.Actor, &rev.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, rev)
+	}
+	return list, rows.Err()
+}
+
+func (p *Postgres) StudentAsOf(studentID int64, at time.Time) (models.Student, error) {
+	var rev models.StudentRevision
+	var created time.Time
+	err := p.conn().QueryRow("SELECT r.student_id,r.name,r.email,r.age,r.deleted,r.created_at,f.created_at FROM student_revisions r JOIN student_revisions f ON f.student_id = r.student_id AND f.revision = 1 WHERE r.student_id = $1 AND r.created_at <= $2 ORDER BY r.revision DESC LIMIT 1", studentID, at.UTC()).Scan(&rev.StudentId, &rev.Name, &rev.Email, &rev.Age, &rev.Deleted, &rev.CreatedAt, &created)
+	if err != nil {
+		return models.Student{}, err
+	}
+	if rev.Deleted {
+		return models.Student{}, sql.ErrNoRows
+	}
+	student := rev.Student()
+	student.CreatedAt = created
+	return student, nil
+}
+
+func (p *Postgres) RevertStudent(studentID int64, revision int) (models.StudentRevision, error) {
+	tx, err := storage.Begin(p.ctx, p.Db)
+	if err != nil {
+		return models.StudentRevision{}, err
+	}
+	defer tx.Rollback()
+
+	target, err := studentRevision(tx, studentID, revision)
+	if err != nil {
+		return models.StudentRevision{}, err
+	}
+	if target.Deleted {
+		return models.StudentRevision{}, storage.ErrDeletedRevision
+	}
+	after := target.Student()
+	var changes json.RawMessage
+	event := storage.EventStudentUpdated
+	before, err := lockStudent(tx, studentID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Deleted since the revision, so it comes back under its old id
+		if _, err := tx.Exec("INSERT INTO students (id,name,email,age) VALUES ($1,$2,$3,$4)", studentID, after.Name, after.Email, after.Age); err != nil {
+			return models.StudentRevision{}, err
+		}
+		changes = storage.StudentChanges(nil, &after)
+		event = storage.EventStudentCreated
+	case err != nil:
+		return models.StudentRevision{}, err
+	default:
+		if _, err := tx.Exec("UPDATE students SET name = $1, email = $2, age = $3, updated_at = CURRENT_TIMESTAMP WHERE id = $4", after.Name, after.Email, after.Age, studentID); err != nil {
+			return models.StudentRevision{}, err
+		}
+		changes = storage.StudentChanges(&before, &after)
+	}
+	if err := p.audit(tx, storage.NewAuditEntry(p.ctx, storage.AuditRevert, storage.EntityStudent, studentID, changes)); err != nil {
+		return models.StudentRevision{}, err
+	}
+	current, err := p.revise(tx, after, false)
+	if err != nil {
+		return models.StudentRevision{}, err
+	}
+	if err := p.publish(tx, event, after); err != nil {
+		return models.StudentRevision{}, err
+	}
+	rev, err := studentRevision(tx, studentID, current)
+	if err != nil {
+		return models.StudentRevision{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.StudentRevision{}, err
+	}
+	return rev, nil
+}
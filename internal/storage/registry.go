@@ -0,0 +1,65 @@
+package storage
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/surajNirala/student-api/internal/config"
+)
+
+// Factory opens a backend from the application config.
+type Factory func(cfg *config.Config) (Storage, error)
+
+var (
+	driversMu sync.RWMutex
+	drivers   = make(map[string]Factory)
+)
+
+// Register makes a backend available under name, normally from the init
+// function of its package. It panics when name is taken, as database/sql
+// does for duplicate drivers.
+func Register(name string, factory Factory) {
+	driversMu.Lock()
+	defer driversMu.Unlock()
+	if factory == nil {
+		panic("storage: Register factory is nil")
+	}
+	if _, dup := drivers[name]; dup {
+		panic("storage: Register called twice for driver " + name)
+	}
+	drivers[name] = factory
+}
+
+// Drivers returns the names of the registered backends, sorted.
+func Drivers() []string {
+	driversMu.RLock()
+	defer driversMu.RUnlock()
+	var names []string
+	for name := range drivers {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Open starts the backend named by cfg.Storage.Driver, retrying its
+// transient errors as configured.
+func Open(cfg *config.Config) (Storage, error) {
+	name := cfg.Storage.Driver
+	if name == "" {
+		return nil, fmt.Errorf("no storage driver configured, set storage.driver to one of: %s", strings.Join(Drivers(), ", "))
+	}
+	driversMu.RLock()
+	factory, ok := drivers[name]
+	driversMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown storage driver %q, available drivers: %s", name, strings.Join(Drivers(), ", "))
+	}
+	storage, err := factory(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("storage driver %s: %w", name, err)
+	}
+	return WithRetry(storage, cfg.Storage.Retry), nil
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/surajNirala/student-api/internal/config"
+)
+
+// stubStorage satisfies Storage for tests that never call it.
+type stubStorage struct {
+	Storage
+}
+
+func TestRegistry(t *testing.T) {
+	opened := &stubStorage{}
+	errDown := errors.New("database is down")
+	Register("test-ok", func(cfg *config.Config) (Storage, error) { return opened, nil })
+	Register("test-failing", func(cfg *config.Config) (Storage, error) { return nil, errDown })
+	t.Cleanup(func() {
+		driversMu.Lock()
+		delete(drivers, "test-ok")
+		delete(drivers, "test-failing")
+		driversMu.Unlock()
+	})
+
+	names := Drivers()
+	if !slices.IsSorted(names) || !slices.Contains(names, "test-ok") || !slices.Contains(names, "test-failing") {
+		t.Errorf("Drivers = %v", names)
+	}
+
+	open := func(driver string) (Storage, error) {
+		return Open(&config.Config{Storage: config.Storage{Driver: driver}})
+	}
+	if s, err := open("test-ok"); err != nil || s != opened {
+		t.Errorf("Open(test-ok) = %v, %v", s, err)
+	}
+	if _, err := open("test-failing"); !errors.Is(err, errDown) || !strings.Contains(err.Error(), "test-failing") {
+		t.Errorf("Open(test-failing) = %v, want the factory's error naming the driver", err)
+	}
+	for _, driver := range []string{"", "oracle"} {
+		_, err := open(driver)
+		if err == nil || !strings.Contains(err.Error(), "test-failing, test-ok") {
+			t.Errorf("Open(%q) = %v, want an error listing the drivers", driver, err)
+		}
+	}
+}
+
+func TestRegisterPanics(t *testing.T) {
+	Register("test-once", func(cfg *config.Config) (Storage, error) { return nil, nil })
+	t.Cleanup(func() {
+		driversMu.Lock()
+		delete(drivers, "test-once")
+		driversMu.Unlock()
+	})
+	for _, tt := range []struct {
+		name    string
+		factory Factory
+	}{
+		{"test-once", func(cfg *config.Config) (Storage, error) { return nil, nil }},
+		{"test-nil", nil},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("Register(%q) did not panic", tt.name)
+				}
+			}()
+			Register(tt.name, tt.factory)
+		}()
+	}
+}
@@ -0,0 +1,183 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/utils/backoff"
+)
+
+// TransientChecker is implemented by backends that can tell errors worth
+// retrying, such as deadlocks, from permanent ones.
+type TransientChecker interface {
+	IsTransient(err error) bool
+}
+
+// Unwrapper is implemented by storages that decorate another one.
+type Unwrapper interface {
+	Unwrap() Storage
+}
+
+// As finds the first storage in the chain of decorators around s that
+// implements T, the way errors.As searches wrapped errors.
+func As[T any](s Storage) (T, bool) {
+	for {
+		if t, ok := s.(T); ok {
+			return t, true
+		}
+		u, ok := s.(Unwrapper)
+		if !ok {
+			var zero T
+			return zero, false
+		}
+		s = u.Unwrap()
+	}
+}
+
+// IsConnectionError reports whether err means the connection to the
+// database broke or could not be made, which is worth retrying whatever
+// the backend.
+func IsConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// WithRetry repeats the operations of s that are safe to run twice when
+// they fail with an error s considers transient. Storages that cannot
+// classify their errors are returned unchanged.
+func WithRetry(s Storage, cfg config.Retry) Storage {
+	checker, ok := s.(TransientChecker)
+	if !ok || cfg.Attempts <= 1 {
+		return s
+	}
+	return &retrying{Storage: s, checker: checker, backoff: backoff.New(cfg), attempts: cfg.Attempts, ctx: context.Background()}
+}
+
+// retrying only overrides reads. Every write also records an audit entry,
+// a revision and an event, and a write whose COMMIT failed on a broken
+// connection may have committed all of them, so it is never repeated.
+type retrying struct {
+	Storage
+	checker  TransientChecker
+	backoff  backoff.Backoff
+	attempts int
+	// ctx ends the waits between attempts once the request is gone.
+	ctx context.Context
+}
+
+func (r *retrying) Unwrap() Storage {
+	return r.Storage
+}
+
+// WithContext drops the retries inside a transaction, which an error such
+// as a deadlock aborts as a whole.
+func (r *retrying) WithContext(ctx context.Context) Storage {
+	if InTransaction(ctx) {
+		return r.Storage.WithContext(ctx)
+	}
+	scoped := *r
+	scoped.Storage = r.Storage.WithContext(ctx)
+	scoped.ctx = ctx
+	return &scoped
+}
+
+func retry[T any](r *retrying, op func() (T, error)) (T, error) {
+	var result T
+	err := backoff.Retry(r.ctx, r.backoff, r.attempts, r.checker.IsTransient, func() error {
+		var err error
+		result, err = op()
+		return err
+	})
+	return result, err
+}
+
+func (r *retrying) StudentList() ([]models.Student, error) {
+	return retry(r, r.Storage.StudentList)
+}
+
+func (r *retrying) GetStudentByID(id int64) (models.Student, error) {
+	return retry(r, func() (models.Student, error) { return r.Storage.GetStudentByID(id) })
+}
+
+func (r *retrying) StudentHistory(studentID int64) ([]models.AuditEntry, error) {
+	return retry(r, func() ([]models.AuditEntry, error) { return r.Storage.StudentHistory(studentID) })
+}
+
+func (r *retrying) StudentRevisions(studentID int64) ([]models.StudentRevision, error) {
+	return retry(r, func() ([]models.StudentRevision, error) { return r.Storage.StudentRevisions(studentID) })
+}
+
+func (r *retrying) StudentAsOf(studentID int64, at time.Time) (models.Student, error) {
+	return retry(r, func() (models.Student, error) { return r.Storage.StudentAsOf(studentID, at) })
+}
+
+func (r *retrying) GetFileByID(id int64) (models.File, error) {
+	return retry(r, func() (models.File, error) { return r.Storage.GetFileByID(id) })
+}
+
+func (r *retrying) StudentFileUsage(studentID int64) (models.FileUsage, error) {
+	return retry(r, func() (models.FileUsage, error) { return r.Storage.StudentFileUsage(studentID) })
+}
+
+func (r *retrying) TotalFileUsage() (models.FileUsage, error) {
+	return retry(r, r.Storage.TotalFileUsage)
+}
+
+func (r *retrying) StudentUploadUsage(studentID int64) (models.FileUsage, error) {
+	return retry(r, func() (models.FileUsage, error) { return r.Storage.StudentUploadUsage(studentID) })
+}
+
+func (r *retrying) TotalUploadUsage() (models.FileUsage, error) {
+	return retry(r, r.Storage.TotalUploadUsage)
+}
+
+func (r *retrying) GetStudentPhoto(studentID int64) (models.Photo, error) {
+	return retry(r, func() (models.Photo, error) { return r.Storage.GetStudentPhoto(studentID) })
+}
+
+func (r *retrying) GetUpload(id string) (models.Upload, error) {
+	return retry(r, func() (models.Upload, error) { return r.Storage.GetUpload(id) })
+}
+
+func (r *retrying) ExpiredUploads(before time.Time) ([]models.Upload, error) {
+	return retry(r, func() ([]models.Upload, error) { return r.Storage.ExpiredUploads(before) })
+}
+
+func (r *retrying) OrphanedFiles() ([]models.File, error) {
+	return retry(r, r.Storage.OrphanedFiles)
+}
+
+func (r *retrying) UnreferencedBlobs() ([]models.Blob, error) {
+	return retry(r, r.Storage.UnreferencedBlobs)
+}
+
+func (r *retrying) Deliveries(status string, limit int) ([]models.Delivery, error) {
+	return retry(r, func() ([]models.Delivery, error) { return r.Storage.Deliveries(status, limit) })
+}
+
+func (r *retrying) Subscriptions() ([]models.Subscription, error) {
+	return retry(r, func() ([]models.Subscription, error) { return r.Storage.Subscriptions() })
+}
+
+func (r *retrying) EventsAfter(id uint64, limit int) ([]models.Event, error) {
+	return retry(r, func() ([]models.Event, error) { return r.Storage.EventsAfter(id, limit) })
+}
+
+func (r *retrying) LastEventID() (uint64, error) {
+	return retry(r, func() (uint64, error) { return r.Storage.LastEventID() })
+}
+
+func (r *retrying) HasBlob(sha256 string) (bool, error) {
+	return retry(r, func() (bool, error) { return r.Storage.HasBlob(sha256) })
+}
@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+)
+
+var errDeadlock = errors.New("deadlock")
+
+// flaky fails every call with errDeadlock until failures run out.
+type flaky struct {
+	stubStorage
+	failures int
+	calls    int
+}
+
+func (f *flaky) IsTransient(err error) bool {
+	return errors.Is(err, errDeadlock)
+}
+
+func (f *flaky) WithContext(ctx context.Context) Storage {
+	return f
+}
+
+func (f *flaky) fail() error {
+	f.calls++
+	if f.failures > 0 {
+		f.failures--
+		return errDeadlock
+	}
+	return nil
+}
+
+func (f *flaky) GetStudentByID(id int64) (models.Student, error) {
+	return models.Student{Id: uint64(id)}, f.fail()
+}
+
+func (f *flaky) CreateStudent(name string, email string, age int) (int64, error) {
+	return 1, f.fail()
+}
+
+func (f *flaky) UpdateStudentByID(name string, email string, age int, id int64) (string, error) {
+	return "updated", f.fail()
+}
+
+var testRetry = config.Retry{InitialInterval: time.Millisecond, MaxInterval: time.Millisecond, Attempts: 3}
+
+func TestWithRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		failures int
+		calls    int
+		ok       bool
+	}{
+		{"no failure", 0, 1, true},
+		{"recovers", 2, 3, true},
+		{"gives up", 3, 3, false},
+	}
+	for _, tt := range tests {
+		backend := &flaky{failures: tt.failures}
+		_, err := WithRetry(backend, testRetry).GetStudentByID(7)
+		if (err == nil) != tt.ok || backend.calls != tt.calls {
+			t.Errorf("%s: GetStudentByID = %v after %d calls, want ok %v after %d", tt.name, err, backend.calls, tt.ok, tt.calls)
+		}
+	}
+
+	// A write that failed may still have committed, along with its audit
+	// entry, revision and event
+	backend := &flaky{failures: 1}
+	if _, err := WithRetry(backend, testRetry).CreateStudent("Asha", "asha@example.com", 20); !errors.Is(err, errDeadlock) || backend.calls != 1 {
+		t.Errorf("CreateStudent = %v after %d calls, want no retry", err, backend.calls)
+	}
+	backend = &flaky{failures: 1}
+	if _, err := WithRetry(backend, testRetry).UpdateStudentByID("Asha", "asha@example.com", 21, 1); !errors.Is(err, errDeadlock) || backend.calls != 1 {
+		t.Errorf("UpdateStudentByID = %v after %d calls, want no retry", err, backend.calls)
+	}
+
+	// A deadlock aborts the whole transaction, so nothing inside it is retried
+	backend = &flaky{failures: 1}
+	ctx := context.WithValue(context.Background(), txKey{}, &sharedTx{})
+	if _, err := WithRetry(backend, testRetry).WithContext(ctx).GetStudentByID(7); !errors.Is(err, errDeadlock) || backend.calls != 1 {
+		t.Errorf("GetStudentByID in a transaction = %v after %d calls, want no retry", err, backend.calls)
+	}
+
+	// A request that has gone away stops waiting for the next attempt
+	backend = &flaky{failures: 3}
+	slow := testRetry
+	slow.InitialInterval, slow.MaxInterval = time.Hour, time.Hour
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	if _, err := WithRetry(backend, slow).WithContext(cancelled).GetStudentByID(7); !errors.Is(err, errDeadlock) || backend.calls != 1 || time.Since(start) > time.Second {
+		t.Errorf("GetStudentByID of a cancelled request = %v after %d calls and %v", err, backend.calls, time.Since(start))
+	}
+
+	// Decorating requires a backend that classifies its errors and more than one attempt
+	if _, ok := WithRetry(&stubStorage{}, testRetry).(*retrying); ok {
+		t.Error("WithRetry decorated a backend without IsTransient")
+	}
+	single := testRetry
+	single.Attempts = 1
+	if _, ok := WithRetry(&flaky{}, single).(*retrying); ok {
+		t.Error("WithRetry decorated a backend for a single attempt")
+	}
+	if s, ok := As[*flaky](WithRetry(backend, testRetry)); !ok || s != backend {
+		t.Errorf("As found %v, %v", s, ok)
+	}
+}
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestIsConnectionError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{driver.ErrBadConn, true},
+		{io.ErrUnexpectedEOF, true},
+		{fmt.Errorf("read: %w", syscall.ECONNRESET), true},
+		{&net.OpError{Op: "dial", Err: syscall.ECONNREFUSED}, true},
+		{&net.OpError{Op: "read", Err: timeoutError{}}, true},
+		{errDeadlock, false},
+		{context.Canceled, false},
+	}
+	for _, tt := range tests {
+		if got := IsConnectionError(tt.err); got != tt.want {
+			t.Errorf("IsConnectionError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package sqlite
+
+import (
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// lockStudent reads the fields of a student that the audit log tracks.
+func lockStudent(tx *storage.Tx, id int64) (models.Student, error) {
+	var student models.Student
+	err := tx.QueryRow("SELECT id,name,email,age FROM students WHERE id = ?", id).Scan(&student.Id, &student.Name, &student.Email, &student.Age)
+	return student, err
+}
+
+// audit records entry as part of tx, so a change and its audit entry are
+// committed or rolled back together.
+func (s *Sqlite) audit(tx *storage.Tx, entry models.AuditEntry) error {
+	_, err := tx.Exec("INSERT INTO audit_log (actor,action,entity_type,entity_id,changes,request_id) VALUES (?,?,?,?,?,?)", entry.Actor, entry.Action, entry.EntityType, entry.EntityId, string(entry.Changes), entry.RequestId)
+	return err
+}
+
+func (s *Sqlite) StudentHistory(studentID int64) ([]models.AuditEntry, error) {
+	var list []models.AuditEntry
+	stmt, err := s.conn().Prepare("SELECT id,actor,action,entity_type,entity_id,changes,request_id,created_at FROM audit_log WHERE entity_type = ? AND entity_id = ? ORDER BY id")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(storage.EntityStudent, studentID)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var entry models.AuditEntry
+		var changes []byte
+		err := rows.Scan(&entry.Id, &entry.Actor, &entry.Action, &entry.EntityType, &entry.EntityId, &changes, &entry.RequestId, &entry.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		entry.Changes = changes
+		list = append(list, entry)
+	}
+	return list, rows.Err()
+}
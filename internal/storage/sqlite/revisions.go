@@ -0,0 +1,122 @@
+package sqlite
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// revise records student as its next revision in tx and returns the
+// revision number. Callers hold the student's row, so revisions of one
+// student are numbered one at a time.
+func (s *Sqlite) revise(tx *storage.Tx, student models.Student, deleted bool) (int, error) {
+	var revision int
+	err := tx.QueryRow("SELECT COALESCE(MAX(revision),0)+1 FROM student_revisions WHERE student_id = ?", student.Id).Scan(&revision)
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec("INSERT INTO student_revisions (student_id,revision,name,email,age,deleted,actor) VALUES (?,?,?,?,?,?,?)", student.Id, revision, student.Name, student.Email, student.Age, deleted, storage.ActorFromContext(s.ctx))
+	return revision, err
+}
+
+func studentRevision(tx *storage.Tx, studentID int64, revision int) (models.StudentRevision, error) {
+	var rev models.StudentRevision
+	err := tx.QueryRow("SELECT student_id,revision,name,email,age,deleted,actor,created_at FROM student_revisions WHERE student_id = ? AND revision = ?", studentID, revision).Scan(&rev.StudentId, &rev.Revision, &rev.Name, &rev.Email, &rev.Age, &rev.Deleted, &rev.Actor, &rev.CreatedAt)
+	return rev, err
+}
+
+func (s *Sqlite) StudentRevisions(studentID int64) ([]models.StudentRevision, error) {
+	var list []models.StudentRevision
+	stmt, err := s.conn().Prepare("SELECT student_id,revision,name,email,age,deleted,actor,created_at FROM student_revisions WHERE student_id = ? ORDER BY revision")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(studentID)
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var rev models.StudentRevision
+		err := rows.Scan(&rev.StudentId, &rev.Revision, &rev.Name, &rev.Email, &rev.Age, &rev.Deleted, &rev.Actor, &rev.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, rev)
+	}
+	return list, rows.Err()
+}
+
+func (s *Sqlite) StudentAsOf(studentID int64, at time.Time) (models.Student, error) {
+	var rev models.StudentRevision
+	var created time.Time
+	err := s.conn().QueryRow("SELECT r.student_id,r.name,r.email,r.age,r.deleted,r.created_at,f.created_at FROM student_revisions r JOIN student_revisions f ON f.student_id = r.student_id AND f.revision = 1 WHERE r.student_id = ? AND r.created_at <= ? ORDER BY r.revision DESC LIMIT 1", studentID, at.UTC()).Scan(&rev.StudentId, &rev.Name, &rev.Email, &rev.Age, &rev.Deleted, &rev.CreatedAt, &created)
+	if err != nil {
+		return models.Student{}, err
+	}
+	if rev.Deleted {
+		return models.Student{}, sql.ErrNoRows
+	}
+	student := rev.Student()
+	student.CreatedAt = created
+	return student, nil
+}
+
+func (s *Sqlite) RevertStudent(studentID int64, revision int) (models.StudentRevision, error) {
+	tx, err := storage.Begin(s.ctx, s.Db)
+	if err != nil {
+		return models.StudentRevision{}, err
+	}
+	defer tx.Rollback()
+
+	target, err := studentRevision(tx, studentID, revision)
+	if err != nil {
+		return models.StudentRevision{}, err
+	}
+	if target.Deleted {
+		return models.StudentRevision{}, storage.ErrDeletedRevision
+	}
+	after := target.Student()
+	var changes json.RawMessage
+	event := storage.EventStudentUpdated
+	before, err := lockStudent(tx, studentID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		// Deleted since the revision, so it comes back under its old id
+		if _, err := tx.Exec("INSERT INTO students (id,name,email,age,updated_at) VALUES (?,?,?,?,CURRENT_TIMESTAMP)", studentID, after.Name, after.Email, after.Age); err != nil {
+			return models.StudentRevision{}, err
+		}
+		changes = storage.StudentChanges(nil, &after)
+		event = storage.EventStudentCreated
+	case err != nil:
+		return models.StudentRevision{}, err
+	default:
+		if _, err := tx.Exec("UPDATE students SET name = ?, email = ?, age = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", after.Name, after.Email, after.Age, studentID); err != nil {
+			return models.StudentRevision{}, err
+		}
+		changes = storage.StudentChanges(&before, &after)
+	}
+	if err := s.audit(tx, storage.NewAuditEntry(s.ctx, storage.AuditRevert, storage.EntityStudent, studentID, changes)); err != nil {
+		return models.StudentRevision{}, err
+	}
+	current, err := s.revise(tx, after, false)
+	if err != nil {
+		return models.StudentRevision{}, err
+	}
+	if err := s.publish(tx, event, after); err != nil {
+		return models.StudentRevision{}, err
+	}
+	rev, err := studentRevision(tx, studentID, current)
+	if err != nil {
+		return models.StudentRevision{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.StudentRevision{}, err
+	}
+	return rev, nil
+}
@@ -0,0 +1,119 @@
+package sqlite
+
+// schema creates the tables this backend needs; every statement must be
+// safe to run against an existing database.
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS students (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT,
+		email TEXT,
+		age INT,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	// Databases created before the default above was spelled correctly
+	// stored the literal text instead of a time.
+	`UPDATE students SET updated_at = created_at WHERE updated_at = 'CURRECT_TIMESTAMP'`,
+	`CREATE TABLE IF NOT EXISTS blobs (
+		sha256 TEXT PRIMARY KEY,
+		size INTEGER NOT NULL,
+		path TEXT NOT NULL,
+		ref_count INTEGER NOT NULL DEFAULT 0,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS files (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		student_id INTEGER,
+		name TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size INTEGER NOT NULL,
+		sha256 TEXT NOT NULL,
+		path TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS tus_uploads (
+		id TEXT PRIMARY KEY,
+		student_id INTEGER,
+		name TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		length INTEGER NOT NULL,
+		upload_offset INTEGER NOT NULL DEFAULT 0,
+		path TEXT NOT NULL,
+		file_id INTEGER,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS student_photos (
+		student_id INTEGER PRIMARY KEY,
+		original_file_id INTEGER NOT NULL,
+		medium_file_id INTEGER NOT NULL,
+		thumb_file_id INTEGER NOT NULL,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS audit_log (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		actor TEXT NOT NULL,
+		action TEXT NOT NULL,
+		entity_type TEXT NOT NULL,
+		entity_id INTEGER NOT NULL,
+		changes TEXT NOT NULL,
+		request_id TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS audit_log_entity ON audit_log (entity_type, entity_id)`,
+	`CREATE TABLE IF NOT EXISTS student_revisions (
+		student_id INTEGER NOT NULL,
+		revision INTEGER NOT NULL,
+		name TEXT,
+		email TEXT,
+		age INT,
+		deleted BOOLEAN NOT NULL DEFAULT 0,
+		actor TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (student_id, revision)
+	)`,
+	// Students from before revisions were kept start with their current
+	// values, as of their last update.
+	`INSERT INTO student_revisions (student_id,revision,name,email,age,deleted,actor,created_at)
+		SELECT id,1,name,email,age,0,'system',COALESCE(updated_at,CURRENT_TIMESTAMP) FROM students
+		WHERE id NOT IN (SELECT student_id FROM student_revisions)`,
+	`CREATE TABLE IF NOT EXISTS outbox (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_type TEXT NOT NULL,
+		student_id INTEGER NOT NULL,
+		payload TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		dispatched_at TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS outbox_pending ON outbox (dispatched_at, id)`,
+	`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT NOT NULL,
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		event_id INTEGER NOT NULL,
+		subscription_id INTEGER NOT NULL,
+		status TEXT NOT NULL,
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL,
+		last_error TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+		updated_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+	)`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at)`,
+	`CREATE TABLE IF NOT EXISTS idempotency_keys (
+		idempotency_key TEXT PRIMARY KEY,
+		fingerprint TEXT NOT NULL,
+		status_code INTEGER NOT NULL DEFAULT 0,
+		header TEXT,
+		body BLOB,
+		created_at TIMESTAMP NOT NULL,
+		expires_at TIMESTAMP NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS idempotency_keys_expires ON idempotency_keys (expires_at)`,
+}
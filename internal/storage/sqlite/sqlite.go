@@ -1,45 +1,111 @@
 package sqlite
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/gabriel-vasile/mimetype"
 	"github.com/surajNirala/student-api/internal/config"
 	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/filestore"
+	"github.com/surajNirala/student-api/internal/utils/digest"
 
-	_ "github.com/mattn/go-sqlite3"
+	"github.com/mattn/go-sqlite3"
 )
 
 type Sqlite struct {
-	Db *sql.DB
+	Db  *sql.DB
+	ctx context.Context
+}
+
+func init() {
+	storage.Register("sqlite", func(cfg *config.Config) (storage.Storage, error) {
+		if cfg.StoragePath == "" {
+			return nil, errors.New("storage_path is not set")
+		}
+		return New(cfg)
+	})
 }
 
 func New(cfg *config.Config) (*Sqlite, error) {
-	db, err := sql.Open("sqlite3", cfg.StoragePath)
+	db, err := sql.Open("sqlite3", dsn(cfg))
 	if err != nil {
 		return nil, err
 	}
-	createQuery := `CREATE TABLE IF NOT EXISTS students (
-						id INTEGER PRIMARY KEY AUTOINCREMENT,
-						name TEXT,
-						email TEXT,
-						age INT,
-						created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
-						updated_at TIMESTAMP DEFAULT CURRECT_TIMESTAMP
-					)`
-	_, err = db.Exec(createQuery)
-	if err != nil {
-		return nil, err
+	storage.ConfigurePool(db, cfg.Storage.Pool)
+	for _, query := range schema {
+		if _, err := db.Exec(query); err != nil {
+			return nil, err
+		}
 	}
-	return &Sqlite{Db: db}, nil
+	return &Sqlite{Db: db, ctx: context.Background()}, nil
+}
+
+// dsn adds the journal mode and busy timeout to the configured path,
+// keeping any options the path already carries. Transactions take the
+// write lock when they begin, so one that reads before it writes waits
+// for the busy timeout instead of failing to upgrade its lock.
+func dsn(cfg *config.Config) string {
+	params := url.Values{}
+	params.Set("_txlock", "immediate")
+	if cfg.Storage.SQLite.JournalMode != "" {
+		params.Set("_journal_mode", cfg.Storage.SQLite.JournalMode)
+	}
+	if cfg.Storage.SQLite.BusyTimeout > 0 {
+		params.Set("_busy_timeout", strconv.FormatInt(cfg.Storage.SQLite.BusyTimeout.Milliseconds(), 10))
+	}
+	separator := "?"
+	if strings.Contains(cfg.StoragePath, "?") {
+		separator = "&"
+	}
+	return cfg.StoragePath + separator + params.Encode()
+}
+
+// IsTransient reports lock contention that outlasted the busy timeout.
+func (s *Sqlite) IsTransient(err error) bool {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code == sqlite3.ErrBusy || sqliteErr.Code == sqlite3.ErrLocked
+	}
+	return false
+}
+
+func (s *Sqlite) Ping(ctx context.Context) error {
+	return s.Db.PingContext(ctx)
+}
+
+func (s *Sqlite) PoolStats() sql.DBStats {
+	return s.Db.Stats()
+}
+
+func (s *Sqlite) WithContext(ctx context.Context) storage.Storage {
+	scoped := *s
+	scoped.ctx = ctx
+	return &scoped
+}
+
+func (s *Sqlite) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	return storage.RunInTx(ctx, s.Db, fn)
+}
+
+// conn is where statements outside a transaction of their own run.
+func (s *Sqlite) conn() storage.Querier {
+	return storage.Conn(s.ctx, s.Db)
 }
 
 func (s *Sqlite) StudentList() ([]models.Student, error) {
 	var list []models.Student
-	stmt, err := s.Db.Prepare("SELECT id,name,email,age,created_at,updated_at FROM students ORDER BY id DESC")
+	stmt, err := s.conn().Prepare("SELECT s.id,s.name,s.email,s.age,s.created_at,s.updated_at,p.student_id FROM students s LEFT JOIN student_photos p ON p.student_id = s.id ORDER BY s.id DESC")
 	if err != nil {
 		return list, err
 	}
@@ -50,10 +116,14 @@ func (s *Sqlite) StudentList() ([]models.Student, error) {
 	}
 	for rows.Next() {
 		var student models.Student
-		err := rows.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.CreatedAt, &student.UpdatedAt)
+		var photo sql.NullInt64
+		err := rows.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.CreatedAt, &student.UpdatedAt, &photo)
 		if err != nil {
 			return nil, err
 		}
+		if photo.Valid {
+			student.PhotoURL = models.StudentPhotoURL(student.Id)
+		}
 
 		list = append(list, student)
 	}
@@ -61,120 +131,593 @@ func (s *Sqlite) StudentList() ([]models.Student, error) {
 }
 
 func (s *Sqlite) CreateStudent(name string, email string, age int) (int64, error) {
-	stmt, err := s.Db.Prepare("INSERT INTO students (name,email,age) VALUES (?,?,?)")
+	tx, err := storage.Begin(s.ctx, s.Db)
 	if err != nil {
 		return 0, err
 	}
-	defer stmt.Close()
-	result, err := stmt.Exec(name, email, age)
+	defer tx.Rollback()
+
+	result, err := tx.Exec("INSERT INTO students (name,email,age,updated_at) VALUES (?,?,?,CURRENT_TIMESTAMP)", name, email, age)
 	if err != nil {
 		return 0, err
 	}
 	lastID, err := result.LastInsertId()
 	if err != nil {
-		return 0, nil
+		return 0, err
+	}
+	changes := storage.StudentChanges(nil, &models.Student{Name: name, Email: email, Age: age})
+	if err := s.audit(tx, storage.NewAuditEntry(s.ctx, storage.AuditCreate, storage.EntityStudent, lastID, changes)); err != nil {
+		return 0, err
+	}
+	created := models.Student{Id: uint64(lastID), Name: name, Email: email, Age: age}
+	if _, err := s.revise(tx, created, false); err != nil {
+		return 0, err
+	}
+	if err := s.publish(tx, storage.EventStudentCreated, created); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
 	}
 	return lastID, nil
 }
 
 func (s *Sqlite) GetStudentByID(id int64) (models.Student, error) {
 	var student models.Student
-	stmt, err := s.Db.Prepare("SELECT id,name,email,age,created_at,updated_at FROM students WHERE id = ?")
+	stmt, err := s.conn().Prepare("SELECT s.id,s.name,s.email,s.age,s.created_at,s.updated_at,p.student_id FROM students s LEFT JOIN student_photos p ON p.student_id = s.id WHERE s.id = ?")
 	if err != nil {
 		return student, err
 	}
 	defer stmt.Close()
 	row := stmt.QueryRow(id)
-	err = row.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.CreatedAt, &student.UpdatedAt)
+	var photo sql.NullInt64
+	err = row.Scan(&student.Id, &student.Name, &student.Email, &student.Age, &student.CreatedAt, &student.UpdatedAt, &photo)
 	if err != nil {
 		return student, err
 	}
+	if photo.Valid {
+		student.PhotoURL = models.StudentPhotoURL(student.Id)
+	}
 	return student, nil
 }
 
 func (s *Sqlite) DeleteStudentByID(id int64) (string, error) {
-	msg := ""
-	stmt, err := s.Db.Prepare("DELETE FROM students WHERE id = ?")
+	tx, err := storage.Begin(s.ctx, s.Db)
 	if err != nil {
-		return msg, err
+		return "", err
 	}
-	defer stmt.Close()
-	result, err := stmt.Exec(id)
-	if err != nil {
-		return msg, err
+	defer tx.Rollback()
+
+	before, err := lockStudent(tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("no student found with id %d: %w", id, sql.ErrNoRows)
 	}
-	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return msg, err
+		return "", err
 	}
-	if rowsAffected == 0 {
-		return msg, fmt.Errorf("no student found with id %d", id)
+	if _, err := tx.Exec("DELETE FROM students WHERE id = ?", id); err != nil {
+		return "", err
+	}
+	// A reverted student must not come back with a photo whose files are
+	// gone; they are orphaned now and left to garbage collection.
+	if _, err := tx.Exec("DELETE FROM student_photos WHERE student_id = ?", id); err != nil {
+		return "", err
+	}
+	changes := storage.StudentChanges(&before, nil)
+	if err := s.audit(tx, storage.NewAuditEntry(s.ctx, storage.AuditDelete, storage.EntityStudent, id, changes)); err != nil {
+		return "", err
+	}
+	if _, err := s.revise(tx, before, true); err != nil {
+		return "", err
+	}
+	if err := s.publish(tx, storage.EventStudentDeleted, before); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
 	}
 	return fmt.Sprintf("student with id %d deleted successfully", id), nil
 }
 
 func (s *Sqlite) UpdateStudentByID(name string, email string, age int, id int64) (string, error) {
-	stmt, err := s.Db.Prepare("UPDATE students SET name = ?, email = ?, age = ? WHERE id = ?")
+	tx, err := storage.Begin(s.ctx, s.Db)
 	if err != nil {
 		return "", err
 	}
-	defer stmt.Close()
+	defer tx.Rollback()
 
-	result, err := stmt.Exec(name, email, age, id)
+	before, err := lockStudent(tx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("no student found with id %d: %w", id, sql.ErrNoRows)
+	}
 	if err != nil {
 		return "", err
 	}
+	if _, err := tx.Exec("UPDATE students SET name = ?, email = ?, age = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", name, email, age, id); err != nil {
+		return "", err
+	}
+	after := before
+	after.Name, after.Email, after.Age = name, email, age
+	changes := storage.StudentChanges(&before, &after)
+	if err := s.audit(tx, storage.NewAuditEntry(s.ctx, storage.AuditUpdate, storage.EntityStudent, id, changes)); err != nil {
+		return "", err
+	}
+	if _, err := s.revise(tx, after, false); err != nil {
+		return "", err
+	}
+	if err := s.publish(tx, storage.EventStudentUpdated, after); err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("student with id %d updated successfully", id), nil
+}
 
-	rowsAffected, err := result.RowsAffected()
+func (s *Sqlite) StudentFileUpload10MB(studentID int64, fileName string, contentType string, fileData []byte) (models.File, error) {
+	return s.StudentLargeFileUpload(studentID, fileName, contentType, bytes.NewReader(fileData))
+}
+
+func (s *Sqlite) StudentLargeFileUpload(studentID int64, fileName string, contentType string, fileReader io.Reader) (models.File, error) {
+	blob, err := filestore.Save(fileReader)
 	if err != nil {
-		return "", err
+		return models.File{}, err
+	}
+	file, err := s.addFile(studentID, fileName, contentType, blob)
+	if err != nil {
+		filestore.Remove(blob.Path)
 	}
+	return file, err
+}
 
-	if rowsAffected == 0 {
-		return "", fmt.Errorf("no student found with id %d", id)
+// addFile commits blob under its digest, sharing the stored copy when the
+// same content was uploaded before, and records a file pointing at it.
+func (s *Sqlite) addFile(studentID int64, fileName string, contentType string, blob filestore.Blob) (models.File, error) {
+	// The reference is recorded before the content is committed to disk,
+	// so a delete waiting on the lock sees it and leaves the copy alone
+	unlock := filestore.LockBlob(blob.SHA256)
+	defer unlock()
+	blobPath := filestore.BlobPath(blob.SHA256)
+	tx, err := storage.Begin(s.ctx, s.Db)
+	if err != nil {
+		return models.File{}, err
 	}
+	defer tx.Rollback()
 
-	return fmt.Sprintf("student with id %d updated successfully", id), nil
+	_, err = tx.Exec("INSERT INTO blobs (sha256,size,path,ref_count) VALUES (?,?,?,1) ON CONFLICT(sha256) DO UPDATE SET ref_count = ref_count + 1", blob.SHA256, blob.Size, blobPath)
+	if err != nil {
+		return models.File{}, err
+	}
+	owner := sql.NullInt64{Int64: studentID, Valid: studentID != 0}
+	result, err := tx.Exec("INSERT INTO files (student_id,name,content_type,size,sha256,path) VALUES (?,?,?,?,?,?)", owner, fileName, contentType, blob.Size, blob.SHA256, blobPath)
+	if err != nil {
+		return models.File{}, err
+	}
+	lastID, err := result.LastInsertId()
+	if err != nil {
+		return models.File{}, err
+	}
+	if _, err := filestore.Commit(blob); err != nil {
+		return models.File{}, err
+	}
+	if err := tx.Commit(); err != nil {
+		return models.File{}, err
+	}
+	return s.GetFileByID(lastID)
+}
+
+func (s *Sqlite) OpenFile(id int64) (models.File, io.ReadSeekCloser, error) {
+	file, err := s.GetFileByID(id)
+	if err != nil {
+		return file, nil, err
+	}
+	f, err := os.Open(file.Path)
+	if err != nil {
+		return file, nil, err
+	}
+	return file, f, nil
 }
 
-func (s *Sqlite) StudentFileUpload10MB(fileName string, fileData []byte) (string, error) {
-	uploadDir := "uploads"
-	// Ensure the upload directory exists
-	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
+func (s *Sqlite) DeleteFileByID(id int64) (string, error) {
+	file, err := s.GetFileByID(id)
+	if err != nil {
 		return "", err
 	}
-	filePath := filepath.Join(uploadDir, fileName)
-	file, err := os.Create(filePath)
+	unlock := filestore.LockBlob(file.SHA256)
+	defer unlock()
+	tx, err := storage.Begin(s.ctx, s.Db)
 	if err != nil {
 		return "", err
 	}
-	defer file.Close()
+	defer tx.Rollback()
 
-	_, err = file.Write(fileData)
+	deleted, err := tx.Exec("DELETE FROM files WHERE id = ?", id)
+	if err != nil {
+		return "", err
+	}
+	n, err := deleted.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	// A concurrent delete of the same file got here first
+	if n == 0 {
+		return "", sql.ErrNoRows
+	}
+	if _, err := tx.Exec("UPDATE blobs SET ref_count = ref_count - 1 WHERE sha256 = ?", file.SHA256); err != nil {
+		return "", err
+	}
+	result, err := tx.Exec("DELETE FROM blobs WHERE sha256 = ? AND ref_count <= 0", file.SHA256)
 	if err != nil {
 		return "", err
 	}
+	unreferenced, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	// Other files may still share the content; only the last one removes it
+	if unreferenced > 0 {
+		if err := storage.RemoveBlob(s.ctx, s, file.SHA256, file.Path); err != nil {
+			return "", err
+		}
+	}
+	return fmt.Sprintf("file with id %d deleted successfully", id), nil
+}
 
-	return fmt.Sprintf("File %s uploaded successfully", fileName), nil
+func (s *Sqlite) GetFileByID(id int64) (models.File, error) {
+	var file models.File
+	var owner sql.NullInt64
+	stmt, err := s.conn().Prepare("SELECT id,student_id,name,content_type,size,sha256,path,created_at,updated_at FROM files WHERE id = ?")
+	if err != nil {
+		return file, err
+	}
+	defer stmt.Close()
+	row := stmt.QueryRow(id)
+	err = row.Scan(&file.Id, &owner, &file.Name, &file.ContentType, &file.Size, &file.SHA256, &file.Path, &file.CreatedAt, &file.UpdatedAt)
+	if err != nil {
+		return file, err
+	}
+	file.StudentId = owner.Int64
+	return file, nil
 }
 
-func (s *Sqlite) StudentLargeFileUpload(fileName string, fileReader io.Reader) (string, error) {
-	uploadDir := "uploads"
-	// Ensure the upload directory exists
-	if err := os.MkdirAll(uploadDir, os.ModePerm); err != nil {
-		return "", err
+func (s *Sqlite) StudentFileUsage(studentID int64) (models.FileUsage, error) {
+	usage := models.FileUsage{StudentId: studentID}
+	stmt, err := s.conn().Prepare("SELECT COUNT(*),COALESCE(SUM(size),0) FROM files WHERE student_id = ?")
+	if err != nil {
+		return usage, err
 	}
-	filePath := filepath.Join(uploadDir, fileName)
-	dstFile, err := os.Create(filePath)
+	defer stmt.Close()
+	err = stmt.QueryRow(studentID).Scan(&usage.Files, &usage.Bytes)
+	return usage, err
+}
+
+// TotalFileUsage counts every file but measures the bytes actually on
+// disk, where content shared by several files is stored once.
+func (s *Sqlite) TotalFileUsage() (models.FileUsage, error) {
+	var usage models.FileUsage
+	stmt, err := s.conn().Prepare("SELECT (SELECT COUNT(*) FROM files),(SELECT COALESCE(SUM(size),0) FROM blobs)")
 	if err != nil {
-		return "", err
+		return usage, err
 	}
-	defer dstFile.Close()
+	defer stmt.Close()
+	err = stmt.QueryRow().Scan(&usage.Files, &usage.Bytes)
+	return usage, err
+}
 
-	_, err = io.Copy(dstFile, fileReader)
+func (s *Sqlite) StudentUploadUsage(studentID int64) (models.FileUsage, error) {
+	usage := models.FileUsage{StudentId: studentID}
+	stmt, err := s.conn().Prepare("SELECT COUNT(*),COALESCE(SUM(upload_offset),0) FROM tus_uploads WHERE student_id = ? AND upload_offset < length")
 	if err != nil {
-		return "", err
+		return usage, err
 	}
+	defer stmt.Close()
+	err = stmt.QueryRow(studentID).Scan(&usage.Files, &usage.Bytes)
+	return usage, err
+}
 
-	return fmt.Sprintf("Large File %s uploaded successfully", fileName), nil
+func (s *Sqlite) TotalUploadUsage() (models.FileUsage, error) {
+	var usage models.FileUsage
+	stmt, err := s.conn().Prepare("SELECT COUNT(*),COALESCE(SUM(upload_offset),0) FROM tus_uploads WHERE upload_offset < length")
+	if err != nil {
+		return usage, err
+	}
+	defer stmt.Close()
+	err = stmt.QueryRow().Scan(&usage.Files, &usage.Bytes)
+	return usage, err
+}
+
+// SetStudentPhoto points a student at a new set of photo files and
+// releases the files of the photo it replaces.
+func (s *Sqlite) SetStudentPhoto(studentID int64, photo models.Photo) error {
+	tx, err := storage.Begin(s.ctx, s.Db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var previous models.Photo
+	err = tx.QueryRow("SELECT original_file_id,medium_file_id,thumb_file_id FROM student_photos WHERE student_id = ?", studentID).Scan(&previous.OriginalFileId, &previous.MediumFileId, &previous.ThumbFileId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	if _, err := tx.Exec("INSERT INTO student_photos (student_id,original_file_id,medium_file_id,thumb_file_id) VALUES (?,?,?,?) ON CONFLICT(student_id) DO UPDATE SET original_file_id = excluded.original_file_id, medium_file_id = excluded.medium_file_id, thumb_file_id = excluded.thumb_file_id, updated_at = CURRENT_TIMESTAMP", studentID, photo.OriginalFileId, photo.MediumFileId, photo.ThumbFileId); err != nil {
+		return err
+	}
+	changes := storage.PhotoChanges(previous.OriginalFileId, photo.OriginalFileId)
+	if err := s.audit(tx, storage.NewAuditEntry(s.ctx, storage.AuditPhoto, storage.EntityStudent, studentID, changes)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if previous.OriginalFileId != 0 {
+		for _, id := range []uint64{previous.OriginalFileId, previous.MediumFileId, previous.ThumbFileId} {
+			if _, err := s.DeleteFileByID(int64(id)); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Sqlite) GetStudentPhoto(studentID int64) (models.Photo, error) {
+	var photo models.Photo
+	stmt, err := s.conn().Prepare("SELECT student_id,original_file_id,medium_file_id,thumb_file_id,updated_at FROM student_photos WHERE student_id = ?")
+	if err != nil {
+		return photo, err
+	}
+	defer stmt.Close()
+	row := stmt.QueryRow(studentID)
+	err = row.Scan(&photo.StudentId, &photo.OriginalFileId, &photo.MediumFileId, &photo.ThumbFileId, &photo.UpdatedAt)
+	if err != nil {
+		return photo, err
+	}
+	return photo, nil
+}
+
+func (s *Sqlite) CreateUpload(studentID int64, fileName string, contentType string, length int64) (models.Upload, error) {
+	id, filePath, err := filestore.CreatePartial()
+	if err != nil {
+		return models.Upload{}, err
+	}
+	stmt, err := s.conn().Prepare("INSERT INTO tus_uploads (id,student_id,name,content_type,length,upload_offset,path) VALUES (?,?,?,?,?,0,?)")
+	if err != nil {
+		filestore.Remove(filePath)
+		return models.Upload{}, err
+	}
+	defer stmt.Close()
+	owner := sql.NullInt64{Int64: studentID, Valid: studentID != 0}
+	if _, err := stmt.Exec(id, owner, fileName, contentType, length, filePath); err != nil {
+		filestore.Remove(filePath)
+		return models.Upload{}, err
+	}
+	return s.GetUpload(id)
+}
+
+func (s *Sqlite) GetUpload(id string) (models.Upload, error) {
+	var upload models.Upload
+	var owner, fileID sql.NullInt64
+	stmt, err := s.conn().Prepare("SELECT id,student_id,name,content_type,length,upload_offset,path,file_id,created_at,updated_at FROM tus_uploads WHERE id = ?")
+	if err != nil {
+		return upload, err
+	}
+	defer stmt.Close()
+	row := stmt.QueryRow(id)
+	err = row.Scan(&upload.Id, &owner, &upload.Name, &upload.ContentType, &upload.Length, &upload.Offset, &upload.Path, &fileID, &upload.CreatedAt, &upload.UpdatedAt)
+	if err != nil {
+		return upload, err
+	}
+	upload.StudentId = owner.Int64
+	upload.FileId = uint64(fileID.Int64)
+	return upload, nil
+}
+
+func (s *Sqlite) WriteUploadChunk(id string, offset int64, data io.Reader) (models.Upload, error) {
+	// A retried PATCH may race the one it retries; only one of them may write at offset
+	unlock := filestore.LockUpload(id)
+	defer unlock()
+	upload, err := s.GetUpload(id)
+	if err != nil {
+		return upload, err
+	}
+	if upload.Offset != offset || upload.Complete() {
+		return upload, storage.ErrOffsetMismatch
+	}
+	// Read one byte past the remaining length to notice oversized chunks
+	written, copyErr := filestore.WritePartial(upload.Path, offset, io.LimitReader(data, upload.Length-offset+1))
+	if copyErr == nil && offset+written > upload.Length {
+		copyErr = storage.ErrChunkTooLarge
+	}
+	if errors.Is(copyErr, storage.ErrChunkTooLarge) || errors.Is(copyErr, digest.ErrMismatch) {
+		// Rejected chunks are discarded as a whole
+		if err := filestore.Truncate(upload.Path, offset); err != nil {
+			return upload, err
+		}
+		return upload, copyErr
+	}
+
+	// Record whatever arrived, even on a dropped connection, so the client can resume from there
+	stmt, err := s.conn().Prepare("UPDATE tus_uploads SET upload_offset = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ? AND upload_offset = ?")
+	if err != nil {
+		return upload, err
+	}
+	defer stmt.Close()
+	result, err := stmt.Exec(offset+written, id, offset)
+	if err != nil {
+		return upload, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return upload, err
+	}
+	if rowsAffected == 0 {
+		return upload, storage.ErrOffsetMismatch
+	}
+	upload.Offset = offset + written
+	if copyErr != nil || !upload.Complete() {
+		return upload, copyErr
+	}
+	return s.completeUpload(upload)
+}
+
+// completeUpload turns a fully received upload into a regular file.
+func (s *Sqlite) completeUpload(upload models.Upload) (models.Upload, error) {
+	// Trust the received bytes over the type declared when the upload was created
+	if mime, err := mimetype.DetectFile(upload.Path); err == nil {
+		upload.ContentType = mime.String()
+	}
+	blob, err := filestore.Hash(upload.Path)
+	if err != nil {
+		return upload, err
+	}
+	file, err := s.addFile(upload.StudentId, upload.Name, upload.ContentType, blob)
+	if err != nil {
+		return upload, err
+	}
+	stmt, err := s.conn().Prepare("UPDATE tus_uploads SET file_id = ?, path = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?")
+	if err != nil {
+		return upload, err
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(file.Id, file.Path, upload.Id); err != nil {
+		return upload, err
+	}
+	upload.FileId = file.Id
+	upload.Path = file.Path
+	return upload, nil
+}
+
+func (s *Sqlite) DeleteUpload(id string) error {
+	upload, err := s.GetUpload(id)
+	if err != nil {
+		return err
+	}
+	stmt, err := s.conn().Prepare("DELETE FROM tus_uploads WHERE id = ?")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+	if _, err := stmt.Exec(id); err != nil {
+		return err
+	}
+	// A completed upload's bytes now belong to its file
+	if upload.Complete() {
+		return nil
+	}
+	return storage.RemoveFile(s.ctx, upload.Path)
+}
+
+func (s *Sqlite) ExpiredUploads(before time.Time) ([]models.Upload, error) {
+	var list []models.Upload
+	stmt, err := s.conn().Prepare("SELECT id,student_id,name,content_type,length,upload_offset,path,file_id,created_at,updated_at FROM tus_uploads WHERE updated_at < ?")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query(before.UTC())
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var upload models.Upload
+		var owner, fileID sql.NullInt64
+		err := rows.Scan(&upload.Id, &owner, &upload.Name, &upload.ContentType, &upload.Length, &upload.Offset, &upload.Path, &fileID, &upload.CreatedAt, &upload.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		upload.StudentId = owner.Int64
+		upload.FileId = uint64(fileID.Int64)
+		list = append(list, upload)
+	}
+	return list, rows.Err()
+}
+
+// OrphanedFiles lists files whose owning student has been deleted.
+func (s *Sqlite) OrphanedFiles() ([]models.File, error) {
+	var list []models.File
+	stmt, err := s.conn().Prepare("SELECT f.id,f.student_id,f.name,f.content_type,f.size,f.sha256,f.path,f.created_at,f.updated_at FROM files f LEFT JOIN students s ON s.id = f.student_id WHERE f.student_id IS NOT NULL AND s.id IS NULL")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query()
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var file models.File
+		var owner sql.NullInt64
+		err := rows.Scan(&file.Id, &owner, &file.Name, &file.ContentType, &file.Size, &file.SHA256, &file.Path, &file.CreatedAt, &file.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		file.StudentId = owner.Int64
+		list = append(list, file)
+	}
+	return list, rows.Err()
+}
+
+// UnreferencedBlobs lists stored content that no file points at, whatever
+// its reference count claims.
+func (s *Sqlite) UnreferencedBlobs() ([]models.Blob, error) {
+	var list []models.Blob
+	stmt, err := s.conn().Prepare("SELECT b.sha256,b.size,b.path,b.ref_count,b.created_at FROM blobs b WHERE NOT EXISTS (SELECT 1 FROM files f WHERE f.sha256 = b.sha256)")
+	if err != nil {
+		return list, err
+	}
+	defer stmt.Close()
+	rows, err := stmt.Query()
+	if err != nil {
+		return list, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var blob models.Blob
+		if err := rows.Scan(&blob.SHA256, &blob.Size, &blob.Path, &blob.RefCount, &blob.CreatedAt); err != nil {
+			return nil, err
+		}
+		list = append(list, blob)
+	}
+	return list, rows.Err()
+}
+
+func (s *Sqlite) HasBlob(sha256 string) (bool, error) {
+	stmt, err := s.conn().Prepare("SELECT COUNT(*) FROM blobs WHERE sha256 = ?")
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	var count int64
+	err = stmt.QueryRow(sha256).Scan(&count)
+	return count > 0, err
+}
+
+// DeleteBlob removes stored content, provided no file has started
+// pointing at it again. It reports whether anything was removed.
+func (s *Sqlite) DeleteBlob(sha256 string) (bool, error) {
+	unlock := filestore.LockBlob(sha256)
+	defer unlock()
+	var blobPath string
+	stmt, err := s.conn().Prepare("SELECT path FROM blobs WHERE sha256 = ?")
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	if err := stmt.QueryRow(sha256).Scan(&blobPath); err != nil {
+		return false, err
+	}
+	result, err := s.conn().Exec("DELETE FROM blobs WHERE sha256 = ? AND NOT EXISTS (SELECT 1 FROM files WHERE sha256 = ?)", sha256, sha256)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil || rowsAffected == 0 {
+		return false, err
+	}
+	return true, storage.RemoveBlob(s.ctx, s, sha256, blobPath)
 }
@@ -0,0 +1,71 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/storagetest"
+)
+
+func TestConformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T) storage.Storage {
+		s, err := New(&config.Config{StoragePath: filepath.Join(t.TempDir(), "test.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.Db.Close() })
+		return s
+	})
+}
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		path     string
+		settings config.SQLite
+		want     string
+	}{
+		{"test.db", config.SQLite{}, "test.db?_txlock=immediate"},
+		{"test.db", config.SQLite{JournalMode: "WAL", BusyTimeout: 5 * time.Second}, "test.db?_busy_timeout=5000&_journal_mode=WAL&_txlock=immediate"},
+		{"test.db?cache=shared", config.SQLite{JournalMode: "WAL"}, "test.db?cache=shared&_journal_mode=WAL&_txlock=immediate"},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{StoragePath: tt.path, Storage: config.Storage{SQLite: tt.settings}}
+		if got := dsn(cfg); got != tt.want {
+			t.Errorf("dsn(%q, %+v) = %q, want %q", tt.path, tt.settings, got, tt.want)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	cfg := &config.Config{
+		StoragePath: filepath.Join(t.TempDir(), "test.db"),
+		Storage: config.Storage{
+			Pool:   config.Pool{MaxOpenConns: 3},
+			SQLite: config.SQLite{JournalMode: "WAL", BusyTimeout: 2 * time.Second},
+		},
+	}
+	s, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Db.Close()
+
+	var mode string
+	var timeout int
+	if err := s.Db.QueryRow("PRAGMA journal_mode").Scan(&mode); err != nil || mode != "wal" {
+		t.Errorf("journal_mode = %q, %v", mode, err)
+	}
+	if err := s.Db.QueryRow("PRAGMA busy_timeout").Scan(&timeout); err != nil || timeout != 2000 {
+		t.Errorf("busy_timeout = %d, %v", timeout, err)
+	}
+	if stats := s.PoolStats(); stats.MaxOpenConnections != 3 {
+		t.Errorf("MaxOpenConnections = %d, want 3", stats.MaxOpenConnections)
+	}
+	if err := s.Ping(context.Background()); err != nil {
+		t.Errorf("Ping = %v", err)
+	}
+}
@@ -0,0 +1,223 @@
+package sqlite
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// publish adds an event about student to the outbox as part of tx.
+func (s *Sqlite) publish(tx *storage.Tx, eventType string, student models.Student) error {
+	_, err := tx.Exec("INSERT INTO outbox (event_type,student_id,payload) VALUES (?,?,?)", eventType, student.Id, string(storage.StudentEventData(student)))
+	return err
+}
+
+func (s *Sqlite) DispatchEvents(limit int) (int, error) {
+	tx, err := storage.Begin(s.ctx, s.Db)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	subs, err := subscriptions(tx)
+	if err != nil {
+		return 0, err
+	}
+	rows, err := tx.Query("SELECT id,event_type FROM outbox WHERE dispatched_at IS NULL ORDER BY id LIMIT ?", limit)
+	if err != nil {
+		return 0, err
+	}
+	var events []models.Event
+	for rows.Next() {
+		var event models.Event
+		if err := rows.Scan(&event.Id, &event.Type); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		events = append(events, event)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	now := time.Now().UTC()
+	for _, event := range events {
+		for _, sub := range subs {
+			if !storage.Subscribed(sub.Events, event.Type) {
+				continue
+			}
+			if _, err := tx.Exec("INSERT INTO webhook_deliveries (event_id,subscription_id,status,next_attempt_at) VALUES (?,?,?,?)", event.Id, sub.Id, models.DeliveryPending, now); err != nil {
+				return 0, err
+			}
+		}
+		if _, err := tx.Exec("UPDATE outbox SET dispatched_at = ? WHERE id = ?", now, event.Id); err != nil {
+			return 0, err
+		}
+	}
+	return len(events), tx.Commit()
+}
+
+func (s *Sqlite) EventsAfter(id uint64, limit int) ([]models.Event, error) {
+	rows, err := s.conn().Query("SELECT id,event_type,student_id,payload,created_at FROM outbox WHERE id > ? ORDER BY id LIMIT ?", id, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.Event
+	for rows.Next() {
+		var event models.Event
+		var data []byte
+		if err := rows.Scan(&event.Id, &event.Type, &event.StudentId, &data, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		event.Data = data
+		list = append(list, event)
+	}
+	return list, rows.Err()
+}
+
+func (s *Sqlite) LastEventID() (uint64, error) {
+	var id uint64
+	err := s.conn().QueryRow("SELECT COALESCE(MAX(id),0) FROM outbox").Scan(&id)
+	return id, err
+}
+
+func (s *Sqlite) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]models.Delivery, error) {
+	tx, err := storage.Begin(s.ctx, s.Db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query("SELECT d.id,d.event_id,d.subscription_id,d.status,d.attempts,d.next_attempt_at,d.last_error,d.created_at,d.updated_at,e.event_type,e.student_id,e.payload,e.created_at,s.url,s.secret FROM webhook_deliveries d JOIN outbox e ON e.id = d.event_id JOIN webhook_subscriptions s ON s.id = d.subscription_id WHERE d.status = ? AND d.next_attempt_at <= ? ORDER BY d.id LIMIT ?", models.DeliveryPending, now.UTC(), limit)
+	if err != nil {
+		return nil, err
+	}
+	list, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	leased := now.Add(lease).UTC()
+	for _, delivery := range list {
+		if _, err := tx.Exec("UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?", leased, delivery.Id); err != nil {
+			return nil, err
+		}
+	}
+	return list, tx.Commit()
+}
+
+func (s *Sqlite) UpdateDelivery(delivery models.Delivery) error {
+	_, err := s.conn().Exec("UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_error = ?, updated_at = CURRENT_TIMESTAMP WHERE id = ?", delivery.Status, delivery.Attempts, delivery.NextAttemptAt.UTC(), delivery.LastError, delivery.Id)
+	return err
+}
+
+func (s *Sqlite) Deliveries(status string, limit int) ([]models.Delivery, error) {
+	rows, err := s.conn().Query("SELECT d.id,d.event_id,d.subscription_id,d.status,d.attempts,d.next_attempt_at,d.last_error,d.created_at,d.updated_at,e.event_type,e.student_id,e.payload,e.created_at,s.url,s.secret FROM webhook_deliveries d JOIN outbox e ON e.id = d.event_id JOIN webhook_subscriptions s ON s.id = d.subscription_id WHERE ? = '' OR d.status = ? ORDER BY d.id DESC LIMIT ?", status, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanDeliveries(rows)
+}
+
+func scanDeliveries(rows *sql.Rows) ([]models.Delivery, error) {
+	defer rows.Close()
+	var list []models.Delivery
+	for rows.Next() {
+		var d models.Delivery
+		var data []byte
+		err := rows.Scan(&d.Id, &d.EventId, &d.SubscriptionId, &d.Status, &d.Attempts, &d.NextAttemptAt, &d.LastError, &d.CreatedAt, &d.UpdatedAt,
+			&d.Event.Type, &d.Event.StudentId, &data, &d.Event.CreatedAt, &d.URL, &d.Secret)
+		if err != nil {
+			return nil, err
+		}
+		d.Event.Id = d.EventId
+		d.Event.Data = data
+		list = append(list, d)
+	}
+	return list, rows.Err()
+}
+
+func (s *Sqlite) ReplayDelivery(id int64) error {
+	result, err := s.conn().Exec("UPDATE webhook_deliveries SET status = ?, attempts = 0, next_attempt_at = ?, last_error = '', updated_at = CURRENT_TIMESTAMP WHERE id = ?", models.DeliveryPending, time.Now().UTC(), id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (s *Sqlite) CreateSubscription(url string, secret string, events []string) (models.Subscription, error) {
+	result, err := s.conn().Exec("INSERT INTO webhook_subscriptions (url,secret,events) VALUES (?,?,?)", url, secret, storage.JoinEvents(events))
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return models.Subscription{}, err
+	}
+	var sub models.Subscription
+	var column string
+	err = s.conn().QueryRow("SELECT id,url,secret,events,created_at FROM webhook_subscriptions WHERE id = ?", id).Scan(&sub.Id, &sub.URL, &sub.Secret, &column, &sub.CreatedAt)
+	sub.Events = storage.SplitEvents(column)
+	return sub, err
+}
+
+func (s *Sqlite) Subscriptions() ([]models.Subscription, error) {
+	return subscriptions(s.Db)
+}
+
+// subscriptions lists the subscriptions through db, which is either the
+// database or a transaction.
+func subscriptions(db interface {
+	Query(query string, args ...any) (*sql.Rows, error)
+}) ([]models.Subscription, error) {
+	rows, err := db.Query("SELECT id,url,secret,events,created_at FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var list []models.Subscription
+	for rows.Next() {
+		var sub models.Subscription
+		var events string
+		if err := rows.Scan(&sub.Id, &sub.URL, &sub.Secret, &events, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		sub.Events = storage.SplitEvents(events)
+		list = append(list, sub)
+	}
+	return list, rows.Err()
+}
+
+func (s *Sqlite) DeleteSubscription(id int64) error {
+	tx, err := storage.Begin(s.ctx, s.Db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec("DELETE FROM webhook_subscriptions WHERE id = ?", id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.Exec("DELETE FROM webhook_deliveries WHERE subscription_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
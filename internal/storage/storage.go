@@ -1,17 +1,104 @@
 package storage
 
 import (
+	"context"
+	"errors"
 	"io"
+	"time"
 
 	"github.com/surajNirala/student-api/internal/models"
 )
 
+// ErrOffsetMismatch is returned when a chunk does not start where the
+// upload currently ends.
+var ErrOffsetMismatch = errors.New("upload offset mismatch")
+
+// ErrChunkTooLarge is returned when a chunk runs past the declared upload length.
+var ErrChunkTooLarge = errors.New("chunk exceeds upload length")
+
+// ErrDeletedRevision is returned when reverting to a revision that records
+// a deletion; delete the student instead.
+var ErrDeletedRevision = errors.New("revision records a deletion")
+
 type Storage interface {
+	// WithContext returns a Storage that acts on behalf of the request
+	// carried by ctx. Backends that do not use it return themselves.
+	WithContext(ctx context.Context) Storage
 	StudentList() ([]models.Student, error)
 	CreateStudent(name string, email string, age int) (int64, error)
 	GetStudentByID(id int64) (models.Student, error)
+	// UpdateStudentByID and DeleteStudentByID fail with an error wrapping
+	// sql.ErrNoRows when the student does not exist.
 	UpdateStudentByID(name string, email string, age int, id int64) (string, error)
 	DeleteStudentByID(id int64) (string, error)
-	StudentFileUpload10MB(fileName string, fileData []byte) (string, error)
-	StudentLargeFileUpload(fileName string, fileData io.Reader) (string, error)
+	// StudentHistory lists the audit entries of a student, oldest first.
+	StudentHistory(studentID int64) ([]models.AuditEntry, error)
+	// StudentRevisions lists the revisions of a student, oldest first.
+	StudentRevisions(studentID int64) ([]models.StudentRevision, error)
+	// StudentAsOf returns a student as it was at a point in time, or
+	// sql.ErrNoRows if it did not exist then.
+	StudentAsOf(studentID int64, at time.Time) (models.Student, error)
+	// RevertStudent restores the values of an earlier revision as a new
+	// revision, recreating the student if it was deleted since.
+	RevertStudent(studentID int64, revision int) (models.StudentRevision, error)
+	StudentFileUpload10MB(studentID int64, fileName string, contentType string, fileData []byte) (models.File, error)
+	StudentLargeFileUpload(studentID int64, fileName string, contentType string, fileData io.Reader) (models.File, error)
+	GetFileByID(id int64) (models.File, error)
+	OpenFile(id int64) (models.File, io.ReadSeekCloser, error)
+	DeleteFileByID(id int64) (string, error)
+	StudentFileUsage(studentID int64) (models.FileUsage, error)
+	TotalFileUsage() (models.FileUsage, error)
+	// StudentUploadUsage and TotalUploadUsage count the unfinished tus
+	// uploads and the bytes they have received so far.
+	StudentUploadUsage(studentID int64) (models.FileUsage, error)
+	TotalUploadUsage() (models.FileUsage, error)
+	SetStudentPhoto(studentID int64, photo models.Photo) error
+	GetStudentPhoto(studentID int64) (models.Photo, error)
+	CreateUpload(studentID int64, fileName string, contentType string, length int64) (models.Upload, error)
+	GetUpload(id string) (models.Upload, error)
+	WriteUploadChunk(id string, offset int64, data io.Reader) (models.Upload, error)
+	DeleteUpload(id string) error
+	ExpiredUploads(before time.Time) ([]models.Upload, error)
+	OrphanedFiles() ([]models.File, error)
+	UnreferencedBlobs() ([]models.Blob, error)
+	HasBlob(sha256 string) (bool, error)
+	DeleteBlob(sha256 string) (bool, error)
+	// Student changes add an event to the outbox in the same transaction.
+	// DispatchEvents turns up to limit of them into one pending delivery
+	// per matching subscription and returns how many it handled.
+	DispatchEvents(limit int) (int, error)
+	// EventsAfter lists up to limit outbox events with ids above id, in
+	// id order; LastEventID is the highest id so far, or 0.
+	EventsAfter(id uint64, limit int) ([]models.Event, error)
+	LastEventID() (uint64, error)
+	// ClaimDeliveries returns up to limit pending deliveries due by now
+	// and hides them from other callers for lease, so an instance that
+	// dies while sending leaves them to be retried.
+	ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]models.Delivery, error)
+	// UpdateDelivery saves the status, attempts, next attempt and last
+	// error of a claimed delivery.
+	UpdateDelivery(delivery models.Delivery) error
+	// Deliveries lists up to limit deliveries, newest first, of every
+	// status when status is "".
+	Deliveries(status string, limit int) ([]models.Delivery, error)
+	// ReplayDelivery makes a delivery pending again with no attempts.
+	ReplayDelivery(id int64) error
+	CreateSubscription(url string, secret string, events []string) (models.Subscription, error)
+	Subscriptions() ([]models.Subscription, error)
+	// DeleteSubscription removes a subscription with all its deliveries.
+	DeleteSubscription(id int64) error
+	// ReserveIdempotencyKey records key as taken until expires by the
+	// request with fingerprint and returns true. When key is already taken
+	// it returns what is recorded for it and false instead; keys past their
+	// expiry, and keys whose request was still unfinished at stale, count
+	// as free.
+	ReserveIdempotencyKey(key string, fingerprint string, expires time.Time, stale time.Time) (models.IdempotencyKey, bool, error)
+	// CompleteIdempotencyKey saves the status, header and body of the
+	// response to the request that reserved key.
+	CompleteIdempotencyKey(key models.IdempotencyKey) error
+	// DeleteIdempotencyKey frees key so the request can be made again.
+	DeleteIdempotencyKey(key string) error
+	// DeleteExpiredIdempotencyKeys removes keys that expired before before
+	// and returns how many it removed.
+	DeleteExpiredIdempotencyKeys(before time.Time) (int64, error)
 }
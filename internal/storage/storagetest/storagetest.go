@@ -0,0 +1,1216 @@
+// Package storagetest is a conformance suite for storage.Storage
+// implementations. Each backend runs it from its own tests:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T) storage.Storage {
+//			return newEmptyStorage(t)
+//		})
+//	}
+package storagetest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/digest"
+)
+
+// Factory returns an empty storage for a single test. Anything it needs
+// to release is registered with t.Cleanup.
+type Factory func(t *testing.T) storage.Storage
+
+// Run exercises newStorage against the behaviour the handlers rely on.
+// Uploaded files are written below a temporary working directory, so
+// Run cannot be used from parallel tests.
+func Run(t *testing.T, newStorage Factory) {
+	t.Chdir(t.TempDir())
+
+	tests := []struct {
+		name string
+		test func(t *testing.T, s storage.Storage)
+	}{
+		{"CreateAndGetStudent", testCreateAndGetStudent},
+		{"StudentNotFound", testStudentNotFound},
+		{"UpdateStudent", testUpdateStudent},
+		{"DeleteStudent", testDeleteStudent},
+		{"StudentHistory", testStudentHistory},
+		{"StudentRevisions", testStudentRevisions},
+		{"Webhooks", testWebhooks},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"Transactions", testTransactions},
+		{"TransactionalFileDeletes", testTransactionalFileDeletes},
+		{"StudentListOrder", testStudentListOrder},
+		{"StudentTimestamps", testStudentTimestamps},
+		{"ConcurrentCreates", testConcurrentCreates},
+		{"Files", testFiles},
+		{"FileNotFound", testFileNotFound},
+		{"SharedContent", testSharedContent},
+		{"ConcurrentSharedContent", testConcurrentSharedContent},
+		{"FileUsage", testFileUsage},
+		{"UploadUsage", testUploadUsage},
+		{"OrphanedFiles", testOrphanedFiles},
+		{"StudentPhoto", testStudentPhoto},
+		{"Uploads", testUploads},
+		{"ConcurrentUploadChunks", testConcurrentUploadChunks},
+		{"UploadNotFound", testUploadNotFound},
+		{"ExpiredUploads", testExpiredUploads},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newStorage(t))
+		})
+	}
+}
+
+// timestampSlack absorbs backends that store whole seconds and clocks
+// that differ slightly between the test and a database server.
+const timestampSlack = 2 * time.Second
+
+func createStudent(t *testing.T, s storage.Storage, name string) int64 {
+	t.Helper()
+	id, err := s.CreateStudent(name, name+"@example.com", 20)
+	if err != nil {
+		t.Fatalf("CreateStudent(%q): %v", name, err)
+	}
+	if id <= 0 {
+		t.Fatalf("CreateStudent(%q) returned id %d", name, id)
+	}
+	return id
+}
+
+func uploadFile(t *testing.T, s storage.Storage, studentID int64, content string) models.File {
+	t.Helper()
+	file, err := s.StudentLargeFileUpload(studentID, "notes.txt", "text/plain", strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("StudentLargeFileUpload: %v", err)
+	}
+	return file
+}
+
+func digestOf(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func testCreateAndGetStudent(t *testing.T, s storage.Storage) {
+	id, err := s.CreateStudent("Asha", "asha@example.com", 21)
+	if err != nil {
+		t.Fatal(err)
+	}
+	student, err := s.GetStudentByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if student.Id != uint64(id) || student.Name != "Asha" || student.Email != "asha@example.com" || student.Age != 21 {
+		t.Errorf("GetStudentByID(%d) = %+v", id, student)
+	}
+	if student.PhotoURL != "" {
+		t.Errorf("new student has photo URL %q", student.PhotoURL)
+	}
+
+	next := createStudent(t, s, "Ravi")
+	if next <= id {
+		t.Errorf("ids are not increasing: %d after %d", next, id)
+	}
+}
+
+func testStudentNotFound(t *testing.T, s storage.Storage) {
+	if _, err := s.GetStudentByID(404); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetStudentByID on missing student: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.UpdateStudentByID("x", "x@example.com", 1, 404); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("UpdateStudentByID on missing student: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.DeleteStudentByID(404); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteStudentByID on missing student: got %v, want sql.ErrNoRows", err)
+	}
+	list, err := s.StudentList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 0 {
+		t.Errorf("StudentList on empty storage returned %d students", len(list))
+	}
+}
+
+func testUpdateStudent(t *testing.T, s storage.Storage) {
+	id := createStudent(t, s, "Asha")
+	other := createStudent(t, s, "Ravi")
+	if _, err := s.UpdateStudentByID("Asha K", "asha.k@example.com", 22, id); err != nil {
+		t.Fatal(err)
+	}
+	student, err := s.GetStudentByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if student.Name != "Asha K" || student.Email != "asha.k@example.com" || student.Age != 22 {
+		t.Errorf("after update got %+v", student)
+	}
+	untouched, err := s.GetStudentByID(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if untouched.Name != "Ravi" {
+		t.Errorf("update changed another student: %+v", untouched)
+	}
+}
+
+func testDeleteStudent(t *testing.T, s storage.Storage) {
+	id := createStudent(t, s, "Asha")
+	other := createStudent(t, s, "Ravi")
+	if _, err := s.DeleteStudentByID(id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetStudentByID(id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetStudentByID after delete: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.DeleteStudentByID(id); err == nil {
+		t.Error("deleting a student twice succeeded")
+	}
+	if _, err := s.GetStudentByID(other); err != nil {
+		t.Errorf("delete removed another student: %v", err)
+	}
+}
+
+func testStudentHistory(t *testing.T, s storage.Storage) {
+	ctx := storage.ContextWithActor(context.Background(), "registrar")
+	ctx = storage.ContextWithRequestID(ctx, "req-1")
+	scoped := s.WithContext(ctx)
+
+	id, err := scoped.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := createStudent(t, s, "Ravi")
+	if _, err := scoped.UpdateStudentByID("Asha", "asha@example.org", 20, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteStudentByID(id); err != nil {
+		t.Fatal(err)
+	}
+
+	history, err := s.StudentHistory(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		action, actor, requestID string
+		changes                  map[string]storage.Change
+	}{
+		{storage.AuditCreate, "registrar", "req-1", map[string]storage.Change{
+			"name": {To: "Asha"}, "email": {To: "asha@example.com"}, "age": {To: float64(20)},
+		}},
+		{storage.AuditUpdate, "registrar", "req-1", map[string]storage.Change{
+			"email": {From: "asha@example.com", To: "asha@example.org"},
+		}},
+		{storage.AuditDelete, "system", "", map[string]storage.Change{
+			"name": {From: "Asha"}, "email": {From: "asha@example.org"}, "age": {From: float64(20)},
+		}},
+	}
+	if len(history) != len(want) {
+		t.Fatalf("StudentHistory returned %d entries, want %d: %+v", len(history), len(want), history)
+	}
+	for i, entry := range history {
+		w := want[i]
+		if entry.Action != w.action || entry.Actor != w.actor || entry.RequestId != w.requestID {
+			t.Errorf("entry %d: got action %q actor %q request %q, want %q %q %q", i, entry.Action, entry.Actor, entry.RequestId, w.action, w.actor, w.requestID)
+		}
+		if entry.EntityType != storage.EntityStudent || entry.EntityId != id {
+			t.Errorf("entry %d is for %s %d, want student %d", i, entry.EntityType, entry.EntityId, id)
+		}
+		if entry.CreatedAt.IsZero() {
+			t.Errorf("entry %d has no timestamp", i)
+		}
+		var changes map[string]storage.Change
+		if err := json.Unmarshal(entry.Changes, &changes); err != nil {
+			t.Fatalf("entry %d changes %s: %v", i, entry.Changes, err)
+		}
+		if !reflect.DeepEqual(changes, w.changes) {
+			t.Errorf("entry %d changes: got %v, want %v", i, changes, w.changes)
+		}
+	}
+
+	// A failed change leaves no entry behind
+	if _, err := s.UpdateStudentByID("Nobody", "nobody@example.com", 20, id); err == nil {
+		t.Fatal("updating a deleted student succeeded")
+	}
+	if history, _ := s.StudentHistory(id); len(history) != len(want) {
+		t.Errorf("failed update was audited: %d entries", len(history))
+	}
+	if history, _ := s.StudentHistory(other); len(history) != 1 {
+		t.Errorf("other student has %d entries, want 1", len(history))
+	}
+}
+
+func testStudentRevisions(t *testing.T, s storage.Storage) {
+	id := createStudent(t, s, "Asha")
+	// Keep the revisions apart on backends that store whole seconds
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := s.UpdateStudentByID("Asha Rao", "asha@example.org", 21, id); err != nil {
+		t.Fatal(err)
+	}
+	revisions, err := s.StudentRevisions(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("StudentRevisions returned %d revisions, want 2", len(revisions))
+	}
+	for i, rev := range revisions {
+		if rev.StudentId != id || rev.Revision != i+1 || rev.Deleted {
+			t.Errorf("revision %d: %+v", i+1, rev)
+		}
+	}
+
+	asOf := func(at time.Time, want string) {
+		t.Helper()
+		student, err := s.StudentAsOf(id, at)
+		if want == "" {
+			if !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("StudentAsOf(%v): got %+v, %v, want sql.ErrNoRows", at, student, err)
+			}
+			return
+		}
+		if err != nil {
+			t.Fatalf("StudentAsOf(%v): %v", at, err)
+		}
+		if student.Name != want || student.Id != uint64(id) {
+			t.Errorf("StudentAsOf(%v) = %+v, want name %q", at, student, want)
+		}
+	}
+	asOf(revisions[0].CreatedAt.Add(-time.Second), "")
+	asOf(revisions[0].CreatedAt, "Asha")
+	asOf(revisions[1].CreatedAt, "Asha Rao")
+
+	reverted, err := s.RevertStudent(id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reverted.Revision != 3 || reverted.Name != "Asha" || reverted.Email != "Asha@example.com" || reverted.Age != 20 {
+		t.Errorf("RevertStudent(1) = %+v", reverted)
+	}
+	if student, err := s.GetStudentByID(id); err != nil || student.Name != "Asha" || student.Age != 20 {
+		t.Errorf("GetStudentByID after revert = %+v, %v", student, err)
+	}
+	if _, err := s.RevertStudent(id, 9); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("RevertStudent to a missing revision: got %v, want sql.ErrNoRows", err)
+	}
+
+	photo := models.Photo{
+		OriginalFileId: uploadFile(t, s, id, "original").Id,
+		MediumFileId:   uploadFile(t, s, id, "medium").Id,
+		ThumbFileId:    uploadFile(t, s, id, "thumb").Id,
+	}
+	if err := s.SetStudentPhoto(id, photo); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteStudentByID(id); err != nil {
+		t.Fatal(err)
+	}
+	asOf(time.Now().Add(time.Hour), "")
+	if _, err := s.RevertStudent(id, 4); !errors.Is(err, storage.ErrDeletedRevision) {
+		t.Errorf("RevertStudent to a deletion: got %v, want ErrDeletedRevision", err)
+	}
+	// Reverting a deleted student brings it back under the same id
+	if _, err := s.RevertStudent(id, 2); err != nil {
+		t.Fatal(err)
+	}
+	if student, err := s.GetStudentByID(id); err != nil || student.Name != "Asha Rao" || student.PhotoURL != "" {
+		t.Errorf("GetStudentByID after restoring = %+v, %v", student, err)
+	}
+	// The photo's files are orphaned by the deletion, so it does not come back
+	if _, err := s.GetStudentPhoto(id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetStudentPhoto after restoring: got %v, want sql.ErrNoRows", err)
+	}
+	history, err := s.StudentHistory(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 6 || history[5].Action != storage.AuditRevert {
+		t.Errorf("history after reverts: %+v", history)
+	}
+}
+
+func testWebhooks(t *testing.T, s storage.Storage) {
+	all, err := s.CreateSubscription("http://lms.example.com/hook", "secret", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deletes, err := s.CreateSubscription("http://library.example.com/hook", "other", []string{storage.EventStudentDeleted})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if all.Id == 0 || all.Secret != "secret" || len(all.Events) != 0 || all.CreatedAt.IsZero() {
+		t.Errorf("CreateSubscription = %+v", all)
+	}
+	subs, err := s.Subscriptions()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 2 || subs[1].Id != deletes.Id || !reflect.DeepEqual(subs[1].Events, []string{storage.EventStudentDeleted}) {
+		t.Errorf("Subscriptions = %+v", subs)
+	}
+
+	id := createStudent(t, s, "Asha")
+	if _, err := s.UpdateStudentByID("Asha", "asha@example.org", 21, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.DeleteStudentByID(id); err != nil {
+		t.Fatal(err)
+	}
+	// A change that fails publishes nothing
+	s.UpdateStudentByID("Nobody", "nobody@example.com", 20, id)
+
+	if n, err := s.DispatchEvents(2); err != nil || n != 2 {
+		t.Fatalf("DispatchEvents(2) = %d, %v, want 2", n, err)
+	}
+	if n, err := s.DispatchEvents(10); err != nil || n != 1 {
+		t.Fatalf("DispatchEvents(10) = %d, %v, want 1", n, err)
+	}
+	if n, err := s.DispatchEvents(10); err != nil || n != 0 {
+		t.Fatalf("DispatchEvents with nothing left = %d, %v", n, err)
+	}
+
+	now := time.Now()
+	claimed, err := s.ClaimDeliveries(now, time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// One delivery per event for the first subscription, one for the delete
+	if len(claimed) != 4 {
+		t.Fatalf("ClaimDeliveries returned %d deliveries, want 4: %+v", len(claimed), claimed)
+	}
+	wantTypes := []string{storage.EventStudentCreated, storage.EventStudentUpdated, storage.EventStudentDeleted, storage.EventStudentDeleted}
+	var gotTypes []string
+	for _, d := range claimed {
+		gotTypes = append(gotTypes, d.Event.Type)
+		if d.Status != models.DeliveryPending || d.Event.StudentId != id || d.Event.Id != d.EventId || d.URL == "" || d.Secret == "" {
+			t.Errorf("claimed delivery %+v", d)
+		}
+	}
+	if !reflect.DeepEqual(gotTypes, wantTypes) {
+		t.Errorf("claimed event types %v, want %v", gotTypes, wantTypes)
+	}
+	var data struct {
+		Id    uint64 `json:"id"`
+		Email string `json:"email"`
+	}
+	if err := json.Unmarshal(claimed[1].Event.Data, &data); err != nil || data.Id != uint64(id) || data.Email != "asha@example.org" {
+		t.Errorf("update event data %s: %v", claimed[1].Event.Data, err)
+	}
+	if again, err := s.ClaimDeliveries(now, time.Minute, 10); err != nil || len(again) != 0 {
+		t.Errorf("leased deliveries claimed again: %d, %v", len(again), err)
+	}
+
+	delivered, dead := claimed[0], claimed[1]
+	delivered.Attempts, delivered.Status = 1, models.DeliveryDelivered
+	dead.Attempts, dead.Status, dead.LastError = 3, models.DeliveryDead, "unexpected status 500"
+	for _, d := range []models.Delivery{delivered, dead} {
+		if err := s.UpdateDelivery(d); err != nil {
+			t.Fatal(err)
+		}
+	}
+	list, err := s.Deliveries(models.DeliveryDead, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Id != dead.Id || list[0].Attempts != 3 || list[0].LastError != "unexpected status 500" {
+		t.Errorf("Deliveries(dead) = %+v", list)
+	}
+	if list, err := s.Deliveries("", 3); err != nil || len(list) != 3 || list[0].Id != claimed[3].Id {
+		t.Errorf("Deliveries(\"\", 3) = %+v, %v", list, err)
+	}
+
+	if err := s.ReplayDelivery(int64(dead.Id)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ReplayDelivery(999999); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("ReplayDelivery of a missing delivery: got %v, want sql.ErrNoRows", err)
+	}
+	replayed, err := s.ClaimDeliveries(time.Now().Add(time.Second), time.Minute, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replayed) != 1 || replayed[0].Id != dead.Id || replayed[0].Attempts != 0 || replayed[0].LastError != "" {
+		t.Errorf("claimed after replay: %+v", replayed)
+	}
+
+	if err := s.DeleteSubscription(int64(deletes.Id)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.DeleteSubscription(int64(deletes.Id)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("deleting a subscription twice: got %v, want sql.ErrNoRows", err)
+	}
+	if list, _ := s.Deliveries("", 10); len(list) != 3 {
+		t.Errorf("%d deliveries left after deleting a subscription, want 3", len(list))
+	}
+}
+
+func testIdempotencyKeys(t *testing.T, s storage.Storage) {
+	now := time.Now()
+	expires := now.Add(time.Hour)
+	stale := now.Add(-time.Minute)
+	key, ok, err := s.ReserveIdempotencyKey("key-1", "print-1", expires, stale)
+	if err != nil || !ok {
+		t.Fatalf("ReserveIdempotencyKey = %v, %v, want reserved", ok, err)
+	}
+	if key.Key != "key-1" || key.Fingerprint != "print-1" || key.StatusCode != 0 {
+		t.Errorf("ReserveIdempotencyKey = %+v", key)
+	}
+
+	// A repeat while the first request is still running sees it unfinished
+	key, ok, err = s.ReserveIdempotencyKey("key-1", "print-2", expires, stale)
+	if err != nil || ok {
+		t.Fatalf("ReserveIdempotencyKey of a taken key = %v, %v", ok, err)
+	}
+	if key.Fingerprint != "print-1" || key.StatusCode != 0 {
+		t.Errorf("taken key = %+v, want print-1 unfinished", key)
+	}
+
+	header := http.Header{"Content-Type": {"application/json"}}
+	body := []byte(`{"id":1}`)
+	if err := s.CompleteIdempotencyKey(models.IdempotencyKey{Key: "key-1", StatusCode: http.StatusCreated, Header: header, Body: body}); err != nil {
+		t.Fatal(err)
+	}
+	key, ok, err = s.ReserveIdempotencyKey("key-1", "print-1", expires, stale)
+	if err != nil || ok {
+		t.Fatalf("ReserveIdempotencyKey of a completed key = %v, %v", ok, err)
+	}
+	if key.StatusCode != http.StatusCreated || !reflect.DeepEqual(key.Header, header) || !bytes.Equal(key.Body, body) {
+		t.Errorf("completed key = %+v", key)
+	}
+	if key.ExpiresAt.Sub(expires).Abs() > timestampSlack {
+		t.Errorf("ExpiresAt = %v, want %v", key.ExpiresAt, expires)
+	}
+	// Completed keys are kept until they expire, however old they are
+	if _, ok, err := s.ReserveIdempotencyKey("key-1", "print-1", expires, now.Add(time.Minute)); err != nil || ok {
+		t.Errorf("completed key taken over as stale: %v, %v", ok, err)
+	}
+
+	// An unfinished request that has gone stale frees its key
+	if _, ok, err := s.ReserveIdempotencyKey("key-2", "print-1", expires, stale); err != nil || !ok {
+		t.Fatalf("ReserveIdempotencyKey(key-2) = %v, %v", ok, err)
+	}
+	key, ok, err = s.ReserveIdempotencyKey("key-2", "print-2", expires, now.Add(time.Minute))
+	if err != nil || !ok || key.Fingerprint != "print-2" {
+		t.Errorf("stale key not taken over: %+v, %v, %v", key, ok, err)
+	}
+
+	if err := s.DeleteIdempotencyKey("key-2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, err := s.ReserveIdempotencyKey("key-2", "print-3", expires, stale); err != nil || !ok {
+		t.Errorf("deleted key not free: %v, %v", ok, err)
+	}
+
+	// Expired keys are free, and removed by DeleteExpiredIdempotencyKeys
+	if _, ok, err := s.ReserveIdempotencyKey("key-3", "print-1", now.Add(-time.Second), stale); err != nil || !ok {
+		t.Fatalf("ReserveIdempotencyKey(key-3) = %v, %v", ok, err)
+	}
+	if n, err := s.DeleteExpiredIdempotencyKeys(now); err != nil || n != 1 {
+		t.Errorf("DeleteExpiredIdempotencyKeys = %d, %v, want 1", n, err)
+	}
+	if _, ok, err := s.ReserveIdempotencyKey("key-1", "print-1", expires, stale); err != nil || ok {
+		t.Errorf("unexpired key-1 was removed: %v, %v", ok, err)
+	}
+}
+
+func testTransactions(t *testing.T, s storage.Storage) {
+	transactor, ok := storage.As[storage.Transactor](s)
+	if !ok {
+		t.Skip("storage does not implement storage.Transactor")
+	}
+	ctx := context.Background()
+	kept := createStudent(t, s, "Asha")
+
+	errRollback := errors.New("rollback")
+	var created int64
+	err := transactor.InTx(ctx, func(ctx context.Context) error {
+		tx := s.WithContext(ctx)
+		var err error
+		if created, err = tx.CreateStudent("Ravi", "ravi@example.com", 21); err != nil {
+			return err
+		}
+		if _, err := tx.UpdateStudentByID("Asha", "asha@example.org", 22, kept); err != nil {
+			return err
+		}
+		// Reads inside the transaction see its changes
+		if student, err := tx.GetStudentByID(created); err != nil || student.Name != "Ravi" {
+			t.Errorf("GetStudentByID inside the transaction = %+v, %v", student, err)
+		}
+		if !storage.AfterCommit(ctx, func() { t.Error("AfterCommit ran after a rollback") }) {
+			t.Error("AfterCommit did not find the transaction")
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("InTx = %v, want %v", err, errRollback)
+	}
+	if _, err := s.GetStudentByID(created); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("student created in a rolled back transaction: %v", err)
+	}
+	if student, err := s.GetStudentByID(kept); err != nil || student.Email != "Asha@example.com" || student.Age != 20 {
+		t.Errorf("update kept after rollback: %+v, %v", student, err)
+	}
+	if history, err := s.StudentHistory(kept); err != nil || len(history) != 1 {
+		t.Errorf("StudentHistory after rollback = %d entries, %v, want 1", len(history), err)
+	}
+
+	committed := false
+	err = transactor.InTx(ctx, func(ctx context.Context) error {
+		tx := s.WithContext(ctx)
+		var err error
+		if created, err = tx.CreateStudent("Ravi", "ravi@example.com", 21); err != nil {
+			return err
+		}
+		// A failing operation undoes only itself
+		if _, err := tx.UpdateStudentByID("Nobody", "nobody@example.com", 20, created+100); err == nil {
+			t.Error("UpdateStudentByID of a missing student succeeded")
+		}
+		if _, err := tx.DeleteStudentByID(kept); err != nil {
+			return err
+		}
+		storage.AfterCommit(ctx, func() { committed = true })
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !committed {
+		t.Error("AfterCommit did not run after the commit")
+	}
+	if _, err := s.GetStudentByID(created); err != nil {
+		t.Errorf("student created in a committed transaction: %v", err)
+	}
+	if _, err := s.GetStudentByID(kept); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("student deleted in a committed transaction: %v", err)
+	}
+}
+
+func testTransactionalFileDeletes(t *testing.T, s storage.Storage) {
+	transactor, ok := storage.As[storage.Transactor](s)
+	if !ok {
+		t.Skip("storage does not implement storage.Transactor")
+	}
+	ctx := context.Background()
+	file := uploadFile(t, s, 0, "kept on rollback")
+	upload, err := s.CreateUpload(0, "big.bin", "application/octet-stream", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	deleteBoth := func(ctx context.Context) error {
+		tx := s.WithContext(ctx)
+		if _, err := tx.DeleteFileByID(int64(file.Id)); err != nil {
+			return err
+		}
+		if err := tx.DeleteUpload(upload.Id); err != nil {
+			return err
+		}
+		// Nothing is removed from disk before the commit
+		for _, path := range []string{file.Path, upload.Path} {
+			if _, err := os.Stat(path); err != nil {
+				t.Errorf("%s inside the transaction: %v", path, err)
+			}
+		}
+		return nil
+	}
+
+	errRollback := errors.New("rollback")
+	err = transactor.InTx(ctx, func(ctx context.Context) error {
+		if err := deleteBoth(ctx); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("InTx = %v, want %v", err, errRollback)
+	}
+	_, rc, err := s.OpenFile(int64(file.Id))
+	if err != nil {
+		t.Fatalf("OpenFile after rollback: %v", err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil || string(data) != "kept on rollback" {
+		t.Errorf("file after rollback holds %q, %v", data, err)
+	}
+	if _, err := os.Stat(upload.Path); err != nil {
+		t.Errorf("partial upload after rollback: %v", err)
+	}
+
+	if err := transactor.InTx(ctx, deleteBoth); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{file.Path, upload.Path} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s after the commit: %v", path, err)
+		}
+	}
+}
+
+func testStudentListOrder(t *testing.T, s storage.Storage) {
+	var ids []int64
+	for i := range 5 {
+		ids = append(ids, createStudent(t, s, fmt.Sprintf("student%d", i)))
+	}
+	list, err := s.StudentList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != len(ids) {
+		t.Fatalf("StudentList returned %d students, want %d", len(list), len(ids))
+	}
+	// Newest first
+	for i, student := range list {
+		if want := ids[len(ids)-1-i]; student.Id != uint64(want) {
+			t.Errorf("StudentList()[%d].Id = %d, want %d", i, student.Id, want)
+		}
+	}
+}
+
+func testStudentTimestamps(t *testing.T, s storage.Storage) {
+	before := time.Now()
+	id := createStudent(t, s, "Asha")
+	after := time.Now()
+	student, err := s.GetStudentByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if student.CreatedAt.Before(before.Add(-timestampSlack)) || student.CreatedAt.After(after.Add(timestampSlack)) {
+		t.Errorf("CreatedAt = %v, want between %v and %v", student.CreatedAt, before, after)
+	}
+	if !student.UpdatedAt.Equal(student.CreatedAt) && student.UpdatedAt.Sub(student.CreatedAt).Abs() > timestampSlack {
+		t.Errorf("new student has UpdatedAt %v far from CreatedAt %v", student.UpdatedAt, student.CreatedAt)
+	}
+
+	// Backends may store whole seconds, so wait long enough to see a change
+	time.Sleep(1100 * time.Millisecond)
+	if _, err := s.UpdateStudentByID("Asha K", "asha@example.com", 20, id); err != nil {
+		t.Fatal(err)
+	}
+	updated, err := s.GetStudentByID(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !updated.CreatedAt.Equal(student.CreatedAt) {
+		t.Errorf("update changed CreatedAt from %v to %v", student.CreatedAt, updated.CreatedAt)
+	}
+	if !updated.UpdatedAt.After(student.UpdatedAt) {
+		t.Errorf("update did not advance UpdatedAt: %v, was %v", updated.UpdatedAt, student.UpdatedAt)
+	}
+}
+
+func testConcurrentCreates(t *testing.T, s storage.Storage) {
+	const workers = 8
+	const perWorker = 10
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	seen := make(map[int64]bool)
+	errs := make(chan error, workers*perWorker)
+	for w := range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range perWorker {
+				id, err := s.CreateStudent(fmt.Sprintf("w%d-%d", w, i), "w@example.com", 20)
+				if err != nil {
+					errs <- err
+					continue
+				}
+				if _, err := s.GetStudentByID(id); err != nil {
+					errs <- err
+				}
+				mu.Lock()
+				if seen[id] {
+					errs <- fmt.Errorf("id %d handed out twice", id)
+				}
+				seen[id] = true
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	list, err := s.StudentList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != workers*perWorker {
+		t.Errorf("StudentList returned %d students, want %d", len(list), workers*perWorker)
+	}
+}
+
+func testFiles(t *testing.T, s storage.Storage) {
+	studentID := createStudent(t, s, "Asha")
+	content := "lecture notes"
+	file, err := s.StudentFileUpload10MB(studentID, "notes.txt", "text/plain", []byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Id == 0 || file.StudentId != studentID || file.Name != "notes.txt" || file.ContentType != "text/plain" {
+		t.Errorf("uploaded file = %+v", file)
+	}
+	if file.Size != int64(len(content)) || file.SHA256 != digestOf(content) {
+		t.Errorf("uploaded file has size %d and digest %s", file.Size, file.SHA256)
+	}
+
+	got, err := s.GetFileByID(int64(file.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Id != file.Id || got.SHA256 != file.SHA256 || got.StudentId != studentID {
+		t.Errorf("GetFileByID = %+v, want %+v", got, file)
+	}
+
+	_, rc, err := s.OpenFile(int64(file.Id))
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("OpenFile read %q, want %q", data, content)
+	}
+
+	if _, err := s.DeleteFileByID(int64(file.Id)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetFileByID(int64(file.Id)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFileByID after delete: got %v, want sql.ErrNoRows", err)
+	}
+	if ok, err := s.HasBlob(file.SHA256); err != nil || ok {
+		t.Errorf("HasBlob after deleting the only file = %v, %v", ok, err)
+	}
+}
+
+func testFileNotFound(t *testing.T, s storage.Storage) {
+	if _, err := s.GetFileByID(404); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetFileByID: got %v, want sql.ErrNoRows", err)
+	}
+	if _, _, err := s.OpenFile(404); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("OpenFile: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.DeleteFileByID(404); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteFileByID: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testSharedContent(t *testing.T, s storage.Storage) {
+	studentID := createStudent(t, s, "Asha")
+	first := uploadFile(t, s, studentID, "same bytes")
+	second := uploadFile(t, s, 0, "same bytes")
+	if first.Id == second.Id {
+		t.Fatalf("both uploads got file id %d", first.Id)
+	}
+	if second.StudentId != 0 {
+		t.Errorf("file without owner has StudentId %d", second.StudentId)
+	}
+	if first.Path != second.Path {
+		t.Errorf("identical content stored twice: %s and %s", first.Path, second.Path)
+	}
+	usage, err := s.TotalFileUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Files != 2 || usage.Bytes != int64(len("same bytes")) {
+		t.Errorf("TotalFileUsage = %+v, want 2 files sharing %d bytes", usage, len("same bytes"))
+	}
+
+	if _, err := s.DeleteFileByID(int64(first.Id)); err != nil {
+		t.Fatal(err)
+	}
+	_, rc, err := s.OpenFile(int64(second.Id))
+	if err != nil {
+		t.Fatalf("deleting one copy broke the other: %v", err)
+	}
+	rc.Close()
+	if _, err := s.DeleteFileByID(int64(second.Id)); err != nil {
+		t.Fatal(err)
+	}
+	blobs, err := s.UnreferencedBlobs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blobs) != 0 {
+		t.Errorf("content left behind after deleting every file: %+v", blobs)
+	}
+}
+
+// testConcurrentSharedContent uploads content again while its last file
+// is deleted and the collector sweeps its blob. Whichever order they run
+// in, the new upload must stay readable.
+func testConcurrentSharedContent(t *testing.T, s storage.Storage) {
+	for round := range 200 {
+		content := fmt.Sprintf("shared content %d", round)
+		old := uploadFile(t, s, 0, content)
+		var wg sync.WaitGroup
+		var file models.File
+		var uploadErr error
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			if _, err := s.DeleteFileByID(int64(old.Id)); err != nil {
+				t.Errorf("DeleteFileByID: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			if _, err := s.DeleteBlob(old.SHA256); err != nil && !errors.Is(err, sql.ErrNoRows) {
+				t.Errorf("DeleteBlob: %v", err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			file, uploadErr = s.StudentLargeFileUpload(0, "notes.txt", "text/plain", strings.NewReader(content))
+		}()
+		wg.Wait()
+		if uploadErr != nil {
+			t.Fatal(uploadErr)
+		}
+		_, rc, err := s.OpenFile(int64(file.Id))
+		if err != nil {
+			t.Fatalf("round %d: file uploaded during the delete is unreadable: %v", round, err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(data) != content {
+			t.Fatalf("round %d: read %q, %v", round, data, err)
+		}
+		if ok, err := s.HasBlob(file.SHA256); err != nil || !ok {
+			t.Fatalf("round %d: HasBlob = %v, %v", round, ok, err)
+		}
+	}
+}
+
+func testFileUsage(t *testing.T, s storage.Storage) {
+	asha := createStudent(t, s, "Asha")
+	ravi := createStudent(t, s, "Ravi")
+	uploadFile(t, s, asha, "abc")
+	uploadFile(t, s, asha, "defgh")
+	uploadFile(t, s, ravi, "ij")
+
+	usage, err := s.StudentFileUsage(asha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.StudentId != asha || usage.Files != 2 || usage.Bytes != 8 {
+		t.Errorf("StudentFileUsage(%d) = %+v, want 2 files, 8 bytes", asha, usage)
+	}
+	usage, err = s.StudentFileUsage(404)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Files != 0 || usage.Bytes != 0 {
+		t.Errorf("StudentFileUsage of student without files = %+v", usage)
+	}
+	usage, err = s.TotalFileUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Files != 3 || usage.Bytes != 10 {
+		t.Errorf("TotalFileUsage = %+v, want 3 files, 10 bytes", usage)
+	}
+}
+
+func testUploadUsage(t *testing.T, s storage.Storage) {
+	asha := createStudent(t, s, "Asha")
+	ravi := createStudent(t, s, "Ravi")
+	for _, u := range []struct {
+		owner   int64
+		length  int64
+		content string
+	}{
+		{asha, 10, "abc"},
+		{asha, 5, "defgh"}, // complete, so its bytes belong to a file
+		{asha, 4, ""},
+		{ravi, 6, "ij"},
+	} {
+		upload, err := s.CreateUpload(u.owner, "part.bin", "", u.length)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.content == "" {
+			continue
+		}
+		if _, err := s.WriteUploadChunk(upload.Id, 0, strings.NewReader(u.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	usage, err := s.StudentUploadUsage(asha)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.StudentId != asha || usage.Files != 2 || usage.Bytes != 3 {
+		t.Errorf("StudentUploadUsage(%d) = %+v, want 2 uploads, 3 bytes", asha, usage)
+	}
+	usage, err = s.TotalUploadUsage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Files != 3 || usage.Bytes != 5 {
+		t.Errorf("TotalUploadUsage = %+v, want 3 uploads, 5 bytes", usage)
+	}
+}
+
+func testOrphanedFiles(t *testing.T, s storage.Storage) {
+	asha := createStudent(t, s, "Asha")
+	ravi := createStudent(t, s, "Ravi")
+	orphan := uploadFile(t, s, asha, "asha's")
+	uploadFile(t, s, ravi, "ravi's")
+	uploadFile(t, s, 0, "nobody's")
+	if _, err := s.DeleteStudentByID(asha); err != nil {
+		t.Fatal(err)
+	}
+	files, err := s.OrphanedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].Id != orphan.Id {
+		t.Errorf("OrphanedFiles = %+v, want only file %d", files, orphan.Id)
+	}
+}
+
+func testStudentPhoto(t *testing.T, s storage.Storage) {
+	studentID := createStudent(t, s, "Asha")
+	if _, err := s.GetStudentPhoto(studentID); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetStudentPhoto without photo: got %v, want sql.ErrNoRows", err)
+	}
+	setPhoto := func(content string) models.Photo {
+		photo := models.Photo{
+			OriginalFileId: uploadFile(t, s, studentID, content+" original").Id,
+			MediumFileId:   uploadFile(t, s, studentID, content+" medium").Id,
+			ThumbFileId:    uploadFile(t, s, studentID, content+" thumb").Id,
+		}
+		if err := s.SetStudentPhoto(studentID, photo); err != nil {
+			t.Fatal(err)
+		}
+		return photo
+	}
+
+	first := setPhoto("first")
+	got, err := s.GetStudentPhoto(studentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.StudentId != studentID || got.OriginalFileId != first.OriginalFileId || got.ThumbFileId != first.ThumbFileId {
+		t.Errorf("GetStudentPhoto = %+v, want %+v", got, first)
+	}
+	student, err := s.GetStudentByID(studentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if student.PhotoURL != models.StudentPhotoURL(uint64(studentID)) {
+		t.Errorf("student with photo has PhotoURL %q", student.PhotoURL)
+	}
+
+	// Replacing the photo releases the files of the old one
+	second := setPhoto("second")
+	if _, err := s.GetFileByID(int64(first.OriginalFileId)); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("old photo file still exists: %v", err)
+	}
+	got, err = s.GetStudentPhoto(studentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.MediumFileId != second.MediumFileId {
+		t.Errorf("GetStudentPhoto after replace = %+v, want %+v", got, second)
+	}
+	usage, err := s.StudentFileUsage(studentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if usage.Files != 3 {
+		t.Errorf("student has %d files after replacing the photo, want 3", usage.Files)
+	}
+}
+
+func testUploads(t *testing.T, s storage.Storage) {
+	studentID := createStudent(t, s, "Asha")
+	content := []byte("hello, resumable world")
+	upload, err := s.CreateUpload(studentID, "hello.txt", "text/plain", int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload.Id == "" || upload.Offset != 0 || upload.Length != int64(len(content)) || upload.Complete() {
+		t.Fatalf("CreateUpload = %+v", upload)
+	}
+
+	upload, err = s.WriteUploadChunk(upload.Id, 0, bytes.NewReader(content[:5]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload.Offset != 5 {
+		t.Errorf("offset after first chunk = %d, want 5", upload.Offset)
+	}
+	if _, err := s.WriteUploadChunk(upload.Id, 0, bytes.NewReader(content)); !errors.Is(err, storage.ErrOffsetMismatch) {
+		t.Errorf("chunk at a stale offset: got %v, want storage.ErrOffsetMismatch", err)
+	}
+	if _, err := s.WriteUploadChunk(upload.Id, 5, bytes.NewReader(append(content[5:], '!'))); !errors.Is(err, storage.ErrChunkTooLarge) {
+		t.Errorf("chunk past the length: got %v, want storage.ErrChunkTooLarge", err)
+	}
+	got, err := s.GetUpload(upload.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Offset != 5 {
+		t.Errorf("rejected chunks moved the offset to %d", got.Offset)
+	}
+
+	upload, err = s.WriteUploadChunk(upload.Id, 5, bytes.NewReader(content[5:]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !upload.Complete() || upload.FileId == 0 {
+		t.Fatalf("upload after last chunk = %+v", upload)
+	}
+	file, err := s.GetFileByID(int64(upload.FileId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.StudentId != studentID || file.Name != "hello.txt" || file.SHA256 != digestOf(string(content)) {
+		t.Errorf("completed upload became %+v", file)
+	}
+
+	// Deleting a finished upload keeps its file
+	if err := s.DeleteUpload(upload.Id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.GetUpload(upload.Id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUpload after delete: got %v, want sql.ErrNoRows", err)
+	}
+	_, rc, err := s.OpenFile(int64(file.Id))
+	if err != nil {
+		t.Fatalf("file of deleted upload is gone: %v", err)
+	}
+	rc.Close()
+}
+
+// slowChunk delivers content a little at a time, so chunks sent at once
+// overlap. With fail set it ends by failing its digest check, like a
+// chunk that was corrupted on the way.
+type slowChunk struct {
+	r    io.Reader
+	fail bool
+}
+
+func (c slowChunk) Read(p []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	n, err := c.r.Read(p[:min(len(p), 512)])
+	if err == io.EOF && c.fail {
+		err = digest.ErrMismatch
+	}
+	return n, err
+}
+
+// testConcurrentUploadChunks sends the same chunk several times at once,
+// as a client retrying a PATCH that timed out might, along with corrupted
+// copies of it. Exactly one good chunk must land, intact.
+func testConcurrentUploadChunks(t *testing.T, s storage.Storage) {
+	content := strings.Repeat("0123456789", 1000)
+	upload, err := s.CreateUpload(0, "digits.txt", "text/plain", int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	const writers = 8
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	accepted := 0
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			chunk := slowChunk{r: strings.NewReader(content)}
+			if i%2 == 1 {
+				chunk = slowChunk{r: strings.NewReader(strings.Repeat("x", len(content))), fail: true}
+			}
+			_, err := s.WriteUploadChunk(upload.Id, 0, chunk)
+			switch {
+			case err == nil:
+				mu.Lock()
+				accepted++
+				mu.Unlock()
+			case !errors.Is(err, storage.ErrOffsetMismatch) && !errors.Is(err, digest.ErrMismatch):
+				t.Errorf("WriteUploadChunk: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if accepted != 1 {
+		t.Fatalf("%d of the concurrent chunks were accepted, want 1", accepted)
+	}
+	upload, err = s.GetUpload(upload.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !upload.Complete() || upload.FileId == 0 {
+		t.Fatalf("upload after the concurrent chunks = %+v", upload)
+	}
+	_, rc, err := s.OpenFile(int64(upload.FileId))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != content {
+		t.Errorf("stored file differs from the chunk sent: %d bytes, digest %s", len(data), digestOf(string(data)))
+	}
+}
+
+func testUploadNotFound(t *testing.T, s storage.Storage) {
+	if _, err := s.GetUpload("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("GetUpload: got %v, want sql.ErrNoRows", err)
+	}
+	if _, err := s.WriteUploadChunk("missing", 0, strings.NewReader("x")); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("WriteUploadChunk: got %v, want sql.ErrNoRows", err)
+	}
+	if err := s.DeleteUpload("missing"); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("DeleteUpload: got %v, want sql.ErrNoRows", err)
+	}
+}
+
+func testExpiredUploads(t *testing.T, s storage.Storage) {
+	upload, err := s.CreateUpload(0, "big.bin", "application/octet-stream", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, err := s.ExpiredUploads(time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("fresh upload reported as expired: %+v", expired)
+	}
+	expired, err = s.ExpiredUploads(time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].Id != upload.Id {
+		t.Errorf("ExpiredUploads = %+v, want upload %s", expired, upload.Id)
+	}
+}
@@ -0,0 +1,176 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"strconv"
+
+	"github.com/surajNirala/student-api/internal/storage/filestore"
+)
+
+// Transactor is implemented by backends that can group the operations of
+// several calls into one transaction.
+type Transactor interface {
+	// InTx calls fn with a context under which every storage returned by
+	// WithContext shares one transaction. It commits when fn returns nil
+	// and rolls back otherwise.
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Querier runs statements; *sql.DB, *sql.Tx and *Tx all implement it.
+type Querier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Prepare(query string) (*sql.Stmt, error)
+}
+
+type txKey struct{}
+
+// sharedTx is the transaction of an InTx call.
+type sharedTx struct {
+	tx          *sql.Tx
+	savepoints  int
+	afterCommit []func()
+}
+
+// RunInTx implements Transactor for the SQL backends. An InTx nested in
+// another joins the outer transaction.
+func RunInTx(ctx context.Context, db *sql.DB, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sharedTx); ok {
+		return fn(ctx)
+	}
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	shared := &sharedTx{tx: tx}
+	if err := fn(context.WithValue(ctx, txKey{}, shared)); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	for _, f := range shared.afterCommit {
+		f()
+	}
+	return nil
+}
+
+// InTransaction reports whether ctx carries the transaction of an InTx call.
+func InTransaction(ctx context.Context) bool {
+	_, ok := ctx.Value(txKey{}).(*sharedTx)
+	return ok
+}
+
+// AfterCommit arranges for f to run once the transaction carried by ctx
+// has committed. It reports false, and does nothing, when ctx carries no
+// transaction.
+func AfterCommit(ctx context.Context, f func()) bool {
+	shared, ok := ctx.Value(txKey{}).(*sharedTx)
+	if ok {
+		shared.afterCommit = append(shared.afterCommit, f)
+	}
+	return ok
+}
+
+// RemoveFile removes a stored file whose record an operation made with ctx
+// deleted. Under InTx that waits until the transaction commits, since a
+// rollback brings the record back. A deferred removal that fails leaves a
+// stray file for the garbage collector.
+func RemoveFile(ctx context.Context, filePath string) error {
+	deferred := AfterCommit(ctx, func() {
+		if err := filestore.Remove(filePath); err != nil {
+			slog.Error("Removing file failed", slog.String("path", filePath), slog.String("error", err.Error()))
+		}
+	})
+	if deferred {
+		return nil
+	}
+	return filestore.Remove(filePath)
+}
+
+// RemoveBlob is RemoveFile for content that s no longer refers to. A file
+// may take the content up again before a deferred removal runs, so the
+// removal checks s for it under the blob's lock first.
+func RemoveBlob(ctx context.Context, s Storage, sum string, blobPath string) error {
+	deferred := AfterCommit(ctx, func() {
+		unlock := filestore.LockBlob(sum)
+		defer unlock()
+		// The transaction is over, so read what it committed
+		stored, err := s.WithContext(context.Background()).HasBlob(sum)
+		if err == nil && !stored {
+			err = filestore.Remove(blobPath)
+		}
+		if err != nil {
+			slog.Error("Removing file failed", slog.String("path", blobPath), slog.String("error", err.Error()))
+		}
+	})
+	if deferred {
+		return nil
+	}
+	return filestore.Remove(blobPath)
+}
+
+// Conn returns what an operation runs its statements on outside a
+// transaction of its own: the transaction carried by ctx, or else db.
+func Conn(ctx context.Context, db *sql.DB) Querier {
+	if shared, ok := ctx.Value(txKey{}).(*sharedTx); ok {
+		return shared.tx
+	}
+	return db
+}
+
+// Tx is the transaction of a single backend operation. Under InTx it is a
+// savepoint in the shared transaction instead, so a failed operation
+// still undoes its own changes while successful ones wait for the shared
+// transaction to commit.
+type Tx struct {
+	*sql.Tx
+	savepoint string
+	done      bool
+}
+
+// Begin starts the transaction of an operation made with ctx.
+func Begin(ctx context.Context, db *sql.DB) (*Tx, error) {
+	shared, ok := ctx.Value(txKey{}).(*sharedTx)
+	if !ok {
+		tx, err := db.Begin()
+		if err != nil {
+			return nil, err
+		}
+		return &Tx{Tx: tx}, nil
+	}
+	shared.savepoints++
+	savepoint := "sp" + strconv.Itoa(shared.savepoints)
+	if _, err := shared.tx.Exec("SAVEPOINT " + savepoint); err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: shared.tx, savepoint: savepoint}, nil
+}
+
+func (tx *Tx) Commit() error {
+	if tx.savepoint == "" {
+		return tx.Tx.Commit()
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	_, err := tx.Exec("RELEASE SAVEPOINT " + tx.savepoint)
+	return err
+}
+
+func (tx *Tx) Rollback() error {
+	if tx.savepoint == "" {
+		return tx.Tx.Rollback()
+	}
+	if tx.done {
+		return sql.ErrTxDone
+	}
+	tx.done = true
+	_, err := tx.Exec("ROLLBACK TO SAVEPOINT " + tx.savepoint)
+	return err
+}
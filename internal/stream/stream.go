@@ -0,0 +1,226 @@
+// Package stream follows the outbox and fans student events out to
+// connected clients. The most recent events are kept in memory, so a
+// client that reconnects can usually resume without touching the
+// database.
+package stream
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+)
+
+// gapWait is how long the broker waits for an event missing from the id
+// sequence. Ids are taken when a transaction writes its event but show up
+// when it commits, so under concurrent writes a later id can be seen
+// first; a gap that lasts longer belongs to a transaction that rolled back.
+const gapWait = 5 * time.Second
+
+// subscriberBuffer is how far a client may fall behind before it is
+// dropped; it reconnects and resumes from the last event it received.
+const subscriberBuffer = 64
+
+// ErrClosed is returned by Subscribe once the broker has stopped.
+var ErrClosed = errors.New("event stream is closed")
+
+type Broker struct {
+	storage  storage.Storage
+	cfg      config.Events
+	mu       sync.Mutex
+	ready    bool
+	closed   bool
+	lastID   uint64
+	gapSince time.Time
+	// recent holds the last cfg.BufferSize events, oldest first
+	recent []models.Event
+	subs   map[*Subscription]struct{}
+}
+
+// Subscription receives every event after the one it was started from.
+// C is closed when the client falls too far behind or the broker stops.
+type Subscription struct {
+	C      <-chan models.Event
+	c      chan models.Event
+	after  uint64
+	broker *Broker
+}
+
+func New(storage storage.Storage, cfg config.Events) *Broker {
+	return &Broker{storage: storage, cfg: cfg, subs: make(map[*Subscription]struct{})}
+}
+
+// Start follows the outbox every cfg.PollInterval until ctx is done, then
+// ends all subscriptions.
+func (b *Broker) Start(ctx context.Context) {
+	defer b.close()
+	ticker := time.NewTicker(b.cfg.PollInterval)
+	defer ticker.Stop()
+	for {
+		b.poll()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Last returns the id of the newest event the broker has seen.
+func (b *Broker) Last() (uint64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.init(); err != nil {
+		return 0, err
+	}
+	return b.lastID, nil
+}
+
+// Subscribe starts a subscription to the events after id and returns
+// those already buffered, to be sent first. ok is false, and nothing is
+// subscribed, when some of them are no longer buffered; read those from
+// storage and subscribe again from the last one read.
+func (b *Broker) Subscribe(id uint64) (sub *Subscription, backlog []models.Event, ok bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, false, ErrClosed
+	}
+	if err := b.init(); err != nil {
+		return nil, nil, false, err
+	}
+	if id < b.lastID {
+		oldest := b.lastID
+		if len(b.recent) > 0 {
+			oldest = b.recent[0].Id - 1
+		}
+		if id < oldest {
+			return nil, nil, false, nil
+		}
+	}
+	sub, backlog = b.subscribe(id)
+	return sub, backlog, true, nil
+}
+
+// Follow is Subscribe for a client that has already read everything
+// storage holds after id. It subscribes even when the buffer does not
+// reach back to id, as no more of the events in between can be read.
+func (b *Broker) Follow(id uint64) (*Subscription, []models.Event, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, ErrClosed
+	}
+	if err := b.init(); err != nil {
+		return nil, nil, err
+	}
+	sub, backlog := b.subscribe(id)
+	return sub, backlog, nil
+}
+
+// subscribe must be called with b.mu held.
+func (b *Broker) subscribe(id uint64) (*Subscription, []models.Event) {
+	var backlog []models.Event
+	for _, event := range b.recent {
+		if event.Id > id {
+			backlog = append(backlog, event)
+		}
+	}
+	c := make(chan models.Event, subscriberBuffer)
+	sub := &Subscription{C: c, c: c, after: id, broker: b}
+	b.subs[sub] = struct{}{}
+	return sub, backlog
+}
+
+// Close ends the subscription.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.drop(s)
+}
+
+// init starts the broker at the newest event in the outbox; it must be
+// called with b.mu held.
+func (b *Broker) init() error {
+	if b.ready {
+		return nil
+	}
+	id, err := b.storage.LastEventID()
+	if err != nil {
+		return err
+	}
+	b.lastID, b.ready = id, true
+	return nil
+}
+
+func (b *Broker) poll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err := b.init(); err != nil {
+		slog.Error("Reading the event log failed", slog.String("error", err.Error()))
+		return
+	}
+	for {
+		events, err := b.storage.EventsAfter(b.lastID, b.cfg.BufferSize)
+		if err != nil {
+			slog.Error("Reading the event log failed", slog.String("error", err.Error()))
+			return
+		}
+		for _, event := range events {
+			if event.Id != b.lastID+1 {
+				if b.gapSince.IsZero() {
+					b.gapSince = time.Now()
+				}
+				if time.Since(b.gapSince) < gapWait {
+					return
+				}
+			}
+			b.gapSince = time.Time{}
+			b.publish(event)
+		}
+		if len(events) < b.cfg.BufferSize {
+			return
+		}
+	}
+}
+
+// publish must be called with b.mu held.
+func (b *Broker) publish(event models.Event) {
+	b.lastID = event.Id
+	b.recent = append(b.recent, event)
+	if len(b.recent) > b.cfg.BufferSize {
+		b.recent = b.recent[len(b.recent)-b.cfg.BufferSize:]
+	}
+	for sub := range b.subs {
+		if event.Id <= sub.after {
+			continue
+		}
+		select {
+		case sub.c <- event:
+		default:
+			b.drop(sub)
+		}
+	}
+}
+
+// drop must be called with b.mu held.
+func (b *Broker) drop(sub *Subscription) {
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.c)
+	}
+}
+
+func (b *Broker) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.drop(sub)
+	}
+}
@@ -0,0 +1,154 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+)
+
+func newBroker(t *testing.T, bufferSize int) (*Broker, storage.Storage) {
+	t.Helper()
+	store := memory.New()
+	return New(store, config.Events{PollInterval: time.Hour, BufferSize: bufferSize}), store
+}
+
+func createStudents(t *testing.T, store storage.Storage, n int) {
+	t.Helper()
+	for range n {
+		if _, err := store.CreateStudent("Asha", "asha@example.com", 20); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func ids(events []models.Event) []uint64 {
+	var list []uint64
+	for _, event := range events {
+		list = append(list, event.Id)
+	}
+	return list
+}
+
+func receive(t *testing.T, sub *Subscription, n int) []models.Event {
+	t.Helper()
+	var events []models.Event
+	for range n {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				t.Fatalf("subscription closed after %d events", len(events))
+			}
+			events = append(events, event)
+		case <-time.After(time.Second):
+			t.Fatalf("received %d events, want %d", len(events), n)
+		}
+	}
+	return events
+}
+
+func TestStartsAtNewestEvent(t *testing.T) {
+	b, store := newBroker(t, 10)
+	createStudents(t, store, 2)
+	last, err := b.Last()
+	if err != nil || last != 2 {
+		t.Fatalf("Last() = %d, %v, want 2", last, err)
+	}
+	sub, backlog, ok, err := b.Subscribe(last)
+	if err != nil || !ok || len(backlog) != 0 {
+		t.Fatalf("Subscribe(%d) = %v, %v, %v", last, backlog, ok, err)
+	}
+	defer sub.Close()
+
+	createStudents(t, store, 1)
+	b.poll()
+	if got := ids(receive(t, sub, 1)); got[0] != 3 {
+		t.Errorf("received event %v, want 3", got)
+	}
+}
+
+func TestResume(t *testing.T) {
+	b, store := newBroker(t, 3)
+	b.poll()
+	createStudents(t, store, 5)
+	b.poll()
+
+	// Events 3 to 5 are buffered
+	sub, backlog, ok, err := b.Subscribe(2)
+	if err != nil || !ok {
+		t.Fatalf("Subscribe(2) = %v, %v", ok, err)
+	}
+	sub.Close()
+	if got := ids(backlog); len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Errorf("backlog %v, want 3 to 5", got)
+	}
+	// Event 2 has left the buffer and must be read from storage
+	if _, _, ok, err := b.Subscribe(1); ok || err != nil {
+		t.Errorf("Subscribe(1) = %v, %v, want not ok", ok, err)
+	}
+}
+
+func TestFollow(t *testing.T) {
+	b, store := newBroker(t, 3)
+	b.poll()
+	createStudents(t, store, 5)
+	b.poll()
+
+	// Events 3 to 5 are buffered; what came before is not waited for
+	sub, backlog, err := b.Follow(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if got := ids(backlog); len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Errorf("backlog %v, want 3 to 5", got)
+	}
+	createStudents(t, store, 1)
+	b.poll()
+	if got := ids(receive(t, sub, 1)); got[0] != 6 {
+		t.Errorf("received event %v, want 6", got)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b, store := newBroker(t, 1000)
+	b.poll()
+	sub, _, _, err := b.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	createStudents(t, store, subscriberBuffer+1)
+	b.poll()
+	receive(t, sub, subscriberBuffer)
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription still open after falling behind")
+	}
+	sub.Close()
+}
+
+func TestStartStops(t *testing.T) {
+	b, _ := newBroker(t, 10)
+	sub, _, _, err := b.Subscribe(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Start(ctx)
+		close(done)
+	}()
+	cancel()
+	<-done
+	if _, ok := <-sub.C; ok {
+		t.Error("subscription still open after the broker stopped")
+	}
+	if _, _, _, err := b.Subscribe(0); !errors.Is(err, ErrClosed) {
+		t.Errorf("Subscribe after stop: got %v, want ErrClosed", err)
+	}
+}
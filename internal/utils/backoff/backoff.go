@@ -0,0 +1,95 @@
+// Package backoff retries operations with exponentially growing, jittered
+// delays.
+package backoff
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+)
+
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+}
+
+func New(cfg config.Retry) Backoff {
+	return Backoff{Initial: cfg.InitialInterval, Max: cfg.MaxInterval}
+}
+
+// Delay returns how long to wait before retry number attempt, counting
+// from zero. The delay doubles with every attempt up to Max and is then
+// drawn at random from its upper half, so clients that failed together
+// do not all retry together.
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := b.Initial
+	for range attempt {
+		if delay >= b.Max/2 {
+			delay = b.Max
+			break
+		}
+		delay *= 2
+	}
+	delay = min(delay, b.Max)
+	if delay <= 0 {
+		return 0
+	}
+	half := delay / 2
+	return half + rand.N(delay-half+1)
+}
+
+// Retry calls op up to attempts times while it fails with an error
+// retryable accepts. It returns op's last error, or ctx's if ctx ends
+// first.
+func Retry(ctx context.Context, b Backoff, attempts int, retryable func(error) bool, op func() error) error {
+	var err error
+	for attempt := range attempts {
+		if err = op(); err == nil || !retryable(err) {
+			return err
+		}
+		if attempt == attempts-1 {
+			break
+		}
+		if sleepErr := sleep(ctx, b.Delay(attempt)); sleepErr != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// Until calls op until it succeeds or maxWait has passed, logging each
+// failure as what. It is meant for waiting on a dependency at startup.
+func Until(ctx context.Context, b Backoff, maxWait time.Duration, what string, op func() error) error {
+	start := time.Now()
+	deadline := start.Add(maxWait)
+	for attempt := 0; ; attempt++ {
+		err := op()
+		if err == nil {
+			return nil
+		}
+		// Make a last attempt at the deadline rather than sleeping past it
+		delay := min(b.Delay(attempt), time.Until(deadline))
+		if delay <= 0 {
+			return fmt.Errorf("%s: gave up after %s: %w", what, time.Since(start).Round(time.Millisecond), err)
+		}
+		slog.Warn("Waiting for "+what, slog.String("error", err.Error()), slog.Duration("retry_in", delay))
+		if err := sleep(ctx, delay); err != nil {
+			return fmt.Errorf("%s: %w", what, err)
+		}
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
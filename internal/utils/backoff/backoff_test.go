@@ -0,0 +1,114 @@
+package backoff
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second}
+	tests := []struct {
+		attempt int
+		ceiling time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{2, 400 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{4, time.Second},
+		{50, time.Second},
+	}
+	for _, tt := range tests {
+		// The jitter keeps every delay in the upper half of its ceiling
+		for range 100 {
+			if got := b.Delay(tt.attempt); got < tt.ceiling/2 || got > tt.ceiling {
+				t.Fatalf("Delay(%d) = %s, want between %s and %s", tt.attempt, got, tt.ceiling/2, tt.ceiling)
+			}
+		}
+	}
+	if got := (Backoff{}).Delay(3); got != 0 {
+		t.Errorf("Delay without intervals = %s, want 0", got)
+	}
+}
+
+var (
+	errTransient = errors.New("transient")
+	errPermanent = errors.New("permanent")
+)
+
+func isTransient(err error) bool {
+	return errors.Is(err, errTransient)
+}
+
+func TestRetry(t *testing.T) {
+	b := Backoff{Initial: time.Millisecond, Max: time.Millisecond}
+	tests := []struct {
+		name     string
+		errs     []error
+		attempts int
+		calls    int
+		want     error
+	}{
+		{"success", []error{nil}, 3, 1, nil},
+		{"recovers", []error{errTransient, errTransient, nil}, 3, 3, nil},
+		{"gives up", []error{errTransient, errTransient, errTransient, nil}, 3, 3, errTransient},
+		{"permanent", []error{errPermanent, nil}, 3, 1, errPermanent},
+		{"single attempt", []error{errTransient, nil}, 1, 1, errTransient},
+	}
+	for _, tt := range tests {
+		calls := 0
+		err := Retry(context.Background(), b, tt.attempts, isTransient, func() error {
+			calls++
+			return tt.errs[calls-1]
+		})
+		if !errors.Is(err, tt.want) || calls != tt.calls {
+			t.Errorf("%s: Retry = %v after %d calls, want %v after %d", tt.name, err, calls, tt.want, tt.calls)
+		}
+	}
+
+	// A cancelled context ends the wait with the operation's error
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	calls := 0
+	err := Retry(ctx, Backoff{Initial: time.Hour, Max: time.Hour}, 3, isTransient, func() error {
+		calls++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) || calls != 1 {
+		t.Errorf("cancelled: Retry = %v after %d calls", err, calls)
+	}
+}
+
+func TestUntil(t *testing.T) {
+	b := Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond}
+
+	calls := 0
+	err := Until(context.Background(), b, time.Second, "database", func() error {
+		if calls++; calls < 3 {
+			return errTransient
+		}
+		return nil
+	})
+	if err != nil || calls != 3 {
+		t.Errorf("Until = %v after %d calls, want success after 3", err, calls)
+	}
+
+	start := time.Now()
+	err = Until(context.Background(), b, 30*time.Millisecond, "database", func() error { return errPermanent })
+	if !errors.Is(err, errPermanent) || !strings.HasPrefix(err.Error(), "database: gave up after") {
+		t.Errorf("Until = %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 30*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Until gave up after %s, want about 30ms", elapsed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = Until(ctx, Backoff{Initial: time.Hour, Max: time.Hour}, time.Hour, "database", func() error { return errTransient })
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("cancelled: Until = %v", err)
+	}
+}
@@ -0,0 +1,87 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrMismatch is returned by a verifying reader whose content does not
+// hash to the digest the client announced.
+var ErrMismatch = errors.New("content digest mismatch")
+
+// FromHeader returns the SHA-256 digest announced in a Content-Digest
+// (RFC 9530) or Digest (RFC 3230) header, or nil when neither has one.
+// Digests using other algorithms are ignored.
+func FromHeader(h http.Header) ([]byte, error) {
+	if value := h.Get("Content-Digest"); value != "" {
+		// Structured field dictionary: sha-256=:<base64>:, sha-512=:<base64>:
+		for _, member := range strings.Split(value, ",") {
+			key, encoded, _ := strings.Cut(strings.TrimSpace(member), "=")
+			if key != "sha-256" {
+				continue
+			}
+			encoded, ok := strings.CutPrefix(encoded, ":")
+			if ok {
+				encoded, ok = strings.CutSuffix(encoded, ":")
+			}
+			if !ok {
+				return nil, fmt.Errorf("invalid Content-Digest header")
+			}
+			return decode(encoded)
+		}
+	}
+	if value := h.Get("Digest"); value != "" {
+		// Legacy form: SHA-256=<base64>, MD5=<base64>
+		for _, member := range strings.Split(value, ",") {
+			key, encoded, _ := strings.Cut(strings.TrimSpace(member), "=")
+			if strings.EqualFold(key, "sha-256") {
+				return decode(encoded)
+			}
+		}
+	}
+	return nil, nil
+}
+
+// Header formats a SHA-256 sum as a Content-Digest/Repr-Digest value.
+func Header(sum []byte) string {
+	return "sha-256=:" + base64.StdEncoding.EncodeToString(sum) + ":"
+}
+
+func decode(encoded string) ([]byte, error) {
+	sum, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sum) != sha256.Size {
+		return nil, fmt.Errorf("invalid sha-256 digest")
+	}
+	return sum, nil
+}
+
+// Verify wraps r so that reaching the end of it fails with ErrMismatch
+// unless the content read hashes to want. A nil want disables the check.
+func Verify(r io.Reader, want []byte) io.Reader {
+	if want == nil {
+		return r
+	}
+	return &verifier{r: r, h: sha256.New(), want: want}
+}
+
+type verifier struct {
+	r    io.Reader
+	h    hash.Hash
+	want []byte
+}
+
+func (v *verifier) Read(p []byte) (int, error) {
+	n, err := v.r.Read(p)
+	v.h.Write(p[:n])
+	if err == io.EOF && !bytes.Equal(v.h.Sum(nil), v.want) {
+		return n, ErrMismatch
+	}
+	return n, err
+}
@@ -0,0 +1,65 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestFromHeader(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello"))
+	encoded := base64.StdEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   []byte
+		ok     bool
+	}{
+		{"none", http.Header{}, nil, true},
+		{"content-digest", http.Header{"Content-Digest": {"sha-256=:" + encoded + ":"}}, sum[:], true},
+		{"content-digest among others", http.Header{"Content-Digest": {"sha-512=:AAAA:, sha-256=:" + encoded + ":"}}, sum[:], true},
+		{"content-digest of another algorithm", http.Header{"Content-Digest": {"sha-512=:AAAA:"}}, nil, true},
+		{"content-digest without colons", http.Header{"Content-Digest": {"sha-256=" + encoded}}, nil, false},
+		{"legacy digest", http.Header{"Digest": {"MD5=AAAA, SHA-256=" + encoded}}, sum[:], true},
+		{"short sum", http.Header{"Digest": {"SHA-256=AAAA"}}, nil, false},
+		{"not base64", http.Header{"Digest": {"SHA-256=!!"}}, nil, false},
+	}
+	for _, tt := range tests {
+		got, err := FromHeader(tt.header)
+		if (err == nil) != tt.ok || !bytes.Equal(got, tt.want) {
+			t.Errorf("%s: FromHeader = %x, %v", tt.name, got, err)
+		}
+	}
+
+	// Header and FromHeader agree
+	got, err := FromHeader(http.Header{"Content-Digest": {Header(sum[:])}})
+	if err != nil || !bytes.Equal(got, sum[:]) {
+		t.Errorf("FromHeader(Header(sum)) = %x, %v", got, err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	sum := sha256.Sum256([]byte("hello"))
+	tests := []struct {
+		name    string
+		content string
+		want    []byte
+		err     error
+	}{
+		{"match", "hello", sum[:], nil},
+		{"mismatch", "hellO", sum[:], ErrMismatch},
+		{"no digest", "anything", nil, nil},
+	}
+	for _, tt := range tests {
+		got, err := io.ReadAll(Verify(strings.NewReader(tt.content), tt.want))
+		if !errors.Is(err, tt.err) || string(got) != tt.content {
+			t.Errorf("%s: read %q, %v; want error %v", tt.name, got, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package filetype
+
+import (
+	"bytes"
+	"io"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// sniffLen is how many leading bytes are inspected, matching mimetype's default read limit.
+const sniffLen = 3072
+
+// Detect sniffs the content type of r from its leading bytes. The returned
+// reader still yields the complete stream, including the sniffed bytes.
+func Detect(r io.Reader) (*mimetype.MIME, io.Reader, error) {
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	head = head[:n]
+	return mimetype.Detect(head), io.MultiReader(bytes.NewReader(head), r), nil
+}
+
+// Allowed reports whether mime matches one of the allowed types. Entries
+// may be exact types ("application/pdf") or wildcards ("image/*"). An
+// empty list allows every type.
+func Allowed(mime *mimetype.MIME, allowed []string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, pattern := range allowed {
+		if prefix, ok := strings.CutSuffix(pattern, "/*"); ok {
+			if strings.HasPrefix(mime.String(), prefix+"/") {
+				return true
+			}
+			continue
+		}
+		if mime.Is(pattern) {
+			return true
+		}
+	}
+	return false
+}
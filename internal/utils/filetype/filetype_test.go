@@ -0,0 +1,65 @@
+package filetype
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name    string
+		content []byte
+		want    string
+	}{
+		{"png", append(pngHeader, make([]byte, 10)...), "image/png"},
+		{"pdf", []byte("%PDF-1.7\n"), "application/pdf"},
+		{"text", []byte("plain words"), "text/plain; charset=utf-8"},
+		{"empty", nil, "text/plain"},
+		{"longer than the sniffed prefix", bytes.Repeat([]byte("a"), 2*sniffLen), "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mime, r, err := Detect(bytes.NewReader(tt.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if mime.String() != tt.want {
+				t.Errorf("Detect = %s, want %s", mime, tt.want)
+			}
+			// The sniffed bytes must not be lost
+			got, err := io.ReadAll(r)
+			if err != nil || !bytes.Equal(got, tt.content) {
+				t.Errorf("stream = %d bytes, %v; want %d bytes", len(got), err, len(tt.content))
+			}
+		})
+	}
+}
+
+func TestAllowed(t *testing.T) {
+	png, _, _ := Detect(bytes.NewReader(pngHeader))
+	pdf, _, _ := Detect(strings.NewReader("%PDF-1.7\n"))
+	tests := []struct {
+		name    string
+		allowed []string
+		png     bool
+		pdf     bool
+	}{
+		{"no list", nil, true, true},
+		{"exact", []string{"application/pdf"}, false, true},
+		{"wildcard", []string{"image/*"}, true, false},
+		{"wildcard is not a prefix match", []string{"imag/*"}, false, false},
+		{"several", []string{"image/jpeg", "image/png", "application/pdf"}, true, true},
+	}
+	for _, tt := range tests {
+		if got := Allowed(png, tt.allowed); got != tt.png {
+			t.Errorf("%s: Allowed(png) = %v", tt.name, got)
+		}
+		if got := Allowed(pdf, tt.allowed); got != tt.pdf {
+			t.Errorf("%s: Allowed(pdf) = %v", tt.name, got)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package response
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// ProblemType is the media type of Problem bodies (RFC 9457).
+const ProblemType = "application/problem+json"
+
+// Problem describes why a request failed in the RFC 9457 format. Errors
+// lists each invalid part of the request.
+type Problem struct {
+	Type     string         `json:"type"`
+	Title    string         `json:"title"`
+	Status   int            `json:"status"`
+	Detail   string         `json:"detail,omitempty"`
+	Instance string         `json:"instance,omitempty"`
+	Errors   []ProblemError `json:"errors,omitempty"`
+}
+
+// ProblemError is one invalid value. In names where it is, such as
+// "path", "query" or "body", and Pointer, a JSON pointer for bodies or the
+// parameter name otherwise, which one.
+type ProblemError struct {
+	In      string `json:"in"`
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
+// NewProblem starts a problem of the given status with its standard title.
+func NewProblem(status int, detail string) Problem {
+	return Problem{Type: "about:blank", Title: http.StatusText(status), Status: status, Detail: detail}
+}
+
+func WriteProblem(w http.ResponseWriter, problem Problem) error {
+	w.Header().Set("Content-Type", ProblemType)
+	w.WriteHeader(problem.Status)
+	return json.NewEncoder(w).Encode(problem)
+}
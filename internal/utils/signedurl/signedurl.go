@@ -0,0 +1,79 @@
+package signedurl
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+)
+
+var (
+	ErrNotConfigured    = errors.New("signed links are not configured")
+	ErrInvalidSignature = errors.New("invalid link signature")
+	ErrExpired          = errors.New("link has expired")
+)
+
+// Signer issues and checks HMAC-SHA256 signatures for file links. Every
+// link names the key that signed it, so rotating the active key keeps old
+// links valid until their key is removed from the config, which revokes
+// all of them at once.
+type Signer struct {
+	active string
+	keys   map[string][]byte
+}
+
+func New(cfg config.Signing) *Signer {
+	keys := make(map[string][]byte, len(cfg.Keys))
+	for id, secret := range cfg.Keys {
+		keys[id] = []byte(secret)
+	}
+	return &Signer{active: cfg.ActiveKey, keys: keys}
+}
+
+// Sign returns the query parameters that authorise downloading fileID until expires.
+func (s *Signer) Sign(fileID int64, expires time.Time) (url.Values, error) {
+	key, ok := s.keys[s.active]
+	if !ok {
+		return nil, ErrNotConfigured
+	}
+	exp := strconv.FormatInt(expires.Unix(), 10)
+	query := url.Values{}
+	query.Set("expires", exp)
+	query.Set("kid", s.active)
+	query.Set("sig", signature(key, fileID, exp, s.active))
+	return query, nil
+}
+
+// Verify checks that query carries a valid, unexpired signature for fileID.
+func (s *Signer) Verify(fileID int64, query url.Values, now time.Time) error {
+	kid := query.Get("kid")
+	key, ok := s.keys[kid]
+	if !ok {
+		return ErrInvalidSignature
+	}
+	exp := query.Get("expires")
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	want := signature(key, fileID, exp, kid)
+	if !hmac.Equal([]byte(query.Get("sig")), []byte(want)) {
+		return ErrInvalidSignature
+	}
+	if now.Unix() > expires {
+		return ErrExpired
+	}
+	return nil
+}
+
+func signature(key []byte, fileID int64, expires string, kid string) string {
+	mac := hmac.New(sha256.New, key)
+	fmt.Fprintf(mac, "file:%d:%s:%s", fileID, expires, kid)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,83 @@
+package signedurl
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+)
+
+var now = time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)
+
+func TestVerify(t *testing.T) {
+	old := New(config.Signing{ActiveKey: "k1", Keys: map[string]string{"k1": "first secret"}})
+	// k2 took over from k1, whose links stay valid
+	rotated := New(config.Signing{ActiveKey: "k2", Keys: map[string]string{"k1": "first secret", "k2": "second secret"}})
+	// Removing k1 revokes every link it signed
+	revoked := New(config.Signing{ActiveKey: "k2", Keys: map[string]string{"k2": "second secret"}})
+	// A key id reused with another secret does not accept old signatures
+	replaced := New(config.Signing{ActiveKey: "k1", Keys: map[string]string{"k1": "new secret"}})
+
+	link, err := old.Sign(7, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := func(name, value string) url.Values {
+		q := url.Values{}
+		for k, v := range link {
+			q[k] = v
+		}
+		q.Set(name, value)
+		return q
+	}
+
+	tests := []struct {
+		name   string
+		signer *Signer
+		fileID int64
+		query  url.Values
+		at     time.Time
+		want   error
+	}{
+		{"valid", old, 7, link, now, nil},
+		{"at expiry", old, 7, link, now.Add(time.Hour), nil},
+		{"expired", old, 7, link, now.Add(time.Hour + time.Second), ErrExpired},
+		{"other file", old, 8, link, now, ErrInvalidSignature},
+		{"extended expiry", old, 7, tampered("expires", "9999999999"), now, ErrInvalidSignature},
+		{"bad expiry", old, 7, tampered("expires", "soon"), now, ErrInvalidSignature},
+		{"bad signature", old, 7, tampered("sig", "AAAA"), now, ErrInvalidSignature},
+		{"unknown key", old, 7, tampered("kid", "k9"), now, ErrInvalidSignature},
+		{"no query", old, 7, url.Values{}, now, ErrInvalidSignature},
+		{"after rotation", rotated, 7, link, now, nil},
+		{"key removed", revoked, 7, link, now, ErrInvalidSignature},
+		{"secret replaced", replaced, 7, link, now, ErrInvalidSignature},
+	}
+	for _, tt := range tests {
+		if err := tt.signer.Verify(tt.fileID, tt.query, tt.at); !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+
+	// New links use the active key
+	link, err = rotated.Sign(7, now.Add(time.Hour))
+	if err != nil || link.Get("kid") != "k2" {
+		t.Fatalf("Sign = %v, %v", link, err)
+	}
+	if err := revoked.Verify(7, link, now); err != nil {
+		t.Errorf("link of the active key after revoking k1: %v", err)
+	}
+}
+
+func TestNotConfigured(t *testing.T) {
+	for _, cfg := range []config.Signing{
+		{},
+		{ActiveKey: "k1"},
+		{ActiveKey: "k1", Keys: map[string]string{"k2": "secret"}},
+	} {
+		if _, err := New(cfg).Sign(1, now); !errors.Is(err, ErrNotConfigured) {
+			t.Errorf("Sign with %+v = %v, want ErrNotConfigured", cfg, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package thumbnail
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func fill(img *image.RGBA, r image.Rectangle, c color.RGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetRGBA(x, y, c)
+		}
+	}
+}
+
+var (
+	red   = color.RGBA{255, 0, 0, 255}
+	green = color.RGBA{0, 255, 0, 255}
+	blue  = color.RGBA{0, 0, 255, 255}
+)
+
+func TestSquareCropsToTheCentre(t *testing.T) {
+	// Three 100×100 panels side by side; only the middle one survives
+	img := image.NewRGBA(image.Rect(0, 0, 300, 100))
+	fill(img, image.Rect(0, 0, 100, 100), red)
+	fill(img, image.Rect(100, 0, 200, 100), green)
+	fill(img, image.Rect(200, 0, 300, 100), blue)
+
+	thumb := Square(img, 10)
+	if thumb.Bounds() != image.Rect(0, 0, 10, 10) {
+		t.Fatalf("bounds = %v", thumb.Bounds())
+	}
+	for y := range 10 {
+		for x := range 10 {
+			if got := thumb.RGBAAt(x, y); got != green {
+				t.Fatalf("pixel (%d,%d) = %v, want %v", x, y, got, green)
+			}
+		}
+	}
+}
+
+func TestSquareAverages(t *testing.T) {
+	// A 2×2 checkerboard of black and white shrinks to a single grey pixel
+	img := image.NewRGBA(image.Rect(0, 0, 2, 2))
+	fill(img, img.Bounds(), color.RGBA{0, 0, 0, 255})
+	img.SetRGBA(0, 0, color.RGBA{255, 255, 255, 255})
+	img.SetRGBA(1, 1, color.RGBA{255, 255, 255, 255})
+
+	if got, want := Square(img, 1).RGBAAt(0, 0), (color.RGBA{127, 127, 127, 255}); got != want {
+		t.Errorf("pixel = %v, want %v", got, want)
+	}
+}
+
+func TestSquareDoesNotEnlarge(t *testing.T) {
+	img := image.NewRGBA(image.Rect(10, 20, 70, 100))
+	if got := Square(img, 128).Bounds(); got != image.Rect(0, 0, 60, 60) {
+		t.Errorf("bounds = %v, want 60×60", got)
+	}
+}
@@ -0,0 +1,174 @@
+// Package webhook delivers student events from the outbox to the URLs
+// subscribed to them. Delivery is at least once: a receiver can see an
+// event again after a timeout or a restart and should use the delivery id
+// header to ignore repeats.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/utils/backoff"
+)
+
+// Headers sent with every delivery. The signature is "sha256=" followed
+// by the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with
+// the subscription's secret.
+const (
+	EventHeader     = "X-Webhook-Event"
+	DeliveryHeader  = "X-Webhook-Delivery"
+	TimestampHeader = "X-Webhook-Timestamp"
+	SignatureHeader = "X-Webhook-Signature"
+)
+
+// maxErrorLength keeps the last error of a delivery within its column.
+const maxErrorLength = 1000
+
+type Dispatcher struct {
+	storage storage.Storage
+	cfg     config.Webhooks
+	client  *http.Client
+	backoff backoff.Backoff
+	mu      sync.Mutex
+}
+
+func New(storage storage.Storage, cfg config.Webhooks) *Dispatcher {
+	return &Dispatcher{
+		storage: storage,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		backoff: backoff.Backoff{Initial: cfg.InitialInterval, Max: cfg.MaxInterval},
+	}
+}
+
+// Start delivers events every cfg.Interval until ctx is done.
+func (d *Dispatcher) Start(ctx context.Context) {
+	if d.cfg.Disabled {
+		return
+	}
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			d.Run(ctx)
+		}
+	}
+}
+
+// Run hands new events to their subscriptions and sends the deliveries
+// that are due, all at once. Runs never overlap.
+func (d *Dispatcher) Run(ctx context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for {
+		n, err := d.storage.DispatchEvents(d.cfg.BatchSize)
+		if err != nil {
+			slog.Error("Dispatching events failed", slog.String("error", err.Error()))
+			break
+		}
+		if n < d.cfg.BatchSize {
+			break
+		}
+	}
+	// Every send ends within the timeout, so the lease outlives the run
+	deliveries, err := d.storage.ClaimDeliveries(time.Now(), d.cfg.Timeout+d.cfg.Interval, d.cfg.BatchSize)
+	if err != nil {
+		slog.Error("Claiming webhook deliveries failed", slog.String("error", err.Error()))
+		return
+	}
+	var wg sync.WaitGroup
+	for _, delivery := range deliveries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d.deliver(ctx, delivery)
+		}()
+	}
+	wg.Wait()
+}
+
+func (d *Dispatcher) deliver(ctx context.Context, delivery models.Delivery) {
+	err := d.send(ctx, delivery)
+	if ctx.Err() != nil {
+		// Shutting down; the lease runs out and the delivery is sent again
+		return
+	}
+	delivery.Attempts++
+	delivery.LastError = ""
+	switch {
+	case err == nil:
+		delivery.Status = models.DeliveryDelivered
+	case delivery.Attempts >= d.cfg.MaxAttempts:
+		delivery.Status = models.DeliveryDead
+		delivery.LastError = errorText(err)
+		slog.Warn("Webhook delivery is dead", slog.Uint64("id", delivery.Id), slog.String("url", delivery.URL), slog.String("error", err.Error()))
+	default:
+		delivery.NextAttemptAt = time.Now().Add(d.backoff.Delay(delivery.Attempts - 1))
+		delivery.LastError = errorText(err)
+	}
+	if err := d.storage.UpdateDelivery(delivery); err != nil {
+		slog.Error("Saving webhook delivery failed", slog.Uint64("id", delivery.Id), slog.String("error", err.Error()))
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery models.Delivery) error {
+	body, err := json.Marshal(delivery.Event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventHeader, delivery.Event.Type)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(delivery.Id, 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(delivery.Secret, timestamp, body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10) + "."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func errorText(err error) string {
+	text := err.Error()
+	if len(text) > maxErrorLength {
+		text = strings.ToValidUTF8(text[:maxErrorLength], "")
+	}
+	return text
+}
@@ -0,0 +1,130 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/memory"
+)
+
+var testConfig = config.Webhooks{
+	Interval:        time.Second,
+	Timeout:         time.Second,
+	BatchSize:       10,
+	MaxAttempts:     2,
+	InitialInterval: time.Millisecond,
+	MaxInterval:     time.Millisecond,
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (rc *receiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.requests = append(rc.requests, r)
+	rc.bodies = append(rc.bodies, body)
+	w.WriteHeader(rc.status)
+}
+
+func TestDeliver(t *testing.T) {
+	rc := &receiver{status: http.StatusNoContent}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store := memory.New()
+	if _, err := store.CreateSubscription(server.URL, "secret", []string{storage.EventStudentCreated}); err != nil {
+		t.Fatal(err)
+	}
+	id, err := store.CreateStudent("Asha", "asha@example.com", 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Not subscribed to
+	if _, err := store.UpdateStudentByID("Asha", "asha@example.org", 20, id); err != nil {
+		t.Fatal(err)
+	}
+
+	New(store, testConfig).Run(context.Background())
+
+	if len(rc.requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(rc.requests))
+	}
+	req, body := rc.requests[0], rc.bodies[0]
+	if req.Header.Get(EventHeader) != storage.EventStudentCreated {
+		t.Errorf("%s = %q", EventHeader, req.Header.Get(EventHeader))
+	}
+	timestamp, err := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := req.Header.Get(SignatureHeader), Sign("secret", timestamp, body); got != want {
+		t.Errorf("signature %q, want %q", got, want)
+	}
+	var event models.Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Type != storage.EventStudentCreated || event.StudentId != id {
+		t.Errorf("delivered event %+v", event)
+	}
+	list, _ := store.Deliveries(models.DeliveryDelivered, 10)
+	if len(list) != 1 || list[0].Attempts != 1 {
+		t.Errorf("delivered deliveries: %+v", list)
+	}
+}
+
+func TestRetryUntilDead(t *testing.T) {
+	rc := &receiver{status: http.StatusInternalServerError}
+	server := httptest.NewServer(rc)
+	defer server.Close()
+
+	store := memory.New()
+	if _, err := store.CreateSubscription(server.URL, "secret", nil); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.CreateStudent("Asha", "asha@example.com", 20); err != nil {
+		t.Fatal(err)
+	}
+	d := New(store, testConfig)
+	d.Run(context.Background())
+	list, _ := store.Deliveries(models.DeliveryPending, 10)
+	if len(list) != 1 || list[0].Attempts != 1 || list[0].LastError == "" {
+		t.Fatalf("after a failed attempt: %+v", list)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	d.Run(context.Background())
+	list, _ = store.Deliveries(models.DeliveryDead, 10)
+	if len(list) != 1 || list[0].Attempts != testConfig.MaxAttempts {
+		t.Fatalf("after the last attempt: %+v", list)
+	}
+
+	// A replay is sent again, this time successfully
+	rc.status = http.StatusOK
+	if err := store.ReplayDelivery(int64(list[0].Id)); err != nil {
+		t.Fatal(err)
+	}
+	d.Run(context.Background())
+	if len(rc.requests) != 3 {
+		t.Errorf("receiver got %d requests, want 3", len(rc.requests))
+	}
+	if list, _ := store.Deliveries(models.DeliveryDelivered, 10); len(list) != 1 {
+		t.Errorf("replayed delivery not delivered: %+v", list)
+	}
+}
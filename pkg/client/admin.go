@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// LastGC returns the report of the last garbage collection sweep; it
+// fails with ErrNotFound before the first one.
+func (c *Client) LastGC(ctx context.Context) (GCReport, error) {
+	var report GCReport
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/admin/gc"}, nil, &report)
+	return report, err
+}
+
+// RunGC sweeps now. A dry run only reports what would be removed.
+func (c *Client) RunGC(ctx context.Context, dryRun bool) (GCReport, error) {
+	var report GCReport
+	query := url.Values{"dry_run": {strconv.FormatBool(dryRun)}}
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/admin/gc", query: query, withKey: true}, nil, &report)
+	return report, err
+}
+
+// Database checks the storage backend. A database that cannot be reached
+// fails with ErrUnavailable.
+func (c *Client) Database(ctx context.Context) (DatabaseStatus, error) {
+	var status DatabaseStatus
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/admin/db"}, nil, &status)
+	return status, err
+}
+
+// CacheStats fails with ErrNotFound when the student cache is off.
+func (c *Client) CacheStats(ctx context.Context) (CacheStats, error) {
+	var stats CacheStats
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/admin/cache"}, nil, &stats)
+	return stats, err
+}
+
+func (c *Client) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	var list []Subscription
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/admin/webhooks"}, nil, &list)
+	return list, err
+}
+
+// CreateSubscription registers a webhook. The returned subscription holds
+// its secret, which the API does not show again.
+func (c *Client) CreateSubscription(ctx context.Context, in SubscriptionInput) (Subscription, error) {
+	var res struct {
+		Subscription Subscription `json:"subscription"`
+		Secret       string       `json:"secret"`
+	}
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/admin/webhooks", withKey: true}, in, &res)
+	res.Subscription.Secret = res.Secret
+	return res.Subscription, err
+}
+
+func (c *Client) DeleteSubscription(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: "/api/admin/webhooks/" + strconv.FormatInt(id, 10)}, nil, nil)
+}
+
+// Deliveries lists the most recent webhook deliveries.
+func (c *Client) Deliveries(ctx context.Context, filter DeliveryFilter) ([]Delivery, error) {
+	query := url.Values{}
+	if filter.Status != "" {
+		query.Set("status", filter.Status)
+	}
+	if filter.Limit != 0 {
+		query.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	var list []Delivery
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/admin/webhooks/deliveries", query: query}, nil, &list)
+	return list, err
+}
+
+// ReplayDelivery sends a delivery again on the dispatcher's next run.
+func (c *Client) ReplayDelivery(ctx context.Context, id int64) error {
+	path := "/api/admin/webhooks/deliveries/" + strconv.FormatInt(id, 10) + "/replay"
+	return c.call(ctx, request{method: http.MethodPost, path: path, withKey: true}, nil, nil)
+}
@@ -0,0 +1,27 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Batch sends several requests in one round trip and returns their
+// responses in the same order. A sub-request that fails does not fail the
+// batch; check each response's Status.
+func (c *Client) Batch(ctx context.Context, requests []BatchRequest) ([]BatchResponse, error) {
+	var responses []BatchResponse
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/batch", withKey: true}, requests, &responses)
+	return responses, err
+}
+
+// AtomicBatch runs requests in one transaction: either all their changes
+// are kept or, once one of them fails, none are. That failure comes back
+// as an *Error. Storage backends without transactions refuse atomic
+// batches with ErrNotImplemented.
+func (c *Client) AtomicBatch(ctx context.Context, requests []BatchRequest) ([]BatchResponse, error) {
+	var responses []BatchResponse
+	req := request{method: http.MethodPost, path: "/api/batch", query: url.Values{"atomic": {"true"}}, withKey: true}
+	err := c.call(ctx, req, requests, &responses)
+	return responses, err
+}
@@ -0,0 +1,247 @@
+// Package client calls the student API from Go. Its methods mirror the
+// routes the API registers, take a context and return typed values, and
+// failed calls return an *Error that errors.Is matches against ErrNotFound
+// and the other sentinel errors.
+//
+// GET, PUT and DELETE requests are retried when the connection fails or
+// the API answers 429, 502, 503 or 504. POST requests to routes that
+// honour the Idempotency-Key header are sent with a fresh key, so they are
+// retried too without running twice. Uploads stream their content and are
+// never retried.
+//
+// The client covers the routes as they are: the student list has no
+// filters or pagination and students are only updated whole, with PUT.
+package client
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/utils/backoff"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	defaultAttempts      = 3
+)
+
+type Client struct {
+	baseURL    *url.URL
+	httpClient *http.Client
+	header     http.Header
+	attempts   int
+	backoff    backoff.Backoff
+}
+
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of a default client,
+// for example to set a timeout or a proxy.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithRetry makes up to attempts tries of each retryable request, waiting
+// from initial up to max between them. One attempt turns retries off.
+func WithRetry(attempts int, initial time.Duration, max time.Duration) Option {
+	return func(c *Client) {
+		c.attempts = attempts
+		c.backoff = backoff.Backoff{Initial: initial, Max: max}
+	}
+}
+
+// WithHeader adds a header to every request.
+func WithHeader(name string, value string) Option {
+	return func(c *Client) {
+		c.header.Set(name, value)
+	}
+}
+
+// WithBearerToken authenticates every request with token. The API does
+// not check credentials itself; this is for deployments behind a gateway
+// that does.
+func WithBearerToken(token string) Option {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// WithClientID names the calling service in the X-Client-ID header, which
+// the API uses in place of the remote address.
+func WithClientID(id string) Option {
+	return WithHeader("X-Client-ID", id)
+}
+
+// WithActor records actor as the one behind every change in the audit log.
+func WithActor(actor string) Option {
+	return WithHeader("X-Actor", actor)
+}
+
+// New returns a client for the API at baseURL, such as
+// "http://localhost:8082".
+func New(baseURL string, opts ...Option) (*Client, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/"))
+	if err != nil {
+		return nil, fmt.Errorf("client: invalid base URL: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("client: base URL must be an http or https URL")
+	}
+	c := &Client{
+		baseURL:    u,
+		httpClient: &http.Client{},
+		header:     make(http.Header),
+		attempts:   defaultAttempts,
+		backoff:    backoff.Backoff{Initial: 200 * time.Millisecond, Max: 5 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	c.attempts = max(c.attempts, 1)
+	return c, nil
+}
+
+// request describes one call. A JSON body in body can be sent again on a
+// retry; a stream cannot.
+type request struct {
+	method string
+	path   string
+	// target is a full URL to use instead of path, such as a signed link
+	target string
+	query  url.Values
+	header http.Header
+	body   []byte
+	stream io.Reader
+	// withKey sends an Idempotency-Key, for POST routes that honour one
+	withKey bool
+}
+
+// send makes the call and returns the response when its status is below
+// 400. Any other status is read into an *Error.
+func (c *Client) send(ctx context.Context, req request) (*http.Response, error) {
+	if req.header == nil {
+		req.header = make(http.Header)
+	}
+	if req.withKey && req.header.Get(idempotencyKeyHeader) == "" {
+		req.header.Set(idempotencyKeyHeader, newKey())
+	}
+	attempts := 1
+	if req.stream == nil && (idempotentMethod(req.method) || req.header.Get(idempotencyKeyHeader) != "") {
+		attempts = c.attempts
+	}
+
+	var res *http.Response
+	err := backoff.Retry(ctx, c.backoff, attempts, retryable(ctx), func() error {
+		var err error
+		res, err = c.attempt(ctx, req)
+		return err
+	})
+	return res, err
+}
+
+func (c *Client) attempt(ctx context.Context, req request) (*http.Response, error) {
+	target := req.target
+	if target == "" {
+		u := *c.baseURL
+		u.Path += req.path
+		u.RawQuery = req.query.Encode()
+		target = u.String()
+	}
+	body := req.stream
+	if body == nil && req.body != nil {
+		body = bytes.NewReader(req.body)
+	}
+	r, err := http.NewRequestWithContext(ctx, req.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.header {
+		r.Header[name] = values
+	}
+	for name, values := range req.header {
+		r.Header[name] = values
+	}
+	res, err := c.httpClient.Do(r)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode >= http.StatusBadRequest {
+		defer res.Body.Close()
+		return nil, readError(res)
+	}
+	return res, nil
+}
+
+// call sends in as JSON, if it is not nil, and decodes the response into
+// out, if it is not nil.
+func (c *Client) call(ctx context.Context, req request, in any, out any) error {
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		req.body = data
+		if req.header == nil {
+			req.header = make(http.Header)
+		}
+		req.header.Set("Content-Type", "application/json")
+	}
+	res, err := c.send(ctx, req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if out == nil {
+		_, err = io.Copy(io.Discard, res.Body)
+		return err
+	}
+	if err := json.NewDecoder(res.Body).Decode(out); err != nil {
+		return fmt.Errorf("client: decoding %s %s response: %w", req.method, req.path, err)
+	}
+	return nil
+}
+
+// idempotentMethod reports whether sending a request with method twice
+// has the same effect as sending it once.
+func idempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable accepts connection failures and the statuses that mean the
+// API is briefly unable to answer, but not the end of ctx.
+func retryable(ctx context.Context) func(error) bool {
+	return func(err error) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			switch apiErr.StatusCode {
+			case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+				return true
+			}
+			return false
+		}
+		return true
+	}
+}
+
+func newKey() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
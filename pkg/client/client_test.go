@@ -0,0 +1,520 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"image"
+	"image/png"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/gc"
+	"github.com/surajNirala/student-api/internal/http/middleware"
+	"github.com/surajNirala/student-api/internal/storage"
+	_ "github.com/surajNirala/student-api/internal/storage/memory"
+	"github.com/surajNirala/student-api/internal/stream"
+	"github.com/surajNirala/student-api/routes"
+)
+
+const testConfig = `env: "test"
+storage:
+  driver: "memory"
+http_server:
+  address: "localhost:0"
+signing:
+  active_key: "k1"
+  keys:
+    k1: "test-secret"
+gc:
+  disabled: true
+  min_age: 0s
+webhooks:
+  disabled: true
+events:
+  poll_interval: 10ms
+validation:
+  responses: true
+`
+
+// newServer runs the API on memory storage, wired up as main does, and
+// returns a client for it along with the storage behind it.
+func newServer(t *testing.T, opts ...Option) (*Client, storage.Storage) {
+	t.Helper()
+	// Uploaded files are kept under the working directory
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err := os.WriteFile(path, []byte(testConfig), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CONFIG_PATH", path)
+	cfg := config.MustLoad()
+	store, err := storage.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	collector := gc.New(store, cfg.GC, cfg.Uploads.Tus)
+	broker := stream.New(store, cfg.Events)
+	router := http.NewServeMux()
+	routes.RouteLoad(router, store, cfg, collector, broker)
+	server := httptest.NewServer(middleware.RequestID(middleware.ClientID(middleware.Actor(router))))
+	t.Cleanup(server.Close)
+	// Ending the broker ends open event streams, so it must stop before the server
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go broker.Start(ctx)
+
+	c, err := New(server.URL, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c, store
+}
+
+func TestStudents(t *testing.T) {
+	c, _ := newServer(t, WithActor("registrar"))
+	ctx := context.Background()
+
+	id, err := c.CreateStudent(ctx, StudentInput{Name: "Asha", Email: "asha@example.com", Age: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.Now()
+	if err := c.UpdateStudent(ctx, id, StudentInput{Name: "Asha K", Email: "asha@example.com", Age: 21}); err != nil {
+		t.Fatal(err)
+	}
+	student, err := c.GetStudent(ctx, id)
+	if err != nil || student.Name != "Asha K" || student.Age != 21 {
+		t.Fatalf("GetStudent = %+v, %v", student, err)
+	}
+	if list, err := c.ListStudents(ctx); err != nil || len(list) != 1 || list[0].ID != student.ID {
+		t.Errorf("ListStudents = %+v, %v", list, err)
+	}
+	if old, err := c.GetStudentAsOf(ctx, id, created); err != nil || old.Name != "Asha" {
+		t.Errorf("GetStudentAsOf = %+v, %v", old, err)
+	}
+
+	history, err := c.StudentHistory(ctx, id)
+	if err != nil || len(history) != 2 || history[1].Actor != "registrar" {
+		t.Errorf("StudentHistory = %+v, %v", history, err)
+	}
+	revisions, err := c.StudentRevisions(ctx, id)
+	if err != nil || len(revisions) != 2 {
+		t.Fatalf("StudentRevisions = %+v, %v", revisions, err)
+	}
+	rev, err := c.RevertStudent(ctx, id, revisions[0].Revision)
+	if err != nil || rev.Name != "Asha" || rev.Revision != 3 {
+		t.Errorf("RevertStudent = %+v, %v", rev, err)
+	}
+
+	if err := c.DeleteStudent(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.RevertStudent(ctx, id, 9); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RevertStudent of a missing revision: %v, want ErrNotFound", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c, _ := newServer(t, WithRetry(1, 0, 0))
+	ctx := context.Background()
+
+	_, err := c.Deliveries(ctx, DeliveryFilter{Status: "lost"})
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, ErrBadRequest) {
+		t.Fatalf("Deliveries with an unknown status: %v, want an *Error matching ErrBadRequest", err)
+	}
+	if len(apiErr.Violations) != 1 || apiErr.Violations[0].In != "query" || apiErr.Violations[0].Pointer != "status" {
+		t.Errorf("violations = %+v", apiErr.Violations)
+	}
+
+	_, err = c.CreateStudent(ctx, StudentInput{Email: "asha@example.com", Age: 20})
+	if !errors.Is(err, ErrBadRequest) {
+		t.Errorf("CreateStudent without a name: %v, want ErrBadRequest", err)
+	}
+	if _, err := c.GetStudent(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetStudent of a missing student: %v, want ErrNotFound", err)
+	}
+	if err := c.UpdateStudent(ctx, 999, StudentInput{Name: "Asha", Email: "asha@example.com", Age: 20}); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UpdateStudent of a missing student: %v, want ErrNotFound", err)
+	}
+	if err := c.DeleteStudent(ctx, 999); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteStudent of a missing student: %v, want ErrNotFound", err)
+	}
+	if _, err := c.DownloadFile(ctx, 999); !errors.Is(err, ErrNotFound) || errors.Is(err, ErrBadRequest) {
+		t.Errorf("DownloadFile of a missing file: %v, want ErrNotFound", err)
+	}
+	if _, err := c.LastGC(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LastGC before a sweep: %v, want ErrNotFound", err)
+	}
+	if _, err := c.AtomicBatch(ctx, []BatchRequest{{Method: http.MethodGet, Path: "/api/students"}}); !errors.Is(err, ErrNotImplemented) {
+		t.Errorf("AtomicBatch on memory storage: %v, want ErrNotImplemented", err)
+	}
+}
+
+func TestFiles(t *testing.T) {
+	c, _ := newServer(t)
+	ctx := context.Background()
+
+	id, err := c.CreateStudent(ctx, StudentInput{Name: "Asha", Email: "asha@example.com", Age: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := c.UploadFile(ctx, FileUpload{Name: "notes.txt", Content: strings.NewReader("hello"), StudentID: id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readDownload(t)(c.DownloadFile(ctx, int64(file.ID))); got != "hello" {
+		t.Errorf("DownloadFile = %q", got)
+	}
+	large, err := c.UploadLargeFile(ctx, FileUpload{Name: "large.txt", Content: strings.NewReader("large")})
+	if err != nil || large.ID == file.ID {
+		t.Fatalf("UploadLargeFile = %+v, %v", large, err)
+	}
+	if usage, err := c.StudentFileUsage(ctx, id); err != nil || usage.Files != 1 || usage.UsedBytes != 5 {
+		t.Errorf("StudentFileUsage = %+v, %v", usage, err)
+	}
+
+	link, err := c.CreateFileLink(ctx, int64(file.ID), time.Minute)
+	if err != nil || time.Until(link.ExpiresAt) > time.Minute {
+		t.Fatalf("CreateFileLink = %+v, %v", link, err)
+	}
+	if got := readDownload(t)(c.DownloadLink(ctx, link.URL)); got != "hello" {
+		t.Errorf("DownloadLink = %q", got)
+	}
+	if _, err := c.DownloadLink(ctx, link.URL+"0"); !errors.Is(err, ErrForbidden) {
+		t.Errorf("DownloadLink with a bad signature: %v, want ErrForbidden", err)
+	}
+
+	if err := c.DeleteFile(ctx, int64(file.ID)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.DownloadFile(ctx, int64(file.ID)); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DownloadFile after DeleteFile: %v, want ErrNotFound", err)
+	}
+}
+
+func TestPhoto(t *testing.T) {
+	c, _ := newServer(t)
+	ctx := context.Background()
+
+	id, err := c.CreateStudent(ctx, StudentInput{Name: "Asha", Email: "asha@example.com", Age: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var img bytes.Buffer
+	if err := png.Encode(&img, image.NewGray(image.Rect(0, 0, 300, 200))); err != nil {
+		t.Fatal(err)
+	}
+	url, err := c.UploadPhoto(ctx, id, "photo.png", &img)
+	if err != nil || url == "" {
+		t.Fatalf("UploadPhoto = %q, %v", url, err)
+	}
+	download, err := c.DownloadPhoto(ctx, id, PhotoThumb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer download.Body.Close()
+	thumb, _, err := image.Decode(download.Body)
+	if err != nil || thumb.Bounds().Dx() > 300 {
+		t.Errorf("thumbnail: %v, %v", thumb.Bounds(), err)
+	}
+}
+
+func TestResumableUpload(t *testing.T) {
+	c, _ := newServer(t, WithRetry(3, time.Millisecond, time.Millisecond))
+	ctx := context.Background()
+
+	content := strings.Repeat("0123456789", 100)
+	upload, err := c.CreateUpload(ctx, "digits.txt", "text/plain", int64(len(content)), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if upload, err = c.WriteUpload(ctx, upload.ID, 0, strings.NewReader(content[:400])); err != nil || upload.Offset != 400 {
+		t.Fatalf("WriteUpload = %+v, %v", upload, err)
+	}
+	if status, err := c.UploadStatus(ctx, upload.ID); err != nil || status.Offset != 400 || status.Length != int64(len(content)) {
+		t.Errorf("UploadStatus = %+v, %v", status, err)
+	}
+	// Writing from the wrong offset is refused
+	if _, err := c.WriteUpload(ctx, upload.ID, 0, strings.NewReader(content)); !errors.Is(err, ErrConflict) {
+		t.Errorf("WriteUpload from a stale offset: %v, want ErrConflict", err)
+	}
+	if err := c.CancelUpload(ctx, upload.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.UploadStatus(ctx, upload.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UploadStatus after CancelUpload: %v, want ErrNotFound", err)
+	}
+
+	upload, err = c.ResumableUpload(ctx, "digits.txt", "text/plain", strings.NewReader(content), 0)
+	if err != nil || upload.Offset != int64(len(content)) || upload.FileLocation == "" {
+		t.Fatalf("ResumableUpload = %+v, %v", upload, err)
+	}
+	id := upload.FileLocation[strings.LastIndex(upload.FileLocation, "/")+1:]
+	download, err := c.download(ctx, request{method: http.MethodGet, path: "/api/files/" + id})
+	if got := readDownload(t)(download, err); got != content {
+		t.Errorf("download of the resumable upload = %q", got)
+	}
+}
+
+func TestAdmin(t *testing.T) {
+	c, store := newServer(t)
+	ctx := context.Background()
+
+	sub, err := c.CreateSubscription(ctx, SubscriptionInput{URL: "http://example.com/hook", Events: []string{EventStudentCreated}})
+	if err != nil || sub.ID == 0 || sub.Secret == "" {
+		t.Fatalf("CreateSubscription = %+v, %v", sub, err)
+	}
+	if list, err := c.Subscriptions(ctx); err != nil || len(list) != 1 || list[0].Secret != "" {
+		t.Errorf("Subscriptions = %+v, %v", list, err)
+	}
+	if _, err := c.CreateStudent(ctx, StudentInput{Name: "Asha", Email: "asha@example.com", Age: 20}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.DispatchEvents(10); err != nil {
+		t.Fatal(err)
+	}
+	deliveries, err := c.Deliveries(ctx, DeliveryFilter{Status: DeliveryPending, Limit: 10})
+	if err != nil || len(deliveries) != 1 || deliveries[0].Event.Type != EventStudentCreated {
+		t.Fatalf("Deliveries = %+v, %v", deliveries, err)
+	}
+	if err := c.ReplayDelivery(ctx, int64(deliveries[0].ID)); err != nil {
+		t.Errorf("ReplayDelivery: %v", err)
+	}
+	if err := c.DeleteSubscription(ctx, int64(sub.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, err := c.Database(ctx); err != nil || status.Driver != "memory" || status.Status != "up" {
+		t.Errorf("Database = %+v, %v", status, err)
+	}
+	if _, err := c.CacheStats(ctx); !errors.Is(err, ErrNotFound) {
+		t.Errorf("CacheStats without a cache: %v, want ErrNotFound", err)
+	}
+	report, err := c.RunGC(ctx, true)
+	if err != nil || !report.DryRun {
+		t.Fatalf("RunGC = %+v, %v", report, err)
+	}
+	if last, err := c.LastGC(ctx); err != nil || !last.FinishedAt.Equal(report.FinishedAt) {
+		t.Errorf("LastGC = %+v, %v", last, err)
+	}
+}
+
+func TestBatch(t *testing.T) {
+	c, _ := newServer(t)
+	responses, err := c.Batch(context.Background(), []BatchRequest{
+		{Method: http.MethodPost, Path: "/api/students", Body: StudentInput{Name: "Asha", Email: "asha@example.com", Age: 20}},
+		{Method: http.MethodGet, Path: "/api/students/1"},
+		{Method: http.MethodGet, Path: "/api/students/x"},
+	})
+	if err != nil || len(responses) != 3 {
+		t.Fatalf("Batch = %+v, %v", responses, err)
+	}
+	for i, want := range []int{http.StatusCreated, http.StatusOK, http.StatusBadRequest} {
+		if responses[i].Status != want {
+			t.Errorf("response %d: status %d, want %d", i, responses[i].Status, want)
+		}
+	}
+	var student Student
+	if err := json.Unmarshal(responses[1].Body, &student); err != nil || student.Name != "Asha" {
+		t.Errorf("response 1 body %s", responses[1].Body)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	c, _ := newServer(t)
+	ctx := context.Background()
+
+	id, err := c.CreateStudent(ctx, StudentInput{Name: "Asha", Email: "asha@example.com", Age: 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Without an id the stream starts at whatever the broker has seen, so
+	// name the creation to start after it
+	events, err := c.Events(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer events.Close()
+	if err := c.DeleteStudent(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	event, err := events.Next()
+	if err != nil || event.Type != EventStudentDeleted || event.StudentID != id {
+		t.Fatalf("Next = %+v, %v", event, err)
+	}
+
+	// Resuming from the same point replays the deletion
+	resumed, err := c.Events(ctx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resumed.Close()
+	if replayed, err := resumed.Next(); err != nil || replayed.ID != event.ID {
+		t.Errorf("Next after resuming = %+v, %v", replayed, err)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*http.Request
+		// down makes every request fail instead of every third succeeding
+		down bool
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r)
+		n, unavailable := len(requests), down
+		mu.Unlock()
+		io.Copy(io.Discard, r.Body)
+		switch {
+		case unavailable || r.URL.Path == "/api/students/file-upload":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case n%3 != 0:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			w.Write([]byte(`{"status": "Error", "error": "try again"}`))
+		case r.Method == http.MethodPost:
+			w.Write([]byte(`{"id": 7}`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+	defer server.Close()
+	c, err := New(server.URL, WithRetry(3, time.Millisecond, time.Millisecond), WithBearerToken("token"), WithClientID("reports"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	reset := func() {
+		mu.Lock()
+		requests = nil
+		mu.Unlock()
+	}
+
+	if list, err := c.ListStudents(ctx); err != nil || len(list) != 0 || len(requests) != 3 {
+		t.Errorf("ListStudents = %v, %v after %d requests", list, err, len(requests))
+	}
+	for _, r := range requests {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("X-Client-ID") != "reports" {
+			t.Errorf("request headers %v", r.Header)
+		}
+	}
+
+	reset()
+	if id, err := c.CreateStudent(ctx, StudentInput{Name: "Asha", Email: "asha@example.com", Age: 20}); err != nil || id != 7 {
+		t.Errorf("CreateStudent = %d, %v", id, err)
+	}
+	key := requests[0].Header.Get(idempotencyKeyHeader)
+	if len(requests) != 3 || key == "" || requests[2].Header.Get(idempotencyKeyHeader) != key {
+		t.Errorf("CreateStudent sent %d requests with keys %q and %q", len(requests), key, requests[len(requests)-1].Header.Get(idempotencyKeyHeader))
+	}
+
+	reset()
+	_, err = c.UploadFile(ctx, FileUpload{Name: "notes.txt", Content: strings.NewReader("hello")})
+	if !errors.Is(err, ErrUnavailable) || len(requests) != 1 {
+		t.Errorf("UploadFile = %v after %d requests, want ErrUnavailable after 1", err, len(requests))
+	}
+
+	reset()
+	mu.Lock()
+	down = true
+	mu.Unlock()
+	_, err = c.ListStudents(ctx)
+	if !errors.Is(err, ErrUnavailable) || len(requests) != 3 {
+		t.Errorf("ListStudents = %v after %d requests, want ErrUnavailable after 3", err, len(requests))
+	}
+}
+
+// TestCoverage keeps the client in step with the routes the API documents.
+func TestCoverage(t *testing.T) {
+	covered := map[string]string{
+		"POST /api/batch":                                 "Batch, AtomicBatch",
+		"GET /api/students":                               "ListStudents",
+		"POST /api/students":                              "CreateStudent",
+		"GET /api/students/events":                        "Events",
+		"GET /api/students/{id}":                          "GetStudent, GetStudentAsOf",
+		"PUT /api/students/{id}":                          "UpdateStudent",
+		"DELETE /api/students/{id}":                       "DeleteStudent",
+		"GET /api/students/{id}/history":                  "StudentHistory",
+		"GET /api/students/{id}/revisions":                "StudentRevisions",
+		"POST /api/students/{id}/revisions/{rev}/revert":  "RevertStudent",
+		"PUT /api/students/{id}/photo":                    "UploadPhoto",
+		"GET /api/students/{id}/photo":                    "DownloadPhoto",
+		"GET /api/students/{id}/files/usage":              "StudentFileUsage",
+		"POST /api/students/file-upload":                  "UploadFile",
+		"POST /api/students/large-file-upload":            "UploadLargeFile",
+		"GET /api/files/{id}":                             "DownloadFile",
+		"DELETE /api/files/{id}":                          "DeleteFile",
+		"POST /api/files/{id}/links":                      "CreateFileLink",
+		"GET /api/files/signed/{id}":                      "DownloadLink",
+		"POST /api/files/tus":                             "CreateUpload",
+		"HEAD /api/files/tus/{id}":                        "UploadStatus",
+		"PATCH /api/files/tus/{id}":                       "WriteUpload",
+		"DELETE /api/files/tus/{id}":                      "CancelUpload",
+		"GET /api/admin/gc":                               "LastGC",
+		"POST /api/admin/gc":                              "RunGC",
+		"GET /api/admin/db":                               "Database",
+		"GET /api/admin/cache":                            "CacheStats",
+		"GET /api/admin/webhooks":                         "Subscriptions",
+		"POST /api/admin/webhooks":                        "CreateSubscription",
+		"DELETE /api/admin/webhooks/{id}":                 "DeleteSubscription",
+		"GET /api/admin/webhooks/deliveries":              "Deliveries",
+		"POST /api/admin/webhooks/deliveries/{id}/replay": "ReplayDelivery",
+		"OPTIONS /api/files/tus":                          "none: tus discovery, the client speaks tus 1.0",
+		"GET /api/students1":                              "none: deprecated alias of GET /api/students",
+	}
+
+	c, _ := newServer(t)
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := c.call(context.Background(), request{method: http.MethodGet, path: "/openapi.json"}, nil, &doc); err != nil {
+		t.Fatal(err)
+	}
+	documented := make(map[string]bool)
+	for path, ops := range doc.Paths {
+		for method := range ops {
+			if method == "parameters" {
+				continue
+			}
+			op := strings.ToUpper(method) + " " + path
+			documented[op] = true
+			if _, ok := covered[op]; !ok {
+				t.Errorf("%s has no client method", op)
+			}
+		}
+	}
+	for op := range covered {
+		if !documented[op] {
+			t.Errorf("%s is not documented by the API", op)
+		}
+	}
+}
+
+func readDownload(t *testing.T) func(*Download, error) string {
+	return func(download *Download, err error) string {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer download.Body.Close()
+		data, err := io.ReadAll(download.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+}
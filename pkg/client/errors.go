@@ -0,0 +1,101 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors an *Error matches with errors.Is, by its status code.
+var (
+	ErrBadRequest           = errors.New("bad request")
+	ErrForbidden            = errors.New("forbidden")
+	ErrNotFound             = errors.New("not found")
+	ErrConflict             = errors.New("conflict")
+	ErrPreconditionFailed   = errors.New("precondition failed")
+	ErrTooLarge             = errors.New("request too large")
+	ErrUnsupportedMediaType = errors.New("unsupported media type")
+	ErrUnprocessable        = errors.New("unprocessable request")
+	ErrNotImplemented       = errors.New("not implemented")
+	ErrUnavailable          = errors.New("service unavailable")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:            ErrBadRequest,
+	http.StatusForbidden:             ErrForbidden,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusConflict:              ErrConflict,
+	http.StatusPreconditionFailed:    ErrPreconditionFailed,
+	http.StatusRequestEntityTooLarge: ErrTooLarge,
+	http.StatusUnsupportedMediaType:  ErrUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   ErrUnprocessable,
+	http.StatusNotImplemented:        ErrNotImplemented,
+	http.StatusServiceUnavailable:    ErrUnavailable,
+}
+
+// Error is a response with a 4xx or 5xx status. Message is the error the
+// API gave, and Violations lists what was wrong with a request it refused
+// for not matching its OpenAPI document.
+type Error struct {
+	StatusCode int
+	Message    string
+	Violations []Violation
+}
+
+// Violation is one invalid part of a request. In is "path", "query",
+// "header" or "body"; Pointer names the parameter or, for bodies, is a
+// JSON pointer to the value.
+type Violation struct {
+	In      string `json:"in"`
+	Pointer string `json:"pointer"`
+	Detail  string `json:"detail"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("student api: %d %s", e.StatusCode, e.Message)
+	for _, v := range e.Violations {
+		msg += fmt.Sprintf("; %s %s %s", v.In, v.Pointer, v.Detail)
+	}
+	return msg
+}
+
+func (e *Error) Is(target error) bool {
+	return statusErrors[e.StatusCode] == target
+}
+
+// readError reads the body of a failed response. The API answers most
+// errors with {"status": "Error", "error": "..."} and refused requests
+// with RFC 9457 problem details.
+func readError(res *http.Response) error {
+	apiErr := &Error{StatusCode: res.StatusCode}
+	data, _ := io.ReadAll(io.LimitReader(res.Body, 64<<10))
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/problem+json":
+		var problem struct {
+			Detail string      `json:"detail"`
+			Errors []Violation `json:"errors"`
+		}
+		if json.Unmarshal(data, &problem) == nil {
+			apiErr.Message = problem.Detail
+			apiErr.Violations = problem.Errors
+		}
+	case "application/json":
+		var body struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(data, &body) == nil {
+			apiErr.Message = body.Error
+		}
+	default:
+		apiErr.Message = strings.TrimSpace(string(data))
+	}
+	if apiErr.Message == "" {
+		apiErr.Message = http.StatusText(res.StatusCode)
+	}
+	return apiErr
+}
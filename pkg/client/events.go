@@ -0,0 +1,67 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// EventStream reads student changes as the API sends them.
+type EventStream struct {
+	body    io.ReadCloser
+	scanner *bufio.Scanner
+}
+
+// Events opens a stream of student changes. With lastEventID above 0 the
+// stream starts with the events recorded after it. The stream ends when
+// ctx does or Close is called.
+func (c *Client) Events(ctx context.Context, lastEventID uint64) (*EventStream, error) {
+	header := http.Header{"Accept": {"text/event-stream"}}
+	if lastEventID > 0 {
+		header.Set("Last-Event-ID", strconv.FormatUint(lastEventID, 10))
+	}
+	// The stream is read while it is open, so it is not retried: a caller
+	// reconnects with the id of the last event it saw
+	res, err := c.attempt(ctx, request{method: http.MethodGet, path: "/api/students/events", header: header})
+	if err != nil {
+		return nil, err
+	}
+	return &EventStream{body: res.Body, scanner: bufio.NewScanner(res.Body)}, nil
+}
+
+// Next blocks until the next event arrives. It returns io.EOF once the API
+// ends the stream.
+func (s *EventStream) Next() (Event, error) {
+	var data []string
+	for s.scanner.Scan() {
+		line := s.scanner.Text()
+		if line == "" {
+			if data == nil {
+				continue
+			}
+			var event Event
+			if err := json.Unmarshal([]byte(strings.Join(data, "\n")), &event); err != nil {
+				return Event{}, fmt.Errorf("client: decoding event: %w", err)
+			}
+			return event, nil
+		}
+		// Ids and types are repeated in the data, and comments and retry
+		// times are of no use to a reader
+		if value, ok := strings.CutPrefix(line, "data:"); ok {
+			data = append(data, strings.TrimPrefix(value, " "))
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return Event{}, err
+	}
+	return Event{}, io.EOF
+}
+
+func (s *EventStream) Close() error {
+	return s.body.Close()
+}
@@ -0,0 +1,114 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ListStudents returns every student. The API has no filters or
+// pagination for this list.
+func (c *Client) ListStudents(ctx context.Context) ([]Student, error) {
+	var list []Student
+	err := c.call(ctx, request{method: http.MethodGet, path: "/api/students"}, nil, &list)
+	return list, err
+}
+
+// CreateStudent adds a student and returns its id.
+func (c *Client) CreateStudent(ctx context.Context, in StudentInput) (int64, error) {
+	var res struct {
+		ID int64 `json:"id"`
+	}
+	err := c.call(ctx, request{method: http.MethodPost, path: "/api/students", withKey: true}, in, &res)
+	return res.ID, err
+}
+
+func (c *Client) GetStudent(ctx context.Context, id int64) (Student, error) {
+	var student Student
+	err := c.call(ctx, request{method: http.MethodGet, path: studentPath(id)}, nil, &student)
+	return student, err
+}
+
+// GetStudentAsOf returns the student as it was at t, which may be before
+// it was deleted.
+func (c *Client) GetStudentAsOf(ctx context.Context, id int64, t time.Time) (Student, error) {
+	var student Student
+	query := url.Values{"as_of": {t.Format(time.RFC3339Nano)}}
+	err := c.call(ctx, request{method: http.MethodGet, path: studentPath(id), query: query}, nil, &student)
+	return student, err
+}
+
+// UpdateStudent replaces the name, email and age of a student. The API
+// has no partial update, so every field must be given.
+func (c *Client) UpdateStudent(ctx context.Context, id int64, in StudentInput) error {
+	return c.call(ctx, request{method: http.MethodPut, path: studentPath(id)}, in, nil)
+}
+
+func (c *Client) DeleteStudent(ctx context.Context, id int64) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: studentPath(id)}, nil, nil)
+}
+
+// StudentHistory lists the recorded changes of a student, oldest first.
+func (c *Client) StudentHistory(ctx context.Context, id int64) ([]AuditEntry, error) {
+	var history []AuditEntry
+	err := c.call(ctx, request{method: http.MethodGet, path: studentPath(id) + "/history"}, nil, &history)
+	return history, err
+}
+
+func (c *Client) StudentRevisions(ctx context.Context, id int64) ([]Revision, error) {
+	var revisions []Revision
+	err := c.call(ctx, request{method: http.MethodGet, path: studentPath(id) + "/revisions"}, nil, &revisions)
+	return revisions, err
+}
+
+// RevertStudent restores the values of revision rev and returns the
+// revision that records it.
+func (c *Client) RevertStudent(ctx context.Context, id int64, rev int) (Revision, error) {
+	var res struct {
+		Revision Revision `json:"revision"`
+	}
+	path := fmt.Sprintf("%s/revisions/%d/revert", studentPath(id), rev)
+	err := c.call(ctx, request{method: http.MethodPost, path: path, withKey: true}, nil, &res)
+	return res.Revision, err
+}
+
+// UploadPhoto sets a student's photo, a JPEG or PNG image, and returns
+// the URL it is served at.
+func (c *Client) UploadPhoto(ctx context.Context, id int64, name string, content io.Reader) (string, error) {
+	var res struct {
+		PhotoURL string `json:"photo_url"`
+	}
+	err := c.upload(ctx, http.MethodPut, studentPath(id)+"/photo", FileUpload{Name: name, Content: content}, &res)
+	return res.PhotoURL, err
+}
+
+// Photo sizes.
+const (
+	PhotoOriginal = "original"
+	PhotoMedium   = "medium"
+	PhotoThumb    = "thumb"
+)
+
+// DownloadPhoto downloads a student's photo in one of the photo sizes.
+func (c *Client) DownloadPhoto(ctx context.Context, id int64, size string) (*Download, error) {
+	query := url.Values{}
+	if size != "" {
+		query.Set("size", size)
+	}
+	return c.download(ctx, request{method: http.MethodGet, path: studentPath(id) + "/photo", query: query})
+}
+
+// StudentFileUsage reports the storage a student's files use.
+func (c *Client) StudentFileUsage(ctx context.Context, id int64) (FileUsage, error) {
+	var usage FileUsage
+	err := c.call(ctx, request{method: http.MethodGet, path: studentPath(id) + "/files/usage"}, nil, &usage)
+	return usage, err
+}
+
+func studentPath(id int64) string {
+	return "/api/students/" + strconv.FormatInt(id, 10)
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"time"
+)
+
+type Student struct {
+	ID        uint64    `json:"id"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Age       int       `json:"age"`
+	PhotoURL  string    `json:"photo_url,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// StudentInput holds the fields a client sets when creating or updating a
+// student; all three are required.
+type StudentInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Age   int    `json:"age"`
+}
+
+// Revision is a student as it stood after one change. A deletion is a
+// revision with Deleted set.
+type Revision struct {
+	StudentID int64     `json:"student_id"`
+	Revision  int       `json:"revision"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email"`
+	Age       int       `json:"age"`
+	Deleted   bool      `json:"deleted"`
+	Actor     string    `json:"actor"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// AuditEntry records one change; Changes maps each changed field to
+// {"from": .., "to": ..}.
+type AuditEntry struct {
+	ID         uint64          `json:"id"`
+	Actor      string          `json:"actor"`
+	Action     string          `json:"action"`
+	EntityType string          `json:"entity_type"`
+	EntityID   int64           `json:"entity_id"`
+	Changes    json.RawMessage `json:"changes"`
+	RequestID  string          `json:"request_id,omitempty"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// FileUsage sums up a student's files. QuotaBytes is 0 when there is no
+// quota, in which case RemainingBytes is nil.
+type FileUsage struct {
+	StudentID      int64  `json:"student_id"`
+	Files          int64  `json:"files"`
+	UsedBytes      int64  `json:"used_bytes"`
+	QuotaBytes     int64  `json:"quota_bytes"`
+	RemainingBytes *int64 `json:"remaining_bytes,omitempty"`
+}
+
+// FileUpload is a file to upload. StudentID, when set, attaches the file
+// to a student, which makes it downloadable only while the student exists.
+type FileUpload struct {
+	Name      string
+	Content   io.Reader
+	StudentID int64
+}
+
+// UploadedFile is a file the API stored.
+type UploadedFile struct {
+	ID     uint64 `json:"id"`
+	SHA256 string `json:"sha256"`
+}
+
+// FileLink is a signed URL that downloads a file without credentials
+// until ExpiresAt.
+type FileLink struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Download is a file being downloaded. The caller must close Body.
+type Download struct {
+	Body        io.ReadCloser
+	ContentType string
+	// Size is -1 when the API did not send a length.
+	Size   int64
+	SHA256 string
+}
+
+// Upload is a resumable upload. FileLocation is set once every byte has
+// arrived and the file is stored.
+type Upload struct {
+	ID           string
+	Offset       int64
+	Length       int64
+	Expires      time.Time
+	FileLocation string
+}
+
+// GCReport lists what a garbage collection sweep deleted, or would have
+// deleted in a dry run.
+type GCReport struct {
+	StartedAt       time.Time `json:"started_at"`
+	FinishedAt      time.Time `json:"finished_at"`
+	DryRun          bool      `json:"dry_run"`
+	OrphanedFiles   []uint64  `json:"orphaned_files"`
+	Blobs           []string  `json:"blobs"`
+	PartialUploads  []string  `json:"partial_uploads"`
+	StrayFiles      []string  `json:"stray_files"`
+	IdempotencyKeys int64     `json:"idempotency_keys"`
+	Errors          []string  `json:"errors,omitempty"`
+}
+
+// DatabaseStatus reports whether the storage backend answers and, for SQL
+// backends, the state of its connection pool.
+type DatabaseStatus struct {
+	Driver string     `json:"driver"`
+	Status string     `json:"status"`
+	Error  string     `json:"error,omitempty"`
+	Pool   *PoolStats `json:"pool,omitempty"`
+}
+
+type PoolStats struct {
+	MaxOpenConnections int    `json:"max_open_connections"`
+	OpenConnections    int    `json:"open_connections"`
+	InUse              int    `json:"in_use"`
+	Idle               int    `json:"idle"`
+	WaitCount          int64  `json:"wait_count"`
+	WaitDuration       string `json:"wait_duration"`
+	MaxIdleClosed      int64  `json:"max_idle_closed"`
+	MaxIdleTimeClosed  int64  `json:"max_idle_time_closed"`
+	MaxLifetimeClosed  int64  `json:"max_lifetime_closed"`
+}
+
+type CacheStats struct {
+	Hits      uint64 `json:"hits"`
+	Misses    uint64 `json:"misses"`
+	Evictions uint64 `json:"evictions"`
+	Entries   int    `json:"entries"`
+}
+
+// Subscription sends events of the listed types, or of every type when
+// Events is empty, to URL. Secret is only known when it is created.
+type Subscription struct {
+	ID        uint64    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SubscriptionInput registers a webhook. A secret is generated when
+// Secret is empty.
+type SubscriptionInput struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events,omitempty"`
+	Secret string   `json:"secret,omitempty"`
+}
+
+// Delivery states.
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryDead      = "dead"
+)
+
+// Delivery is one event on its way to one subscription.
+type Delivery struct {
+	ID             uint64    `json:"id"`
+	EventID        uint64    `json:"event_id"`
+	SubscriptionID uint64    `json:"subscription_id"`
+	Status         string    `json:"status"`
+	Attempts       int       `json:"attempts"`
+	NextAttemptAt  time.Time `json:"next_attempt_at"`
+	LastError      string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+	Event          Event     `json:"event"`
+	URL            string    `json:"url"`
+}
+
+// DeliveryFilter narrows Deliveries down to one Status and at most Limit
+// deliveries; the zero value lists the 100 most recent.
+type DeliveryFilter struct {
+	Status string
+	Limit  int
+}
+
+// Event types.
+const (
+	EventStudentCreated = "student.created"
+	EventStudentUpdated = "student.updated"
+	EventStudentDeleted = "student.deleted"
+)
+
+// Event is a change to a student. Data holds the id, name, email and age
+// the student has after the change, or had last when it was deleted.
+type Event struct {
+	ID        uint64          `json:"id"`
+	Type      string          `json:"type"`
+	StudentID int64           `json:"student_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// BatchRequest is one request of a batch. Body is sent as JSON.
+type BatchRequest struct {
+	Method  string            `json:"method"`
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    any               `json:"body,omitempty"`
+}
+
+// BatchResponse is the answer to one request of a batch. Body holds JSON
+// responses as they are and anything else as a JSON string.
+type BatchResponse struct {
+	Status  int               `json:"status"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Resumable uploads follow the tus 1.0 protocol.
+const (
+	tusVersion  = "1.0.0"
+	tusBasePath = "/api/files/tus"
+)
+
+// CreateUpload starts a resumable upload of length bytes. contentType and
+// studentID may be left empty.
+func (c *Client) CreateUpload(ctx context.Context, name string, contentType string, length int64, studentID int64) (Upload, error) {
+	metadata := []string{"filename " + base64.StdEncoding.EncodeToString([]byte(name))}
+	if contentType != "" {
+		metadata = append(metadata, "filetype "+base64.StdEncoding.EncodeToString([]byte(contentType)))
+	}
+	if studentID != 0 {
+		metadata = append(metadata, "student_id "+base64.StdEncoding.EncodeToString([]byte(strconv.FormatInt(studentID, 10))))
+	}
+	header := tusHeader()
+	header.Set("Upload-Length", strconv.FormatInt(length, 10))
+	header.Set("Upload-Metadata", strings.Join(metadata, ","))
+	res, err := c.send(ctx, request{method: http.MethodPost, path: tusBasePath, header: header, withKey: true})
+	if err != nil {
+		return Upload{}, err
+	}
+	res.Body.Close()
+	upload := readUpload(res)
+	upload.ID = path.Base(res.Header.Get("Location"))
+	upload.Length = length
+	return upload, nil
+}
+
+// UploadStatus reports how much of an upload the API has received.
+func (c *Client) UploadStatus(ctx context.Context, id string) (Upload, error) {
+	res, err := c.send(ctx, request{method: http.MethodHead, path: uploadPath(id), header: tusHeader()})
+	if err != nil {
+		return Upload{}, err
+	}
+	res.Body.Close()
+	upload := readUpload(res)
+	upload.ID = id
+	upload.Length, _ = strconv.ParseInt(res.Header.Get("Upload-Length"), 10, 64)
+	return upload, nil
+}
+
+// WriteUpload sends the bytes of an upload from offset on, which must be
+// the offset the API has reached. It returns the upload as it stands
+// after the bytes that arrived.
+func (c *Client) WriteUpload(ctx context.Context, id string, offset int64, content io.Reader) (Upload, error) {
+	header := tusHeader()
+	header.Set("Content-Type", "application/offset+octet-stream")
+	header.Set("Upload-Offset", strconv.FormatInt(offset, 10))
+	res, err := c.send(ctx, request{method: http.MethodPatch, path: uploadPath(id), header: header, stream: content})
+	if err != nil {
+		return Upload{}, err
+	}
+	res.Body.Close()
+	upload := readUpload(res)
+	upload.ID = id
+	return upload, nil
+}
+
+// CancelUpload ends an upload and removes what it received.
+func (c *Client) CancelUpload(ctx context.Context, id string) error {
+	return c.call(ctx, request{method: http.MethodDelete, path: uploadPath(id), header: tusHeader()}, nil, nil)
+}
+
+// ResumableUpload uploads content as a resumable upload. When sending it
+// fails, it asks how far the API got and carries on from there, up to the
+// number of attempts the client makes for other requests.
+func (c *Client) ResumableUpload(ctx context.Context, name string, contentType string, content io.ReadSeeker, studentID int64) (Upload, error) {
+	length, err := content.Seek(0, io.SeekEnd)
+	if err != nil {
+		return Upload{}, err
+	}
+	upload, err := c.CreateUpload(ctx, name, contentType, length, studentID)
+	if err != nil {
+		return Upload{}, err
+	}
+	for attempt := 0; ; attempt++ {
+		if _, err := content.Seek(upload.Offset, io.SeekStart); err != nil {
+			return upload, err
+		}
+		written, err := c.WriteUpload(ctx, upload.ID, upload.Offset, content)
+		if err == nil {
+			written.Length = length
+			return written, nil
+		}
+		// A broken connection or a failure on the API's side can be
+		// resumed, as can a write that started from the wrong offset
+		resumable := ctx.Err() == nil
+		var apiErr *Error
+		if errors.As(err, &apiErr) {
+			resumable = apiErr.StatusCode == http.StatusConflict || apiErr.StatusCode >= http.StatusInternalServerError
+		}
+		if !resumable || attempt+1 >= c.attempts {
+			return upload, err
+		}
+		if err := sleep(ctx, c.backoff.Delay(attempt)); err != nil {
+			return upload, err
+		}
+		status, err := c.UploadStatus(ctx, upload.ID)
+		if err != nil {
+			return upload, fmt.Errorf("client: resuming upload: %w", err)
+		}
+		upload = status
+	}
+}
+
+func tusHeader() http.Header {
+	return http.Header{"Tus-Resumable": {tusVersion}}
+}
+
+func uploadPath(id string) string {
+	return tusBasePath + "/" + id
+}
+
+func readUpload(res *http.Response) Upload {
+	var upload Upload
+	upload.Offset, _ = strconv.ParseInt(res.Header.Get("Upload-Offset"), 10, 64)
+	upload.Expires, _ = http.ParseTime(res.Header.Get("Upload-Expires"))
+	upload.FileLocation = res.Header.Get("File-Location")
+	return upload
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -4,22 +4,163 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/surajNirala/student-api/internal/config"
+	"github.com/surajNirala/student-api/internal/gc"
+	"github.com/surajNirala/student-api/internal/http/handlers/admin"
+	"github.com/surajNirala/student-api/internal/http/handlers/batch"
+	"github.com/surajNirala/student-api/internal/http/handlers/file"
 	"github.com/surajNirala/student-api/internal/http/handlers/student"
+	"github.com/surajNirala/student-api/internal/http/handlers/tus"
+	"github.com/surajNirala/student-api/internal/http/middleware"
+	"github.com/surajNirala/student-api/internal/models"
+	"github.com/surajNirala/student-api/internal/openapi"
 	"github.com/surajNirala/student-api/internal/storage"
+	"github.com/surajNirala/student-api/internal/storage/cache"
+	"github.com/surajNirala/student-api/internal/stream"
+	"github.com/surajNirala/student-api/internal/utils/response"
+	"github.com/surajNirala/student-api/internal/utils/signedurl"
 )
 
-func RouteLoad(router *http.ServeMux, storage storage.Storage) {
+// message stands for the map bodies handlers answer with, such as
+// {"Success": "OK", "Code": 201, "id": 1}.
+var message = map[string]any{}
+
+func RouteLoad(router *http.ServeMux, storage storage.Storage, cfg *config.Config, collector *gc.Collector, broker *stream.Broker) {
+	// Uploads stream bodies too large to fingerprint, so they are left out
+	idempotent := middleware.Idempotency(storage, cfg.Idempotency)
+	signer := signedurl.New(cfg.Signing)
+	boolean := &openapi.Schema{Type: "boolean"}
+	integer := &openapi.Schema{Type: "integer", Format: "int64"}
+	zero, one, maxDeliveries := 0.0, 1.0, 1000.0
+	// Most resources are numbered; uploads are not
+	byID := map[string]*openapi.Schema{"id": integer}
+
 	router.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Welcome to Student API " + time.Now().Format(time.RFC3339)))
 	})
-	router.HandleFunc("GET /api/students", student.List(storage))
-	router.HandleFunc("POST /api/students", student.Create(storage))
-	router.HandleFunc("GET /api/students/{id}", student.GetByID(storage))
-	router.HandleFunc("PUT /api/students/{id}", student.UpdateByID(storage))
-	router.HandleFunc("DELETE /api/students/{id}", student.DeleteByID(storage))
 
-	router.HandleFunc("GET /api/students1", student.List(storage))
+	// Every route is listed here once, both to register it and to describe
+	// it in /openapi.json
+	routes := []openapi.Route{
+		// Sub-requests go through router again, with everything registered here
+		{Method: http.MethodPost, Path: batch.BasePath, Handler: batch.Handle(router, storage, cfg.Batch), Tag: "batch",
+			Summary: "Run several requests in one round trip",
+			Query:   map[string]string{"atomic": "true runs all requests in one transaction and stops at the first failure"},
+			Params:  map[string]*openapi.Schema{"atomic": boolean},
+			Request: []batch.Request{}, Response: []batch.Response{}, Idempotent: true},
+
+		{Method: http.MethodGet, Path: "/api/students", Handler: student.List(storage), Tag: "students",
+			Summary: "List students", Response: []models.Student{}},
+		{Method: http.MethodPost, Path: "/api/students", Handler: student.Create(storage), Tag: "students",
+			Summary: "Create a student", Request: models.Student{}, Status: http.StatusCreated, Response: message, Idempotent: true},
+		{Method: http.MethodGet, Path: "/api/students/events", Handler: student.Events(storage, broker, cfg.Events), Tag: "students",
+			Summary:      "Stream student changes as Server-Sent Events",
+			Query:        map[string]string{"last_event_id": "Resume after this event; the Last-Event-ID header takes precedence"},
+			Params:       map[string]*openapi.Schema{"last_event_id": {Type: "integer", Minimum: &zero}},
+			ResponseType: "text/event-stream"},
+		{Method: http.MethodGet, Path: "/api/students/{id}", Handler: student.GetByID(storage), Tag: "students",
+			Params:   byID,
+			Summary:  "Get a student",
+			Query:    map[string]string{"as_of": "Return the student as it was at this RFC 3339 time or at the end of this date"},
+			Response: models.Student{}},
+		{Method: http.MethodPut, Path: "/api/students/{id}", Handler: student.UpdateByID(storage), Tag: "students",
+			Params:  byID,
+			Summary: "Update a student", Request: models.Student{}, Response: message},
+		{Method: http.MethodDelete, Path: "/api/students/{id}", Handler: student.DeleteByID(storage), Tag: "students",
+			Params:  byID,
+			Summary: "Delete a student", Response: ""},
+		{Method: http.MethodGet, Path: "/api/students/{id}/history", Handler: student.History(storage), Tag: "students",
+			Params:  byID,
+			Summary: "List the recorded changes of a student", Response: []models.AuditEntry{}},
+		{Method: http.MethodGet, Path: "/api/students/{id}/revisions", Handler: student.Revisions(storage), Tag: "students",
+			Params:  byID,
+			Summary: "List the revisions of a student", Response: []models.StudentRevision{}},
+		{Method: http.MethodPost, Path: "/api/students/{id}/revisions/{rev}/revert", Handler: student.Revert(storage), Tag: "students",
+			Params:  map[string]*openapi.Schema{"id": integer, "rev": integer},
+			Summary: "Restore the values of an earlier revision", Response: message, Idempotent: true},
+		{Method: http.MethodPut, Path: "/api/students/{id}/photo", Handler: student.UploadPhoto(storage, cfg.Uploads.Photo, cfg.Uploads.Quota), Tag: "students",
+			Params:  byID,
+			Summary: "Upload a student's photo", RequestType: "multipart/form-data", Response: message},
+		{Method: http.MethodGet, Path: "/api/students/{id}/photo", Handler: student.GetPhoto(storage), Tag: "students",
+			Params:       map[string]*openapi.Schema{"id": integer, "size": {Type: "string", Enum: []any{"thumb", "medium", "original"}}},
+			Summary:      "Download a student's photo",
+			Query:        map[string]string{"size": "thumb, medium or original (the default)"},
+			ResponseType: "image/*"},
+		{Method: http.MethodGet, Path: "/api/students/{id}/files/usage", Handler: student.FileUsage(storage, cfg.Uploads.Quota), Tag: "students",
+			Params:  byID,
+			Summary: "Report the storage a student's files use", Response: message},
+
+		{Method: http.MethodGet, Path: "/api/students1", Handler: student.List(storage), Tag: "students",
+			Summary: "List students", Response: []models.Student{}, Deprecated: true},
+
+		{Method: http.MethodPost, Path: "/api/students/file-upload", Handler: student.FileUpload10MB(storage, cfg.Uploads.File, cfg.Uploads.Quota), Tag: "files",
+			Summary: "Upload a file", RequestType: "multipart/form-data", Response: message},
+		{Method: http.MethodPost, Path: "/api/students/large-file-upload", Handler: student.LargeFileUpload(storage, cfg.Uploads.LargeFile, cfg.Uploads.Quota), Tag: "files",
+			Summary: "Upload a large file", RequestType: "multipart/form-data", Response: message},
+		{Method: http.MethodGet, Path: "/api/files/{id}", Handler: file.Download(storage), Tag: "files",
+			Params:  byID,
+			Summary: "Download a file", ResponseType: "application/octet-stream"},
+		{Method: http.MethodDelete, Path: "/api/files/{id}", Handler: file.Delete(storage), Tag: "files",
+			Params:  byID,
+			Summary: "Delete a file", Response: ""},
+		{Method: http.MethodPost, Path: "/api/files/{id}/links", Handler: file.CreateLink(storage, signer, cfg.Signing), Tag: "files",
+			Params:  byID,
+			Summary: "Create a signed download link", Request: file.LinkRequest{}, OptionalBody: true, Status: http.StatusCreated, Response: message, Idempotent: true},
+		{Method: http.MethodGet, Path: "/api/files/signed/{id}", Handler: file.SignedDownload(storage, signer), Tag: "files",
+			Params:  byID,
+			Summary: "Download a file with a signed link", ResponseType: "application/octet-stream"},
+
+		{Method: http.MethodOptions, Path: tus.BasePath, Handler: tus.Options(cfg.Uploads.Tus), Tag: "uploads",
+			Summary: "Describe the supported tus extensions", Status: http.StatusNoContent},
+		{Method: http.MethodPost, Path: tus.BasePath, Handler: tus.Create(storage, cfg.Uploads.Tus, cfg.Uploads.Quota), Tag: "uploads",
+			Summary: "Start a resumable upload", Status: http.StatusCreated, Idempotent: true},
+		{Method: http.MethodHead, Path: tus.BasePath + "/{id}", Handler: tus.Head(storage, cfg.Uploads.Tus), Tag: "uploads",
+			Summary: "Get the offset of a resumable upload"},
+		{Method: http.MethodPatch, Path: tus.BasePath + "/{id}", Handler: tus.Patch(storage, cfg.Uploads.Tus, cfg.Uploads.Quota), Tag: "uploads",
+			Summary: "Append to a resumable upload", RequestType: "application/offset+octet-stream", Status: http.StatusNoContent},
+		{Method: http.MethodDelete, Path: tus.BasePath + "/{id}", Handler: tus.Delete(storage), Tag: "uploads",
+			Summary: "Cancel a resumable upload", Status: http.StatusNoContent},
 
-	router.HandleFunc("POST /api/students/file-upload", student.FileUpload10MB(storage))
-	router.HandleFunc("POST /api/students/large-file-upload", student.LargeFileUpload(storage))
+		{Method: http.MethodGet, Path: "/api/admin/gc", Handler: admin.GCReport(collector), Tag: "admin",
+			Summary: "Get the report of the last garbage collection", Response: gc.Report{}},
+		{Method: http.MethodPost, Path: "/api/admin/gc", Handler: admin.RunGC(collector), Tag: "admin",
+			Summary:  "Run garbage collection now",
+			Query:    map[string]string{"dry_run": "true only reports what would be removed"},
+			Params:   map[string]*openapi.Schema{"dry_run": boolean},
+			Response: gc.Report{}, Idempotent: true},
+		{Method: http.MethodGet, Path: "/api/admin/db", Handler: admin.Database(storage, cfg.Storage.Driver), Tag: "admin",
+			Summary: "Check the database and its connection pool", Response: message},
+		{Method: http.MethodGet, Path: "/api/admin/cache", Handler: admin.CacheStats(storage), Tag: "admin",
+			Summary: "Get the student cache statistics", Response: cache.Stats{}},
+		{Method: http.MethodGet, Path: "/api/admin/webhooks", Handler: admin.Subscriptions(storage), Tag: "webhooks",
+			Summary: "List webhook subscriptions", Response: []models.Subscription{}},
+		{Method: http.MethodPost, Path: "/api/admin/webhooks", Handler: admin.CreateSubscription(storage), Tag: "webhooks",
+			Summary: "Subscribe a webhook", Request: admin.SubscriptionRequest{}, Status: http.StatusCreated, Response: message, Idempotent: true},
+		{Method: http.MethodDelete, Path: "/api/admin/webhooks/{id}", Handler: admin.DeleteSubscription(storage), Tag: "webhooks",
+			Params:  byID,
+			Summary: "Remove a webhook subscription", Status: http.StatusNoContent},
+		{Method: http.MethodGet, Path: "/api/admin/webhooks/deliveries", Handler: admin.Deliveries(storage), Tag: "webhooks",
+			Summary: "List webhook deliveries",
+			Query:   map[string]string{"status": "Only deliveries with this status", "limit": "Maximum number of deliveries"},
+			Params: map[string]*openapi.Schema{
+				"status": {Type: "string", Enum: []any{models.DeliveryPending, models.DeliveryDelivered, models.DeliveryDead}},
+				"limit":  {Type: "integer", Minimum: &one, Maximum: &maxDeliveries},
+			},
+			Response: []models.Delivery{}},
+		{Method: http.MethodPost, Path: "/api/admin/webhooks/deliveries/{id}/replay", Handler: admin.ReplayDelivery(storage), Tag: "webhooks",
+			Params:  byID,
+			Summary: "Send a delivery again", Status: http.StatusAccepted, Response: message, Idempotent: true},
+	}
+	doc := openapi.Build(openapi.Info{Title: "Student API", Version: "1.0.0"}, routes, response.Response{}, response.Problem{})
+	validate := middleware.Validation(doc, cfg.Validation)
+	for _, route := range routes {
+		handler := route.Handler
+		if route.Idempotent {
+			handler = idempotent(handler)
+		}
+		// Invalid requests are refused before they can take an idempotency key
+		router.Handle(route.Pattern(), validate(route.Method, route.Path)(handler))
+	}
+	router.HandleFunc("GET /openapi.json", openapi.Handler(doc))
+	router.Handle("GET /docs/", http.StripPrefix("/docs/", openapi.Viewer()))
 }